@@ -7,9 +7,12 @@ import (
 	"sync"
 
 	"core/cmd/middleware"
-	"core/internal/k8s"
+	"core/internal/lifecycle"
+	"core/internal/orchestrator"
+	"core/internal/postgres"
 	"core/internal/redis"
-	"core/internal/s3"
+	"core/internal/store"
+	"core/internal/workspace"
 	"core/pkg/dotenv"
 	"core/services/auth"
 	"core/services/repl"
@@ -19,7 +22,10 @@ import (
 	"github.com/rs/cors"
 )
 
-var FRONTEND_URL = dotenv.EnvString("FRONTEND_URL", "*")
+var (
+	FRONTEND_URL = dotenv.EnvString("FRONTEND_URL", "*")
+	REPL_STORE   = dotenv.EnvString("REPL_STORE", "redis")
+)
 
 type APIServer struct {
 	addr string
@@ -34,46 +40,48 @@ func NewAPIServer(addr string) *APIServer {
 func (api *APIServer) Run() error {
 
 	router := http.NewServeMux()
-	s3Client := s3.NewS3Client()
-	rds := redis.NewRedisStore()
+	workspaces := workspace.NewStorage()
+	replStore := newReplStore()
+	orch := orchestrator.New(workspaces)
+	manager := lifecycle.NewManager(replStore, newLocker(replStore), orch, workspaces)
 
 	router.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
 		var mu sync.Mutex
 		var wg sync.WaitGroup
 		wg.Add(3)
 		status := map[string]string{
-			"api":   "ok",
-			"k8s":   "ok",
-			"s3":    "ok",
-			"redis": "ok",
+			"api":                     "ok",
+			"storage":                 "ok",
+			REPL_STORE:                "ok",
+			orchestrator.ORCHESTRATOR: "ok",
 		}
 
 		go func() {
 			defer wg.Done()
-			if err := rds.Ping(); err != nil {
+			if err := replStore.Ping(); err != nil {
 				mu.Lock()
 				status["api"] = "degraded"
-				status["redis"] = fmt.Sprintf("%v", err)
+				status[REPL_STORE] = fmt.Sprintf("%v", err)
 				mu.Unlock()
 			}
 		}()
 
 		go func() {
 			defer wg.Done()
-			if err := s3Client.Ping(); err != nil {
+			if err := workspaces.Ping(r.Context()); err != nil {
 				mu.Lock()
 				status["api"] = "degraded"
-				status["s3"] = fmt.Sprintf("%v", err)
+				status["storage"] = fmt.Sprintf("%v", err)
 				mu.Unlock()
 			}
 		}()
 
 		go func() {
 			defer wg.Done()
-			if _, err := k8s.CheckStatus(); err != nil {
+			if err := orch.Ping(r.Context()); err != nil {
 				mu.Lock()
 				status["api"] = "degraded"
-				status["k8s"] = fmt.Sprintf("%v", err)
+				status[orchestrator.ORCHESTRATOR] = fmt.Sprintf("%v", err)
 				mu.Unlock()
 			}
 		}()
@@ -87,11 +95,11 @@ func (api *APIServer) Run() error {
 	router.Handle("/auth/", http.StripPrefix("/auth", auth.NewAuthHandler()))
 
 	// Runner Routes
-	router.Handle("/api/runner/", http.StripPrefix("/api/runner", runner.NewHandler(rds)))
+	router.Handle("/api/runner/", http.StripPrefix("/api/runner", runner.NewHandler(replStore, manager, workspaces)))
 
 	// Protected Repl Routes
 	router.Handle("/api/repl/", middleware.AuthMiddleware(
-		http.StripPrefix("/api/repl", repl.NewHandler(s3Client, rds))))
+		http.StripPrefix("/api/repl", repl.NewHandler(workspaces, replStore, manager, orch))))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{FRONTEND_URL, "http://localhost:3000"},
@@ -108,3 +116,33 @@ func (api *APIServer) Run() error {
 	log.Println("Server has started at ", api.addr)
 	return server.ListenAndServe()
 }
+
+// newReplStore picks the repl metadata backend from REPL_STORE
+// ("redis", "postgres" or "memory").
+func newReplStore() store.ReplStore {
+	switch REPL_STORE {
+	case "postgres":
+		return postgres.NewPostgresStore()
+	case "memory":
+		log.Println("⚠️ Using in-memory repl store, data will be lost on restart")
+		return store.NewMemoryStore()
+	case "redis":
+		return redis.NewRedisStore()
+	default:
+		log.Printf("⚠️ Unknown REPL_STORE %q, falling back to redis", REPL_STORE)
+		return redis.NewRedisStore()
+	}
+}
+
+// newLocker returns the lock used to serialise repl start/stop. Locks live in
+// Redis so that they are shared by every core replica.
+func newLocker(replStore store.ReplStore) store.Locker {
+	if locker, ok := replStore.(store.Locker); ok {
+		return locker
+	}
+	if redis.REDIS_URL != "" {
+		return redis.NewRedisStore()
+	}
+	log.Println("⚠️ REDIS_URL is not set, repl locks are only local to this core replica")
+	return store.NewMemoryLocker()
+}
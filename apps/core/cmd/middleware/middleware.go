@@ -17,7 +17,11 @@ import (
 
 type contextKey string
 
-const UserContextKey contextKey = "user"
+const (
+	UserContextKey   contextKey = "user"
+	TokenContextKey  contextKey = "token"
+	ScopesContextKey contextKey = "scopes"
+)
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -58,8 +62,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
-		// Add user to context
+		// Add user (and GitHub token, if any) to context
 		ctx := context.WithValue(r.Context(), UserContextKey, tokenInfo.User)
+		if tokenInfo.Token != nil {
+			ctx = context.WithValue(ctx, TokenContextKey, tokenInfo.Token)
+			ctx = context.WithValue(ctx, ScopesContextKey, tokenInfo.Scopes)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -77,3 +85,16 @@ func GetUserFromContext(ctx context.Context) (*models.User, bool) {
 	user, ok := ctx.Value(UserContextKey).(*models.User)
 	return user, ok
 }
+
+// GetTokenFromContext returns the user's GitHub token, missing for magic link
+// sessions
+func GetTokenFromContext(ctx context.Context) (*oauth2.Token, bool) {
+	token, ok := ctx.Value(TokenContextKey).(*oauth2.Token)
+	return token, ok
+}
+
+// GetScopesFromContext returns the scopes granted to the user's GitHub token
+func GetScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ScopesContextKey).([]string)
+	return scopes
+}
@@ -0,0 +1,366 @@
+package githubrepo
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"packages/storage"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	// maxExportFiles bounds the blobs of an export, each new file costs an
+	// API call out of the user's hourly quota
+	maxExportFiles = 2000
+	// maxExportSize bounds the total size of an export
+	maxExportSize = 512 << 20
+	// maxExportFileSize is the largest file accepted by GitHub's blob API
+	maxExportFileSize = 100 << 20
+)
+
+var (
+	ErrNoPushAccess   = errors.New("you don't have push access to this repository")
+	ErrEmptyWorkspace = errors.New("there are no files to export")
+)
+
+// exportSkipped are folders never exported, at any depth
+var exportSkipped = []string{".git", "node_modules"}
+
+type ExportOptions struct {
+	// Branch receives the commit, the default branch when empty. A missing
+	// branch is created from the default branch.
+	Branch  string
+	Message string
+	// Create makes the repository when it does not exist
+	Create  bool
+	Private bool
+}
+
+type ExportResult struct {
+	Repo   Repo   `json:"repo"`
+	Commit string `json:"commit"`
+	URL    string `json:"url"`
+	// Created is set when the repository was made by the export
+	Created bool `json:"created,omitempty"`
+	// Unchanged is set when the branch already held the same files, no
+	// commit is made then
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+type exportFile struct {
+	path string
+	key  string
+	size int64
+}
+
+// Export commits the files below prefix to a branch of repo, replacing its
+// tree: files missing from prefix are deleted from the branch. It uses the
+// git data API so no clone is needed, and only uploads files GitHub does not
+// have yet.
+func Export(ctx context.Context, client *github.Client, repo Repo, workspaces storage.WorkspaceStorage, prefix string, opts ExportOptions) (ExportResult, error) {
+	files, err := listExportFiles(ctx, workspaces, prefix)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	info, created, err := getOrCreateRepo(ctx, client, repo, opts)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if !info.GetPermissions()["push"] {
+		return ExportResult{}, ErrNoPushAccess
+	}
+
+	branch := opts.Branch
+	if branch == "" {
+		branch = info.GetDefaultBranch()
+	}
+	result := ExportResult{Repo: Repo{Owner: info.GetOwner().GetLogin(), Name: info.GetName(), Ref: branch}, Created: created}
+	repo = result.Repo
+
+	parent, branchExists, err := resolveParent(ctx, client, repo, info.GetDefaultBranch())
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if parent == "" {
+		// The git data API refuses to work on empty repositories, the
+		// contents API makes the first commit of their default branch
+		if parent, err = initializeRepo(ctx, client, repo, workspaces, files[0], opts.Message); err != nil {
+			return ExportResult{}, err
+		}
+		branchExists = branch == info.GetDefaultBranch()
+	}
+
+	parentCommit, _, err := client.Git.GetCommit(ctx, repo.Owner, repo.Name, parent)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	parentTree := parentCommit.GetTree().GetSHA()
+
+	existing, err := treeBlobs(ctx, client, repo, parentTree)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	entries := make([]*github.TreeEntry, 0, len(files))
+	for _, file := range files {
+		entry, err := uploadBlob(ctx, client, repo, workspaces, file, existing[file.path])
+		if err != nil {
+			return ExportResult{}, fmt.Errorf("failed to upload %s: %w", file.path, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	tree, _, err := client.Git.CreateTree(ctx, repo.Owner, repo.Name, "", entries)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	result.URL = fmt.Sprintf("%s/tree/%s", info.GetHTMLURL(), branch)
+	if tree.GetSHA() == parentTree && branchExists {
+		result.Commit = parent
+		result.Unchanged = true
+		return result, nil
+	}
+
+	commit, _, err := client.Git.CreateCommit(ctx, repo.Owner, repo.Name, &github.Commit{
+		Message: github.String(opts.Message),
+		Tree:    &github.Tree{SHA: tree.SHA},
+		Parents: []*github.Commit{{SHA: github.String(parent)}},
+	}, nil)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	if branchExists {
+		// Not forced: the commit descends from the branch, a push made in the
+		// meantime fails the export instead of being overwritten
+		_, _, err = client.Git.UpdateRef(ctx, repo.Owner, repo.Name, ref, false)
+	} else {
+		_, _, err = client.Git.CreateRef(ctx, repo.Owner, repo.Name, ref)
+	}
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	result.Commit = commit.GetSHA()
+	return result, nil
+}
+
+// listExportFiles lists the files below prefix, by path relative to it
+func listExportFiles(ctx context.Context, workspaces storage.WorkspaceStorage, prefix string) ([]exportFile, error) {
+	objects, err := workspaces.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []exportFile
+	var total int64
+	for _, object := range objects {
+		rel := strings.TrimPrefix(object.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") || skipExport(rel) {
+			continue
+		}
+		if object.Size > maxExportFileSize {
+			return nil, fmt.Errorf("%s is larger than %d MB", rel, maxExportFileSize>>20)
+		}
+
+		total += object.Size
+		files = append(files, exportFile{path: rel, key: object.Key, size: object.Size})
+	}
+
+	switch {
+	case len(files) == 0:
+		return nil, ErrEmptyWorkspace
+	case len(files) > maxExportFiles:
+		return nil, fmt.Errorf("too many files to export (%d, at most %d)", len(files), maxExportFiles)
+	case total > maxExportSize:
+		return nil, fmt.Errorf("workspace is larger than %d MB", maxExportSize>>20)
+	}
+	return files, nil
+}
+
+func skipExport(rel string) bool {
+	for _, segment := range strings.Split(rel, "/") {
+		for _, skipped := range exportSkipped {
+			if segment == skipped {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getOrCreateRepo looks repo up, creating it when opts.Create is set. It is
+// created in an organization when repo.Owner is not the user.
+func getOrCreateRepo(ctx context.Context, client *github.Client, repo Repo, opts ExportOptions) (*github.Repository, bool, error) {
+	info, _, err := client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err == nil {
+		return info, false, nil
+	}
+	if err = notFound(err); !errors.Is(err, ErrRepoNotFound) || !opts.Create {
+		return nil, false, err
+	}
+
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, false, err
+	}
+	org := repo.Owner
+	if strings.EqualFold(org, user.GetLogin()) {
+		org = ""
+	}
+
+	info, _, err = client.Repositories.Create(ctx, org, &github.Repository{
+		Name:        github.String(repo.Name),
+		Private:     github.Bool(opts.Private),
+		Description: github.String("Exported from DevEx"),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create repository: %w", err)
+	}
+	return info, true, nil
+}
+
+// resolveParent returns the commit the export builds on: the head of the
+// branch, or of the default branch when the branch does not exist yet. It is
+// "" for empty repositories.
+func resolveParent(ctx context.Context, client *github.Client, repo Repo, defaultBranch string) (string, bool, error) {
+	ref, _, err := client.Git.GetRef(ctx, repo.Owner, repo.Name, "heads/"+repo.Ref)
+	if err == nil {
+		return ref.GetObject().GetSHA(), true, nil
+	}
+	switch statusCode(err) {
+	case http.StatusConflict:
+		// "Git Repository is empty."
+		return "", false, nil
+	case http.StatusNotFound:
+	default:
+		return "", false, err
+	}
+
+	if repo.Ref == defaultBranch {
+		return "", false, nil
+	}
+	ref, _, err = client.Git.GetRef(ctx, repo.Owner, repo.Name, "heads/"+defaultBranch)
+	if err != nil {
+		if code := statusCode(err); code == http.StatusConflict || code == http.StatusNotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return ref.GetObject().GetSHA(), false, nil
+}
+
+// initializeRepo commits a first file to the default branch of an empty
+// repository and returns the commit
+func initializeRepo(ctx context.Context, client *github.Client, repo Repo, workspaces storage.WorkspaceStorage, file exportFile, message string) (string, error) {
+	content, err := readExportFile(ctx, workspaces, file)
+	if err != nil {
+		return "", err
+	}
+
+	resp, _, err := client.Repositories.CreateFile(ctx, repo.Owner, repo.Name, file.path, &github.RepositoryContentFileOptions{
+		Message: github.String(message),
+		Content: content,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	return resp.GetSHA(), nil
+}
+
+type treeBlob struct {
+	sha  string
+	mode string
+}
+
+// treeBlobs maps the paths of the files in a tree to their blob
+func treeBlobs(ctx context.Context, client *github.Client, repo Repo, sha string) (map[string]treeBlob, error) {
+	tree, _, err := client.Git.GetTree(ctx, repo.Owner, repo.Name, sha, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// A truncated tree only means more blobs get uploaded again
+	blobs := make(map[string]treeBlob, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			blobs[entry.GetPath()] = treeBlob{sha: entry.GetSHA(), mode: entry.GetMode()}
+		}
+	}
+	return blobs, nil
+}
+
+// uploadBlob returns the tree entry of a file, uploading it unless GitHub
+// already has the same content at that path. Storage does not keep file
+// modes, so an existing file keeps its mode (e.g. executable scripts).
+func uploadBlob(ctx context.Context, client *github.Client, repo Repo, workspaces storage.WorkspaceStorage, file exportFile, existing treeBlob) (*github.TreeEntry, error) {
+	content, err := readExportFile(ctx, workspaces, file)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := existing.mode
+	if mode == "" || mode == "120000" {
+		mode = "100644"
+	}
+	entry := &github.TreeEntry{
+		Path: github.String(file.path),
+		Mode: github.String(mode),
+		Type: github.String("blob"),
+	}
+
+	if sha := blobSHA(content); sha == existing.sha {
+		entry.SHA = github.String(sha)
+		return entry, nil
+	}
+
+	blob, _, err := client.Git.CreateBlob(ctx, repo.Owner, repo.Name, &github.Blob{
+		Content:  github.String(base64.StdEncoding.EncodeToString(content)),
+		Encoding: github.String("base64"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entry.SHA = blob.SHA
+	return entry, nil
+}
+
+func readExportFile(ctx context.Context, workspaces storage.WorkspaceStorage, file exportFile) ([]byte, error) {
+	body, err := workspaces.Get(ctx, file.key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(io.LimitReader(body, maxExportFileSize))
+}
+
+// blobSHA is the id git gives to a file's content
+func blobSHA(content []byte) string {
+	h := sha1.New()
+	h.Write([]byte("blob " + strconv.Itoa(len(content)) + "\x00"))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func statusCode(err error) int {
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return ghErr.Response.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+// Package githubrepo moves repl files between storage and GitHub repositories.
+package githubrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+// ErrRepoNotFound is returned for repositories that do not exist or that the
+// user's token can not read.
+var ErrRepoNotFound = errors.New("repository not found or not accessible")
+
+// Repo is a GitHub repository, optionally at a branch, tag or commit
+type Repo struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	Ref   string `json:"ref,omitempty"`
+}
+
+func (r Repo) String() string {
+	if r.Ref == "" {
+		return r.Owner + "/" + r.Name
+	}
+	return r.Owner + "/" + r.Name + "@" + r.Ref
+}
+
+// ParseURL accepts the usual ways of pointing at a repository:
+//
+//	https://github.com/owner/repo(.git)
+//	https://github.com/owner/repo/tree/<ref>
+//	github.com/owner/repo
+//	git@github.com:owner/repo.git
+//	owner/repo
+func ParseURL(raw string) (Repo, error) {
+	raw = strings.TrimSpace(raw)
+	invalid := fmt.Errorf("invalid GitHub repository %q", raw)
+
+	path := raw
+	switch {
+	case strings.HasPrefix(raw, "git@github.com:"):
+		path = strings.TrimPrefix(raw, "git@github.com:")
+	case strings.Contains(raw, "github.com"):
+		if !strings.Contains(raw, "://") {
+			raw = "https://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Host != "github.com" && u.Host != "www.github.com") {
+			return Repo{}, invalid
+		}
+		path = u.Path
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Repo{}, invalid
+	}
+
+	repo := Repo{Owner: parts[0], Name: strings.TrimSuffix(parts[1], ".git")}
+	if len(parts) > 3 && parts[2] == "tree" {
+		repo.Ref = strings.Join(parts[3:], "/")
+	} else if len(parts) > 2 {
+		return Repo{}, invalid
+	}
+
+	return repo, nil
+}
+
+// NewClient returns a GitHub client acting as the user, or an anonymous one
+// (public repositories only) when there is no token, e.g. for magic link
+// sessions.
+func NewClient(ctx context.Context, token *oauth2.Token) *github.Client {
+	if token == nil {
+		return github.NewClient(nil)
+	}
+	return github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+}
+
+// notFound maps GitHub's answer for missing and hidden repositories
+func notFound(err error) error {
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusNotFound {
+		return ErrRepoNotFound
+	}
+	return err
+}
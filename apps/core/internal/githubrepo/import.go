@@ -0,0 +1,172 @@
+package githubrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"packages/storage"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// Stages reported while importing, in order
+const (
+	StageResolvingRepository = "resolvingRepository"
+	StageDetectingTemplate   = "detectingTemplate"
+	StageUploadingFiles      = "uploadingFiles"
+)
+
+// maxImportSize bounds the extracted size of an imported repository
+const maxImportSize = 512 << 20
+
+var ErrTemplateNotDetected = errors.New("could not detect the project type, pick a template")
+
+// templateMarkers are the files identifying the template of a project, the
+// first template with a marker in the repository root wins.
+var templateMarkers = []struct {
+	template string
+	files    []string
+}{
+	{"go", []string{"go.mod"}},
+	{"node", []string{"package.json"}},
+	{"python", []string{"pyproject.toml", "requirements.txt", "setup.py", "Pipfile"}},
+}
+
+type ImportOptions struct {
+	// Template skips detection when set
+	Template   string
+	OnProgress func(stage, message string)
+}
+
+// Import copies the files of repo below prefix and returns the template of
+// the project. Nothing is left below prefix when it fails.
+func Import(ctx context.Context, client *github.Client, repo Repo, workspaces storage.WorkspaceStorage, prefix string, opts ImportOptions) (template string, err error) {
+	progress := opts.OnProgress
+	if progress == nil {
+		progress = func(string, string) {}
+	}
+
+	progress(StageResolvingRepository, "Looking up "+repo.String())
+	info, _, err := client.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return "", notFound(err)
+	}
+	if repo.Ref == "" {
+		repo.Ref = info.GetDefaultBranch()
+	}
+
+	template = opts.Template
+	if template == "" {
+		if template, err = detectTemplate(ctx, client, repo); err != nil {
+			return "", err
+		}
+	}
+	progress(StageDetectingTemplate, fmt.Sprintf("Using the %s template", template))
+
+	progress(StageUploadingFiles, "Downloading "+repo.String())
+	link, _, err := client.Repositories.GetArchiveLink(ctx, repo.Owner, repo.Name, github.Tarball,
+		&github.RepositoryContentGetOptions{Ref: repo.Ref}, 3)
+	if err != nil {
+		return "", notFound(err)
+	}
+
+	if err := uploadTarball(ctx, link.String(), workspaces, prefix); err != nil {
+		if cleanupErr := workspaces.DeletePrefix(context.WithoutCancel(ctx), prefix); cleanupErr != nil {
+			err = errors.Join(err, cleanupErr)
+		}
+		return "", err
+	}
+
+	return template, nil
+}
+
+// detectTemplate looks for marker files in the root of the repository
+func detectTemplate(ctx context.Context, client *github.Client, repo Repo) (string, error) {
+	_, entries, _, err := client.Repositories.GetContents(ctx, repo.Owner, repo.Name, "",
+		&github.RepositoryContentGetOptions{Ref: repo.Ref})
+	if err != nil {
+		return "", notFound(err)
+	}
+
+	root := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.GetType() == "file" {
+			root[entry.GetName()] = true
+		}
+	}
+
+	for _, marker := range templateMarkers {
+		for _, file := range marker.files {
+			if root[file] {
+				return marker.template, nil
+			}
+		}
+	}
+	return "", ErrTemplateNotDetected
+}
+
+// uploadTarball streams a GitHub tarball into storage. Its entries live in a
+// single top folder (<owner>-<repo>-<sha>/) which is stripped.
+func uploadTarball(ctx context.Context, link string, workspaces storage.WorkspaceStorage, prefix string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download repository: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download repository: status %d", resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("invalid repository archive: %w", err)
+	}
+	defer gz.Close()
+
+	var total int64
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid repository archive: %w", err)
+		}
+		// Like workspace sync, only regular files are kept
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		_, rel, ok := strings.Cut(path.Clean(header.Name), "/")
+		if !ok || rel == "" || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		total += header.Size
+		if total > maxImportSize {
+			return fmt.Errorf("repository is larger than %d MB", maxImportSize>>20)
+		}
+
+		// Buffer the file, object storage wants a seekable body
+		body, err := io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("invalid repository archive: %w", err)
+		}
+		if err := workspaces.Put(ctx, path.Join(prefix, rel), bytes.NewReader(body), int64(len(body))); err != nil {
+			return err
+		}
+	}
+}
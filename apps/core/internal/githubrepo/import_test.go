@@ -0,0 +1,160 @@
+package githubrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"packages/storage"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+// tarball is a gzipped tar of entries, as GitHub serves them
+func tarball(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644}
+		if e.typeflag == tar.TypeReg {
+			header.Size = int64(len(e.body))
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadTarball(t *testing.T) {
+	const prefix = "repl/octocat/demo/"
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    []string
+	}{
+		{
+			name: "top folder is stripped",
+			entries: []tarEntry{
+				{name: "octocat-demo-abc123/", typeflag: tar.TypeDir},
+				{name: "octocat-demo-abc123/go.mod", typeflag: tar.TypeReg, body: "module demo"},
+				{name: "octocat-demo-abc123/cmd/main.go", typeflag: tar.TypeReg, body: "package main"},
+			},
+			want: []string{"cmd/main.go", "go.mod"},
+		},
+		{
+			name: "only regular files are kept",
+			entries: []tarEntry{
+				{name: "octocat-demo-abc123/README.md", typeflag: tar.TypeReg, body: "# demo"},
+				{name: "octocat-demo-abc123/docs", typeflag: tar.TypeSymlink, linkname: "/etc"},
+				{name: "octocat-demo-abc123/hard", typeflag: tar.TypeLink, linkname: "octocat-demo-abc123/README.md"},
+				{name: "octocat-demo-abc123/src/", typeflag: tar.TypeDir},
+			},
+			want: []string{"README.md"},
+		},
+		{
+			name: "entries outside of the repository are skipped",
+			entries: []tarEntry{
+				{name: "octocat-demo-abc123/main.go", typeflag: tar.TypeReg, body: "package main"},
+				{name: "top-level-file", typeflag: tar.TypeReg, body: "no top folder"},
+				{name: "octocat-demo-abc123/../../../escape.txt", typeflag: tar.TypeReg, body: "escape"},
+				{name: "../..", typeflag: tar.TypeReg, body: "parent"},
+				{name: "octocat-demo-abc123/a/../../b.txt", typeflag: tar.TypeReg, body: "b"},
+			},
+			want: []string{"main.go"},
+		},
+		{
+			name: "paths are cleaned",
+			entries: []tarEntry{
+				{name: "octocat-demo-abc123/src/../lib//util.go", typeflag: tar.TypeReg, body: "package lib"},
+				{name: "octocat-demo-abc123/./cmd/main.go", typeflag: tar.TypeReg, body: "package main"},
+			},
+			want: []string{"cmd/main.go", "lib/util.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			archive := tarball(t, tt.entries)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archive)
+			}))
+			defer server.Close()
+
+			workspaces := storage.NewFS(filepath.Join(t.TempDir(), "storage"))
+			if err := uploadTarball(ctx, server.URL, workspaces, prefix); err != nil {
+				t.Fatal(err)
+			}
+
+			objects, err := workspaces.List(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, obj := range objects {
+				rel, ok := strings.CutPrefix(obj.Key, prefix)
+				if !ok {
+					t.Errorf("object %s written outside of %s", obj.Key, prefix)
+					continue
+				}
+				got = append(got, rel)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("uploaded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadTarballErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   []byte
+	}{
+		{name: "download fails", status: http.StatusNotFound},
+		{name: "not gzipped", status: http.StatusOK, body: []byte("not a tarball")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			workspaces := storage.NewFS(filepath.Join(t.TempDir(), "storage"))
+			if err := uploadTarball(context.Background(), server.URL, workspaces, "repl/octocat/demo/"); err == nil {
+				t.Error("uploadTarball() error = nil")
+			}
+		})
+	}
+}
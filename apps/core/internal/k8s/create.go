@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
 
+	"core/internal/tokens"
 	"core/models"
 	"core/pkg/dotenv"
+	"packages/replauth"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -15,10 +18,27 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-var RUNNER_CLUSTER_IP = dotenv.EnvString("RUNNER_CLUSTER_IP", "localhost")
+var (
+	RUNNER_CLUSTER_IP = dotenv.EnvString("RUNNER_CLUSTER_IP", "localhost")
+	// PREVIEW_DOMAIN serves the apps of the repls on
+	// <port>-<replId>.<PREVIEW_DOMAIN>, its wildcard DNS record must point to
+	// the ingress controller. Subdomain previews are off when unset.
+	PREVIEW_DOMAIN = dotenv.EnvString("PREVIEW_DOMAIN", "")
+	// PREVIEW_TLS_SECRET holds the certificate of *.<PREVIEW_DOMAIN>
+	PREVIEW_TLS_SECRET = dotenv.EnvString("PREVIEW_TLS_SECRET", "preview-tls-secret")
+	// FRONTEND_URL is the editor, the only origin the runners answer CORS
+	// requests of
+	FRONTEND_URL = dotenv.EnvString("FRONTEND_URL", "http://localhost:3000")
+)
+
+// terminationGracePeriod (seconds) bounds the runner's final workspace upload
+const terminationGracePeriod = 60
 
-func CreateReplDeploymentAndService(userName, replId, template string) error {
-	clientset, _ := getClientSet()
+func CreateReplDeploymentAndService(userName, replId, template string, revocations replauth.Revocations) error {
+	clientset, err := getClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to load k8s client: %w", err)
+	}
 	ctx := context.Background()
 
 	config, exists := models.TemplateConfigs[template]
@@ -26,9 +46,6 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 		return fmt.Errorf("unsupported template: %s", template)
 	}
 
-	region := dotenv.EnvString("SPACES_REGION", "blr1")
-	bucket := dotenv.EnvString("SPACES_BUCKET", "devex")
-
 	labels := map[string]string{
 		"app":      replId,
 		"template": template,
@@ -57,30 +74,16 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 							},
 						},
 					},
-					InitContainers: []corev1.Container{
-						{
-							Name:    "s3-downloader",
-							Image:   "amazon/aws-cli",
-							Command: []string{"sh", "-c"},
-							Args: []string{
-								fmt.Sprintf(`aws s3 cp s3://%s/repl/%s/%s/ /workspaces --recursive --endpoint-url https://%s.digitaloceanspaces.com && echo "Resources copied from DO Spaces";`, bucket, userName, replId, region),
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "workspace-vol",
-									MountPath: "/workspaces",
-								},
-							},
-							Env: awsEnvVars(),
-						},
-					},
+					// The runner downloads the workspace on boot and uploads it
+					// on SIGTERM, give it time to finish before it is killed.
+					TerminationGracePeriodSeconds: int64Ptr(terminationGracePeriod),
 					Containers: []corev1.Container{
 						// Runner container now exposes the app port AND the internal gRPC port
 						{
 							Name:            "runner",
 							Image:           fmt.Sprintf("ghcr.io/parthkapoor-dev/devex/runner-%s:latest", template),
 							ImagePullPolicy: corev1.PullAlways,
-							Env: []corev1.EnvVar{
+							Env: append([]corev1.EnvVar{
 								{
 									Name:  "REPL_ID",
 									Value: replId,
@@ -89,7 +92,29 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 									Name:  "TEMPLATE",
 									Value: template,
 								},
-							},
+								// Where users reach the runner through the ingress, for preview URLs
+								{
+									Name:  "PUBLIC_URL",
+									Value: fmt.Sprintf("https://%s/%s", RUNNER_CLUSTER_IP, replId),
+								},
+								{
+									Name:  "PREVIEW_DOMAIN",
+									Value: PREVIEW_DOMAIN,
+								},
+								{
+									Name:  "FRONTEND_URL",
+									Value: FRONTEND_URL,
+								},
+								// Verifies the access tokens core gives the users of this repl
+								{
+									Name:  "REPL_TOKEN_KEY",
+									Value: tokens.KeyHex(replId),
+								},
+								{
+									Name:  "REPL_TOKEN_REVOCATIONS",
+									Value: tokens.Revocations(revocations),
+								},
+							}, storageEnvVars(userName, replId)...),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "workspace-vol",
@@ -102,12 +127,6 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 									Name:          "http",
 									ContainerPort: config.Port,
 								},
-								// Port for internal gRPC communication, acting as the server
-								{
-									Name:          "grpc",
-									ContainerPort: 50051,
-									Protocol:      corev1.ProtocolTCP,
-								},
 							},
 						},
 						// MCP container now exposes its own HTTP port for external access
@@ -124,8 +143,17 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 									Name:  "TEMPLATE",
 									Value: template,
 								},
+								{
+									Name:  "REPL_TOKEN_KEY",
+									Value: tokens.KeyHex(replId),
+								},
+								{
+									Name:  "REPL_TOKEN_REVOCATIONS",
+									Value: tokens.Revocations(revocations),
+								},
 								// NOTE: The mcp-server (gRPC client) will connect to the runner (gRPC server)
-								// on localhost:50051 as they are in the same Pod.
+								// on 127.0.0.1:50051 as they are in the same Pod, the runner does
+								// not listen on the Pod's IP.
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -148,8 +176,8 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 		},
 	}
 
-	_, err := clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
-	if err != nil {
+	_, err = clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
+	if err := ignoreAlreadyExists("Deployment", replId, err); err != nil {
 		return fmt.Errorf("failed to create deployment: %w", err)
 	}
 
@@ -174,20 +202,13 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 					TargetPort: intstr.FromInt(8080),
 					Protocol:   corev1.ProtocolTCP,
 				},
-				// Port for the internal gRPC communication
-				{
-					Name:       "grpc",
-					Port:       50051,
-					TargetPort: intstr.FromInt(50051),
-					Protocol:   corev1.ProtocolTCP,
-				},
 			},
 			Type: corev1.ServiceTypeClusterIP,
 		},
 	}
 
 	_, err = clientset.CoreV1().Services("default").Create(ctx, service, metav1.CreateOptions{})
-	if err != nil {
+	if err := ignoreAlreadyExists("Service", replId, err); err != nil {
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 
@@ -202,6 +223,8 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 				"nginx.ingress.kubernetes.io/ssl-redirect":       "false",
 				"nginx.ingress.kubernetes.io/proxy-read-timeout": "3600",
 				"nginx.ingress.kubernetes.io/proxy-send-timeout": "3600",
+				// Tells the apps behind the runner's /user-app/ proxy where they are served
+				"nginx.ingress.kubernetes.io/x-forwarded-prefix": "/" + replId,
 			},
 		},
 		Spec: networkingv1.IngressSpec{
@@ -253,10 +276,72 @@ func CreateReplDeploymentAndService(userName, replId, template string) error {
 	}
 
 	_, err = clientset.NetworkingV1().Ingresses("default").Create(ctx, ingress, metav1.CreateOptions{})
-	if err != nil {
+	if err := ignoreAlreadyExists("Ingress", replId, err); err != nil {
 		return fmt.Errorf("failed to create ingress: %w", err)
 	}
 
+	// 4. Ingress of the previews on their own hosts
+	if PREVIEW_DOMAIN != "" {
+		_, err = clientset.NetworkingV1().Ingresses("default").Create(ctx, previewIngress(replId, config.Port), metav1.CreateOptions{})
+		if err := ignoreAlreadyExists("Ingress", replId+"-preview", err); err != nil {
+			return fmt.Errorf("failed to create preview ingress: %w", err)
+		}
+	}
+
 	log.Printf("✅ Deployment and Service for repl %s (template: %s) created with MCP sidecar.\n", replId, template)
 	return nil
 }
+
+// previewIngress routes <port>-<replId>.<PREVIEW_DOMAIN> to the runner, which
+// picks the app's port from the Host. Ingress hosts only take a wildcard as
+// their first label, so the repl gets <replId>.<PREVIEW_DOMAIN> with a regex
+// alias for the ports, all under the *.<PREVIEW_DOMAIN> certificate.
+func previewIngress(replId string, port int32) *networkingv1.Ingress {
+	host := fmt.Sprintf("%s.%s", replId, PREVIEW_DOMAIN)
+	alias := fmt.Sprintf(`~^[0-9]+-%s\.%s$`, regexp.QuoteMeta(replId), regexp.QuoteMeta(PREVIEW_DOMAIN))
+
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: replId + "-preview",
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/server-alias":       alias,
+				"nginx.ingress.kubernetes.io/websocket-services": replId,
+				"nginx.ingress.kubernetes.io/ssl-redirect":       "false",
+				"nginx.ingress.kubernetes.io/proxy-read-timeout": "3600",
+				"nginx.ingress.kubernetes.io/proxy-send-timeout": "3600",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: strPtr("nginx"),
+			TLS: []networkingv1.IngressTLS{
+				{
+					Hosts:      []string{host},
+					SecretName: PREVIEW_TLS_SECRET,
+				},
+			},
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: pathTypePtr(networkingv1.PathTypePrefix),
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: replId,
+											Port: networkingv1.ServiceBackendPort{
+												Number: port,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -2,34 +2,30 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"core/pkg/dotenv"
-
-	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// DeleteReplDeploymentAndService tears the repl's resources down. The runner
+// uploads the workspace when its pod receives SIGTERM, so this waits for the
+// pod to be gone before reporting the repl as stopped.
 func DeleteReplDeploymentAndService(userName, replId string) error {
-	clientset, _ := getClientSet()
+	clientset, err := getClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to load k8s client: %w", err)
+	}
 	ctx := context.Background()
 
-	region := dotenv.EnvString("SPACES_REGION", "blr1")
-	bucket := dotenv.EnvString("SPACES_BUCKET", "devex")
+	foreground := metav1.DeletePropagationForeground
 
-	// Step 1: Upload workspace from pod to DigitalOcean Spaces
-	log.Println("📤 Uploading workspace from pod to DigitalOcean Spaces...")
-
-	if err := InjectEphemeralUploader(clientset, ctx, replId, userName, region, bucket); err != nil {
-		log.Printf("⚠️ Failed to inject uploader: %v", err)
-	} else {
-		log.Printf("✅ Uploaded /workspaces to s3://devex/repl/%s/%s/", userName, replId)
-	}
-
-	// Step 2: Delete resources
+	// Delete resources (already deleted counts as success)
+	var errs []error
 	for _, resource := range []struct {
 		name string
 		del  func() error
@@ -40,6 +36,12 @@ func DeleteReplDeploymentAndService(userName, replId string) error {
 				return clientset.NetworkingV1().Ingresses("default").Delete(ctx, replId+"-ingress", metav1.DeleteOptions{})
 			},
 		},
+		{
+			name: "Preview Ingress",
+			del: func() error {
+				return clientset.NetworkingV1().Ingresses("default").Delete(ctx, replId+"-preview", metav1.DeleteOptions{})
+			},
+		},
 		{
 			name: "Service",
 			del: func() error {
@@ -49,98 +51,52 @@ func DeleteReplDeploymentAndService(userName, replId string) error {
 		{
 			name: "Deployment",
 			del: func() error {
-				return clientset.AppsV1().Deployments("default").Delete(ctx, replId, metav1.DeleteOptions{})
+				return clientset.AppsV1().Deployments("default").Delete(ctx, replId, metav1.DeleteOptions{
+					PropagationPolicy: &foreground,
+				})
 			},
 		},
 	} {
 		err := resource.del()
-		if err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Printf("✅ %s for repl %s was already deleted", resource.name, replId)
+		case err != nil:
 			log.Printf("⚠️ Failed to delete %s: %v", resource.name, err)
-		} else {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", resource.name, err))
+		default:
 			log.Printf("✅ %s deleted for repl %s", resource.name, replId)
 		}
 	}
-
-	return nil
-}
-
-// InjectEphemeralUploader injects an ephemeral container into the running REPL pod to upload files
-func InjectEphemeralUploader(clientset *kubernetes.Clientset, ctx context.Context, replId, userName, region, bucket string) error {
-	const namespace = "default"
-
-	// Fetch the target pod
-	podList, err := clientset.CoreV1().Pods("default").List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("app=%s", replId),
-	})
-	if err != nil || len(podList.Items) == 0 {
-		return err
-	}
-	pod := podList.Items[0]
-
-	// Prepare the ephemeral container spec
-	ephemeral := corev1.EphemeralContainer{
-		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
-			Name:    "s3-uploader",
-			Image:   "amazon/aws-cli",
-			Command: []string{"sh", "-c"},
-			Args: []string{
-				fmt.Sprintf(`aws s3 cp /workspaces s3://%s/repl/%s/%s/ --recursive --endpoint-url https://%s.digitaloceanspaces.com`, bucket, userName, replId, region),
-			},
-			VolumeMounts: []corev1.VolumeMount{
-				{
-					Name:      "workspace-vol",
-					MountPath: "/workspaces",
-				},
-			},
-			Env: awsEnvVars(),
-		},
-		// TargetContainerName: "runner", // optional but helps in debugging
-	}
-
-	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ephemeral)
-
-	if _, err = clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(
-		ctx, pod.Name, &pod, metav1.UpdateOptions{}); err != nil {
-		return fmt.Errorf("failed to update pod with ephemeral container: %w", err)
-	}
-	log.Printf("📦 Ephemeral uploader injected into pod %s", pod.Name)
-
-	if err := waitForEphemeralUpload(clientset, pod.Name); err != nil {
+	if err := errors.Join(errs...); err != nil {
 		return err
 	}
 
-	return nil
+	log.Printf("⏳ Waiting for the runner of repl %s to upload the workspace and exit...", replId)
+	return waitForPodsGone(clientset, replId)
 }
 
-func waitForEphemeralUpload(clientset *kubernetes.Clientset, podName string) error {
-	const (
-		namespace = "default"
-		timeout   = 2 * time.Minute
-		interval  = 2 * time.Second
-	)
+// waitForPodsGone polls until no pod of the repl is left. A pod that is still
+// terminating may still be uploading the workspace.
+func waitForPodsGone(clientset *kubernetes.Clientset, replId string) error {
+	const interval = 2 * time.Second
+	timeout := (terminationGracePeriod + 30) * time.Second
 
 	start := time.Now()
 	for time.Since(start) < timeout {
-		pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		podList, err := clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("app=%s", replId),
+		})
 		if err != nil {
-			return fmt.Errorf("failed to get updated pod: %w", err)
+			return fmt.Errorf("failed to list pods: %w", err)
 		}
-
-		for _, ec := range pod.Status.EphemeralContainerStatuses {
-			if ec.Name == "s3-uploader" {
-				if ec.State.Terminated != nil {
-					if ec.State.Terminated.ExitCode == 0 {
-						log.Println("✅ Ephemeral container finished successfully")
-						return nil
-					}
-					return fmt.Errorf("ephemeral container failed with code %d", ec.State.Terminated.ExitCode)
-				}
-			}
+		if len(podList.Items) == 0 {
+			log.Printf("✅ Pods of repl %s are gone", replId)
+			return nil
 		}
 
-		log.Println("⏳ Waiting for ephemeral uploader to complete...")
 		time.Sleep(interval)
 	}
 
-	return fmt.Errorf("timeout: ephemeral container did not finish in time")
+	return fmt.Errorf("timeout: pods of repl %s are still terminating", replId)
 }
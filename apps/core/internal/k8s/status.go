@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplPodStatus summarises the Deployment of a repl
+type ReplPodStatus struct {
+	// Exists is false once the Deployment has been deleted
+	Exists bool
+	Ready  bool
+	// Message explains why the pod is not ready, if it is known
+	Message string
+}
+
+// GetReplStatus reports whether the repl's Deployment exists and is ready
+func GetReplStatus(ctx context.Context, replId string) (ReplPodStatus, error) {
+	clientset, err := getClientSet()
+	if err != nil {
+		return ReplPodStatus{}, fmt.Errorf("failed to load k8s client: %w", err)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments("default").Get(ctx, replId, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ReplPodStatus{}, nil
+	}
+	if err != nil {
+		return ReplPodStatus{}, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	status := ReplPodStatus{
+		Exists: true,
+		Ready:  deployment.Status.ReadyReplicas > 0,
+	}
+	if status.Ready {
+		return status, nil
+	}
+
+	pod, err := replPod(ctx, replId)
+	if err != nil {
+		return status, nil
+	}
+	for _, progress := range podProgress(pod) {
+		if progress.Err != nil {
+			status.Message = progress.Err.Error()
+			break
+		}
+		status.Message = progress.Message
+	}
+
+	return status, nil
+}
+
+// StreamReplLogs streams the logs of one container of the repl's pod
+func StreamReplLogs(ctx context.Context, replId, container string, follow bool, tailLines int64) (io.ReadCloser, error) {
+	clientset, err := getClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load k8s client: %w", err)
+	}
+
+	pod, err := replPod(ctx, replId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+	}
+	if tailLines > 0 {
+		opts.TailLines = &tailLines
+	}
+
+	return clientset.CoreV1().Pods("default").GetLogs(pod.Name, opts).Stream(ctx)
+}
+
+func replPod(ctx context.Context, replId string) (*corev1.Pod, error) {
+	clientset, err := getClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load k8s client: %w", err)
+	}
+
+	podList, err := clientset.CoreV1().Pods("default").List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", replId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	if len(podList.Items) == 0 {
+		return nil, fmt.Errorf("no pod found for repl %s", replId)
+	}
+
+	return &podList.Items[0], nil
+}
@@ -6,10 +6,12 @@ import (
 
 	"path/filepath"
 
+	"core/internal/workspace"
 	"core/pkg/dotenv"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
@@ -52,6 +54,16 @@ func CheckStatus() (bool, error) {
 	return true, nil
 }
 
+// ignoreAlreadyExists makes resource creation idempotent: a resource left
+// behind by an earlier (possibly interrupted) activation is reused as is.
+func ignoreAlreadyExists(kind, replId string, err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		log.Printf("✅ %s for repl %s already exists, reusing it", kind, replId)
+		return nil
+	}
+	return err
+}
+
 // Utility functions
 func int32Ptr(i int32) *int32 {
 	return &i
@@ -69,29 +81,26 @@ func strPtr(s string) *string {
 	return &s
 }
 
-func awsEnvVars() []corev1.EnvVar {
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+// storageEnvVars tell the runner where to sync the repl's workspace. It gets
+// no storage credentials: core presigns its requests, for the keys of this
+// repl only, see services/runner.
+func storageEnvVars(userName, replId string) []corev1.EnvVar {
 	return []corev1.EnvVar{
 		{
-			Name: "AWS_ACCESS_KEY_ID",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: "aws-creds",
-					},
-					Key: "access_key",
-				},
-			},
+			Name:  "STORAGE_PROVIDER",
+			Value: "presigned",
+		},
+		{
+			Name:  "STORAGE_PREFIX",
+			Value: workspace.ReplPrefix(userName, replId),
 		},
 		{
-			Name: "AWS_SECRET_ACCESS_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: "aws-creds",
-					},
-					Key: "secret_key",
-				},
-			},
+			Name:  "CHECKPOINT_PREFIX",
+			Value: workspace.CheckpointPrefix(userName, replId),
 		},
 	}
 }
@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Stages reported by WatchReplPod, in the order a healthy pod goes through them
+const (
+	StagePodScheduled     = "podScheduled"
+	StagePullingImage     = "pullingImage"
+	StageContainerStarted = "containerStarted"
+)
+
+// PodProgress is a startup milestone (or failure) of a repl's pod
+type PodProgress struct {
+	Stage   string
+	Message string
+	// Err is set when the pod can not come up without intervention
+	Err error
+}
+
+// Waiting reasons after which the pod will not start on its own
+var fatalWaitingReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CrashLoopBackOff":           true,
+}
+
+// WatchReplPod follows the pod of a repl's Deployment and reports its startup
+// milestones until ctx is cancelled or the pod fails.
+func WatchReplPod(ctx context.Context, replId string, onProgress func(PodProgress)) error {
+	clientset, err := getClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to load k8s client: %w", err)
+	}
+
+	watcher, err := clientset.CoreV1().Pods("default").Watch(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", replId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch pod: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+
+			for _, progress := range podProgress(pod) {
+				onProgress(progress)
+				if progress.Err != nil {
+					log.Printf("⚠️ Pod %s of repl %s failed: %v", pod.Name, replId, progress.Err)
+					return progress.Err
+				}
+			}
+		}
+	}
+}
+
+// podProgress derives the milestones reached by a pod from its status
+func podProgress(pod *corev1.Pod) []PodProgress {
+	var progress []PodProgress
+
+	if pod.Status.Phase == corev1.PodFailed {
+		return append(progress, PodProgress{Err: fmt.Errorf("pod failed: %s", pod.Status.Message)})
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue {
+			progress = append(progress, PodProgress{Stage: StagePodScheduled, Message: "Pod scheduled on " + pod.Spec.NodeName})
+		}
+	}
+
+	started := len(pod.Status.ContainerStatuses) > 0
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil {
+			started = false
+			if fatalWaitingReasons[waiting.Reason] {
+				return append(progress, PodProgress{Err: fmt.Errorf("container %s: %s: %s",
+					status.Name, waiting.Reason, waiting.Message)})
+			}
+			// PodInitializing / ContainerCreating is where images are pulled
+			progress = append(progress, PodProgress{Stage: StagePullingImage, Message: "Pulling image " + status.Image})
+		}
+		if status.State.Terminated != nil {
+			started = false
+		}
+	}
+	if started {
+		progress = append(progress, PodProgress{Stage: StageContainerStarted, Message: "Containers started"})
+	}
+
+	return progress
+}
@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"core/internal/tokens"
+	"core/internal/workspace"
+	"core/models"
+	"packages/checkpoint"
+)
+
+// CopyWorkspace copies the files of a repl below dst. A running repl takes a
+// checkpoint first and the copy is made from it, so that it is consistent
+// even while the user keeps working.
+func (m *Manager) CopyWorkspace(ctx context.Context, replId, dst string) error {
+	ctx, unlock, err := m.lock(ctx, replId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repl, err := m.store.GetRepl(replId)
+	if err != nil {
+		return err
+	}
+
+	switch repl.State {
+	case models.ReplRunning:
+		info, err := checkpointRunner(ctx, m.orch.Endpoint(replId).RunnerURL, replId)
+		if err != nil {
+			return err
+		}
+
+		prefix := workspace.CheckpointPrefix(repl.User, repl.Id)
+		manifest, err := checkpoint.Load(ctx, m.workspaces, prefix, info.Id)
+		if err != nil {
+			return err
+		}
+		return checkpoint.RestoreToPrefix(ctx, m.workspaces, prefix, manifest, "", dst)
+	case models.ReplStopped, models.ReplFailed:
+		return m.workspaces.CopyPrefix(ctx, workspace.ReplPrefix(repl.User, repl.Id), dst)
+	default:
+		return fmt.Errorf("%w: repl is %s", ErrBusy, repl.State)
+	}
+}
+
+// checkpointRunner asks the runner for a checkpoint of its current files
+func checkpointRunner(ctx context.Context, runnerURL, replId string) (checkpoint.Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, runnerTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, runnerURL+"/api/v1/checkpoints", nil)
+	if err != nil {
+		return checkpoint.Info{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := tokens.Authorize(req, replId); err != nil {
+		return checkpoint.Info{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return checkpoint.Info{}, fmt.Errorf("runner checkpoint failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return checkpoint.Info{}, fmt.Errorf("runner checkpoint failed with status %d: %s", resp.StatusCode, message)
+	}
+
+	var info checkpoint.Info
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return checkpoint.Info{}, fmt.Errorf("invalid checkpoint from runner: %w", err)
+	}
+	return info, nil
+}
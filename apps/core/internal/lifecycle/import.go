@@ -0,0 +1,35 @@
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// importTimeout bounds a background import, from the GitHub lookup to the
+// repl being recorded
+const importTimeout = 10 * time.Minute
+
+// Import runs fn in the background to fill a repl that does not exist yet and
+// returns the Operation following it. fn reports its own progress, the
+// operation ends with StepImported when it succeeds and StepFailed otherwise.
+func (m *Manager) Import(replId, owner string, fn func(ctx context.Context, progress func(Step, string)) error) *Operation {
+	op := newOperation(replId)
+	op.Owner = owner
+	m.track(op)
+	op.publish(StepQueued, "Importing repository")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+		defer cancel()
+
+		if err := fn(ctx, op.publish); err != nil {
+			log.Printf("Import of repl %s failed: %v", replId, err)
+			op.publish(StepFailed, err.Error())
+			return
+		}
+		op.publish(StepImported, "Repository imported")
+	}()
+
+	return op
+}
@@ -0,0 +1,333 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"core/internal/orchestrator"
+	"core/internal/store"
+	"core/models"
+	"packages/storage"
+)
+
+const (
+	// lockTTL bounds how long a crashed core replica can block a repl
+	lockTTL = 30 * time.Second
+	// lockRefresh keeps the lease alive while an operation is running
+	lockRefresh = 10 * time.Second
+	// startTimeout bounds a background start, from orchestrator to runner ping
+	startTimeout = 3 * time.Minute
+)
+
+// ErrBusy is returned when another start/stop of the same repl is in flight
+var ErrBusy = errors.New("another operation is in progress for this repl")
+
+// errStartCancelled is the cause a start is cancelled with when the repl is
+// stopped while it is still coming up
+var errStartCancelled = errors.New("start was cancelled by a stop")
+
+// Manager starts and stops repls. Every operation holds a per-repl lock, so
+// double clicks and multiple core replicas cannot race each other, and both
+// operations are idempotent: starting a running repl or stopping a stopped
+// one is a no-op.
+type Manager struct {
+	store      store.ReplStore
+	locker     store.Locker
+	orch       orchestrator.Orchestrator
+	workspaces storage.WorkspaceStorage
+	ops        *operations
+
+	mu sync.Mutex
+	// starts are the background starts running on this core replica
+	starts map[string]*startInFlight
+}
+
+// startInFlight is a background start that a Stop can cancel
+type startInFlight struct {
+	cancel context.CancelCauseFunc
+	// done is closed once the start has given its lease up
+	done chan struct{}
+}
+
+func NewManager(replStore store.ReplStore, locker store.Locker, orch orchestrator.Orchestrator, workspaces storage.WorkspaceStorage) *Manager {
+	return &Manager{
+		store:      replStore,
+		locker:     locker,
+		orch:       orch,
+		workspaces: workspaces,
+		ops:        newOperations(),
+		starts:     make(map[string]*startInFlight),
+	}
+}
+
+// Start begins bringing the repl up in the background and returns the
+// Operation to follow its progress. Conflicts (another start/stop in flight,
+// invalid state) are reported synchronously.
+func (m *Manager) Start(ctx context.Context, replId string) (*Operation, error) {
+	repl, err := m.store.GetRepl(replId)
+	if err != nil {
+		return nil, err
+	}
+
+	op := newOperation(replId)
+
+	if repl.State == models.ReplRunning {
+		m.track(op)
+		op.publish(StepRunnerReady, "Repl is already running")
+		return op, nil
+	}
+
+	// The start goes on in the background after the request is done
+	leaseCtx, unlock, err := m.lock(context.WithoutCancel(ctx), replId)
+	if err != nil {
+		// Let clients that hit this replica follow the start in progress
+		if errors.Is(err, ErrBusy) {
+			if current, ok := m.ops.latest(replId); ok && !current.Done() {
+				return current, nil
+			}
+		}
+		return nil, err
+	}
+	m.track(op)
+
+	abort := func(err error) (*Operation, error) {
+		unlock()
+		op.publish(StepFailed, err.Error())
+		return nil, err
+	}
+
+	// Re-read under the lock, the previous holder may have finished the job
+	repl, err = m.store.GetRepl(replId)
+	if err != nil {
+		return abort(err)
+	}
+
+	switch repl.State {
+	case models.ReplRunning:
+		unlock()
+		op.publish(StepRunnerReady, "Repl is already running")
+		return op, nil
+	case models.ReplStarting:
+		// A previous start was interrupted (its lease expired), resume it
+	case models.ReplStopping:
+		// A previous stop was interrupted, record it and start over
+		if _, err = m.store.TransitionRepl(replId, models.ReplFailed, "previous stop was interrupted"); err != nil {
+			return abort(err)
+		}
+		fallthrough
+	default:
+		if _, err = m.store.TransitionRepl(replId, models.ReplStarting, ""); err != nil {
+			return abort(err)
+		}
+	}
+
+	op.publish(StepQueued, "Starting repl")
+
+	startCtx, cancel := context.WithCancelCause(leaseCtx)
+	inFlight := &startInFlight{cancel: cancel, done: make(chan struct{})}
+	m.mu.Lock()
+	m.starts[replId] = inFlight
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.starts, replId)
+			m.mu.Unlock()
+			unlock()
+			cancel(nil)
+			close(inFlight.done)
+		}()
+		m.start(startCtx, repl, op)
+	}()
+
+	return op, nil
+}
+
+// start does the actual work of Start, publishing progress to op. It gives
+// up when ctx is cancelled, by a Stop or because the lease was lost.
+func (m *Manager) start(ctx context.Context, repl models.Repl, op *Operation) {
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	if err := m.orch.Start(ctx, repl); err != nil {
+		log.Println("Orchestrator Start Failed", err)
+		m.abortStart(ctx, op, err)
+		return
+	}
+	op.publish(StepDeploymentCreated, "Repl resources created")
+
+	// Watch failures (image pull errors, crashing containers, runner
+	// process exiting, ...) abort the wait for the runner instead of letting
+	// it time out.
+	podErr := make(chan error, 1)
+	go func() {
+		err := m.orch.Watch(ctx, repl.Id, func(p orchestrator.Progress) {
+			if p.Err == nil {
+				op.publish(Step(p.Stage), p.Message)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			podErr <- err
+			cancel()
+		}
+	}()
+
+	if err := pingRunner(ctx, m.orch.Endpoint(repl.Id).RunnerURL+"/ping"); err != nil {
+		select {
+		case err = <-podErr:
+		default:
+		}
+		m.abortStart(ctx, op, err)
+		return
+	}
+
+	if _, err := m.store.TransitionRepl(repl.Id, models.ReplRunning, ""); err != nil {
+		m.failOperation(op, err)
+		return
+	}
+	op.publish(StepRunnerReady, "Runner is ready")
+}
+
+// abortStart fails a start. A start that lost its lease leaves the repl's
+// state alone, it belongs to whoever took the lock over.
+func (m *Manager) abortStart(ctx context.Context, op *Operation, err error) {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, store.ErrLockLost) {
+		log.Printf("⚠️ Start of repl %s lost its lock, giving up", op.ReplID)
+		op.publish(StepFailed, cause.Error())
+		return
+	}
+	if errors.Is(cause, errStartCancelled) {
+		err = cause
+	}
+	m.failOperation(op, err)
+}
+
+// track makes op visible to Operation / LatestOperation for a while
+func (m *Manager) track(op *Operation) {
+	m.ops.add(op)
+	m.ops.forget(op)
+}
+
+// Operation returns a start operation started by this core replica
+func (m *Manager) Operation(operationId string) (*Operation, bool) {
+	return m.ops.get(operationId)
+}
+
+// LatestOperation returns the last start operation of the repl on this replica
+func (m *Manager) LatestOperation(replId string) (*Operation, bool) {
+	return m.ops.latest(replId)
+}
+
+// Endpoint returns where the repl's runner and mcp server can be reached
+func (m *Manager) Endpoint(replId string) orchestrator.Endpoint {
+	return m.orch.Endpoint(replId)
+}
+
+// Stop uploads the workspace and tears the repl's resources down
+func (m *Manager) Stop(ctx context.Context, replId string) (models.Repl, error) {
+	repl, err := m.store.GetRepl(replId)
+	if err != nil || repl.State == models.ReplStopped {
+		return repl, err
+	}
+
+	_, unlock, err := m.lock(ctx, replId)
+	if errors.Is(err, ErrBusy) && m.cancelStart(ctx, replId) {
+		// The start gave its lease up, take it over
+		_, unlock, err = m.lock(ctx, replId)
+	}
+	if err != nil {
+		return repl, err
+	}
+	defer unlock()
+
+	repl, err = m.store.GetRepl(replId)
+	if err != nil {
+		return repl, err
+	}
+
+	switch repl.State {
+	case models.ReplStopped:
+		return repl, nil
+	case models.ReplStopping:
+		// A previous stop was interrupted, resume it
+	default:
+		if repl, err = m.store.TransitionRepl(replId, models.ReplStopping, ""); err != nil {
+			return repl, err
+		}
+	}
+
+	// The workspace upload has to finish even if the caller goes away
+	if err := m.orch.Stop(context.WithoutCancel(ctx), repl); err != nil {
+		log.Println("Orchestrator Stop Failed", err)
+		return m.fail(replId, err)
+	}
+
+	return m.store.TransitionRepl(replId, models.ReplStopped, "")
+}
+
+// cancelStart cancels the start of the repl running on this core replica,
+// if any, and waits until it has given its lease up. Starts running on
+// other replicas can not be cancelled, Stop reports ErrBusy for them.
+func (m *Manager) cancelStart(ctx context.Context, replId string) bool {
+	m.mu.Lock()
+	inFlight, ok := m.starts[replId]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	inFlight.cancel(errStartCancelled)
+	select {
+	case <-inFlight.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// lock takes the per-repl lease and keeps it alive until unlock is called.
+// The returned context is derived from ctx and is cancelled with
+// store.ErrLockLost as its cause if the lease is lost.
+func (m *Manager) lock(ctx context.Context, replId string) (leaseCtx context.Context, unlock func(), err error) {
+	lease, err := m.locker.Acquire(ctx, "repl:"+replId, lockTTL)
+	if errors.Is(err, store.ErrLockHeld) {
+		return nil, nil, ErrBusy
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock repl: %w", err)
+	}
+
+	kept, stop := store.KeepAlive(lease, lockRefresh)
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	stopLost := context.AfterFunc(kept, func() { cancel(context.Cause(kept)) })
+
+	return leaseCtx, func() {
+		stopLost()
+		cancel(nil)
+		stop()
+		if err := lease.Release(context.Background()); err != nil {
+			log.Printf("⚠️ Failed to release lock for repl %s: %v", replId, err)
+		}
+	}, nil
+}
+
+// failOperation marks both the repl and the operation as failed
+func (m *Manager) failOperation(op *Operation, err error) {
+	m.fail(op.ReplID, err)
+	op.publish(StepFailed, err.Error())
+}
+
+// fail moves the repl to the failed state, keeping err as its last error
+func (m *Manager) fail(replId string, err error) (models.Repl, error) {
+	repl, tErr := m.store.TransitionRepl(replId, models.ReplFailed, err.Error())
+	if tErr != nil {
+		log.Printf("Unable to mark repl %s as failed: %v", replId, tErr)
+	}
+	return repl, err
+}
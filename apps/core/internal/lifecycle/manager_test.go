@@ -0,0 +1,208 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"core/internal/orchestrator"
+	"core/internal/store"
+	"core/models"
+)
+
+// fakeOrchestrator runs nothing, its runner is an httptest server
+type fakeOrchestrator struct {
+	runnerURL string
+	// block makes Start wait until its context is done
+	block bool
+
+	mu      sync.Mutex
+	started int
+	stopped int
+}
+
+func (f *fakeOrchestrator) Start(ctx context.Context, repl models.Repl) error {
+	f.mu.Lock()
+	f.started++
+	f.mu.Unlock()
+
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (f *fakeOrchestrator) Watch(ctx context.Context, replId string, onProgress func(orchestrator.Progress)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeOrchestrator) Stop(ctx context.Context, repl models.Repl) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped++
+	return nil
+}
+
+func (f *fakeOrchestrator) Status(ctx context.Context, replId string) (orchestrator.Status, error) {
+	return orchestrator.Status{}, nil
+}
+
+func (f *fakeOrchestrator) Logs(ctx context.Context, replId string, opts orchestrator.LogOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f *fakeOrchestrator) Endpoint(replId string) orchestrator.Endpoint {
+	return orchestrator.Endpoint{RunnerURL: f.runnerURL}
+}
+
+func (f *fakeOrchestrator) Ping(ctx context.Context) error {
+	return nil
+}
+
+func newTestManager(t *testing.T, orch *fakeOrchestrator) (*Manager, *store.MemoryStore) {
+	t.Helper()
+
+	runner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "\"pong\"\n")
+	}))
+	t.Cleanup(runner.Close)
+	orch.runnerURL = runner.URL
+
+	replStore := store.NewMemoryStore()
+	if err := replStore.CreateRepl("node", "octocat", "demo", "repl-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(replStore, store.NewMemoryLocker(), orch, nil), replStore
+}
+
+// waitOperation returns the last event of op once it has finished
+func waitOperation(t *testing.T, op *Operation) Event {
+	t.Helper()
+
+	_, updates, cancel := op.Subscribe()
+	defer cancel()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-updates:
+			if !ok {
+				return op.Last()
+			}
+		case <-timeout:
+			t.Fatalf("operation did not finish, last step %s", op.Last().Step)
+		}
+	}
+}
+
+func TestManagerStartStop(t *testing.T) {
+	ctx := context.Background()
+	orch := &fakeOrchestrator{}
+	m, replStore := newTestManager(t, orch)
+
+	op, err := m.Start(ctx, "repl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := waitOperation(t, op); last.Step != StepRunnerReady {
+		t.Fatalf("last step = %s (%s), want %s", last.Step, last.Message, StepRunnerReady)
+	}
+	if repl, _ := replStore.GetRepl("repl-1"); repl.State != models.ReplRunning {
+		t.Fatalf("state after Start() = %s, want %s", repl.State, models.ReplRunning)
+	}
+
+	// Starting a running repl is a no-op
+	op, err = m.Start(ctx, "repl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := op.Last(); last.Step != StepRunnerReady || orch.started != 1 {
+		t.Errorf("second Start() step = %s with %d orchestrator starts, want %s with 1", last.Step, orch.started, StepRunnerReady)
+	}
+
+	repl, err := m.Stop(ctx, "repl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repl.State != models.ReplStopped || orch.stopped != 1 {
+		t.Errorf("Stop() state = %s with %d orchestrator stops, want %s with 1", repl.State, orch.stopped, models.ReplStopped)
+	}
+
+	// Stopping a stopped repl is a no-op too
+	if _, err := m.Stop(ctx, "repl-1"); err != nil || orch.stopped != 1 {
+		t.Errorf("second Stop() error = %v with %d orchestrator stops, want none and 1", err, orch.stopped)
+	}
+}
+
+func TestManagerStopCancelsStart(t *testing.T) {
+	ctx := context.Background()
+	orch := &fakeOrchestrator{block: true}
+	m, _ := newTestManager(t, orch)
+
+	op, err := m.Start(ctx, "repl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	repl, err := m.Stop(stopCtx, "repl-1")
+	if err != nil {
+		t.Fatalf("Stop() during a start error = %v", err)
+	}
+	if repl.State != models.ReplStopped {
+		t.Errorf("state after Stop() = %s, want %s", repl.State, models.ReplStopped)
+	}
+
+	last := waitOperation(t, op)
+	if last.Step != StepFailed || last.Message != errStartCancelled.Error() {
+		t.Errorf("start operation ended with %s (%s), want %s (%s)", last.Step, last.Message, StepFailed, errStartCancelled)
+	}
+}
+
+func TestManagerStopBusy(t *testing.T) {
+	ctx := context.Background()
+	m, replStore := newTestManager(t, &fakeOrchestrator{})
+	replStore.TransitionRepl("repl-1", models.ReplStarting, "")
+
+	// A start held by another core replica can not be cancelled from here
+	lease, err := m.locker.Acquire(ctx, "repl:repl-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release(ctx)
+
+	if _, err := m.Stop(ctx, "repl-1"); !errors.Is(err, ErrBusy) {
+		t.Errorf("Stop() error = %v, want %v", err, ErrBusy)
+	}
+}
+
+func TestManagerStartLostLease(t *testing.T) {
+	m, replStore := newTestManager(t, &fakeOrchestrator{block: true})
+	repl, err := replStore.TransitionRepl("repl-1", models.ReplStarting, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(store.ErrLockLost)
+
+	op := newOperation("repl-1")
+	m.start(ctx, repl, op)
+
+	if last := op.Last(); last.Step != StepFailed || last.Message != store.ErrLockLost.Error() {
+		t.Errorf("operation ended with %s (%s), want %s (%s)", last.Step, last.Message, StepFailed, store.ErrLockLost)
+	}
+	// The repl belongs to whoever took the lock over
+	if repl, _ := replStore.GetRepl("repl-1"); repl.State != models.ReplStarting {
+		t.Errorf("state = %s, want %s", repl.State, models.ReplStarting)
+	}
+}
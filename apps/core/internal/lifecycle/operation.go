@@ -0,0 +1,214 @@
+package lifecycle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operations are kept around for late SSE subscribers
+const operationRetention = 10 * time.Minute
+
+// Step is a stage of a background repl operation
+type Step string
+
+const (
+	StepQueued            Step = "queued"
+	StepDeploymentCreated Step = "deploymentCreated"
+	StepPodScheduled      Step = "podScheduled"
+	StepPullingImage      Step = "pullingImage"
+	StepContainerStarted  Step = "containerStarted"
+	StepRunnerReady       Step = "runnerReady"
+	StepFailed            Step = "failed"
+)
+
+// Steps of a GitHub import, see githubrepo.Import
+const (
+	StepResolvingRepository Step = "resolvingRepository"
+	StepDetectingTemplate   Step = "detectingTemplate"
+	StepUploadingFiles      Step = "uploadingFiles"
+	StepImported            Step = "imported"
+)
+
+// stepProgress is the rough percentage shown for each step
+var stepProgress = map[Step]int{
+	StepQueued:            0,
+	StepDeploymentCreated: 10,
+	StepPodScheduled:      20,
+	StepPullingImage:      35,
+	StepContainerStarted:  55,
+	StepRunnerReady:       100,
+	StepFailed:            100,
+
+	StepResolvingRepository: 10,
+	StepDetectingTemplate:   25,
+	StepUploadingFiles:      40,
+	StepImported:            100,
+}
+
+// Event is a progress update of an Operation
+type Event struct {
+	Step     Step      `json:"step"`
+	Message  string    `json:"message,omitempty"`
+	Progress int       `json:"progress"`
+	Time     time.Time `json:"time"`
+}
+
+// Operation is a background repl activation (or import) whose progress can
+// be followed
+type Operation struct {
+	ID     string `json:"operationId"`
+	ReplID string `json:"replId"`
+	// Owner is the user who started an operation on a repl that is not in
+	// the store yet, empty for activations
+	Owner string `json:"-"`
+
+	mu          sync.Mutex
+	events      []Event
+	done        bool
+	subscribers map[chan Event]struct{}
+}
+
+func newOperation(replId string) *Operation {
+	return &Operation{
+		ID:          "op-" + uuid.NewString(),
+		ReplID:      replId,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// publish records the event and fans it out to subscribers. Steps never go
+// backwards, so repeated or stale pod watch events are dropped.
+func (o *Operation) publish(step Step, message string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.done {
+		return
+	}
+	if n := len(o.events); n > 0 && step != StepFailed && stepProgress[step] <= o.events[n-1].Progress {
+		return
+	}
+
+	event := Event{
+		Step:     step,
+		Message:  message,
+		Progress: stepProgress[step],
+		Time:     time.Now(),
+	}
+	o.events = append(o.events, event)
+
+	for ch := range o.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// A slow subscriber only misses intermediate steps, it can
+			// still read the final one with Last once the channel closes.
+		}
+	}
+
+	if step == StepRunnerReady || step == StepImported || step == StepFailed {
+		o.finish()
+	}
+}
+
+func (o *Operation) finish() {
+	o.done = true
+	for ch := range o.subscribers {
+		close(ch)
+	}
+	o.subscribers = nil
+}
+
+// Subscribe returns the events published so far and a channel with the ones
+// that follow. The channel is closed once the operation has finished.
+func (o *Operation) Subscribe() (history []Event, updates <-chan Event, cancel func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	history = append([]Event(nil), o.events...)
+	ch := make(chan Event, 16)
+
+	if o.done {
+		close(ch)
+		return history, ch, func() {}
+	}
+
+	o.subscribers[ch] = struct{}{}
+	return history, ch, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.subscribers[ch]; ok {
+			delete(o.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Done reports whether the operation has finished (ready or failed)
+func (o *Operation) Done() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.done
+}
+
+// Last returns the latest event of the operation
+func (o *Operation) Last() Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.events) == 0 {
+		return Event{Step: StepQueued}
+	}
+	return o.events[len(o.events)-1]
+}
+
+// operations indexes the operations started by this core replica
+type operations struct {
+	mu     sync.RWMutex
+	byId   map[string]*Operation
+	byRepl map[string]*Operation
+}
+
+func newOperations() *operations {
+	return &operations{
+		byId:   make(map[string]*Operation),
+		byRepl: make(map[string]*Operation),
+	}
+}
+
+func (ops *operations) add(op *Operation) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	ops.byId[op.ID] = op
+	ops.byRepl[op.ReplID] = op
+}
+
+// forget drops the operation once late subscribers had a chance to read it
+func (ops *operations) forget(op *Operation) {
+	time.AfterFunc(operationRetention, func() {
+		ops.mu.Lock()
+		defer ops.mu.Unlock()
+
+		delete(ops.byId, op.ID)
+		if ops.byRepl[op.ReplID] == op {
+			delete(ops.byRepl, op.ReplID)
+		}
+	})
+}
+
+func (ops *operations) get(id string) (*Operation, bool) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+	op, ok := ops.byId[id]
+	return op, ok
+}
+
+func (ops *operations) latest(replId string) (*Operation, bool) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+	op, ok := ops.byRepl[replId]
+	return op, ok
+}
@@ -1,6 +1,8 @@
-package repl
+package lifecycle
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,15 +11,18 @@ import (
 )
 
 // Ping the Runner Service to check whether the container is running or initiating.
-func pingRunner(url string) error {
-	timeout := time.After(1 * time.Minute)
+// It keeps polling until the runner answers or ctx is done.
+func pingRunner(ctx context.Context, url string) error {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-timeout:
-			return fmt.Errorf("timeout: no 'pong' response received from %s", url)
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timeout: no 'pong' response received from %s", url)
+			}
+			return ctx.Err()
 
 		case <-ticker.C:
 			resp, err := http.Get(url)
@@ -0,0 +1,95 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"core/internal/tokens"
+	"core/internal/workspace"
+	"core/models"
+	"packages/checkpoint"
+)
+
+// runnerTimeout bounds checkpoints and restores done by a running repl's runner
+const runnerTimeout = 2 * time.Minute
+
+// Restore brings the workspace of a repl, or the file or folder p in it, back
+// to a checkpoint. A running repl restores through its runner so that open
+// editors and terminals see the files change; for a stopped repl the
+// workspace is restored in storage directly.
+func (m *Manager) Restore(ctx context.Context, replId, checkpointId, p string) error {
+	ctx, unlock, err := m.lock(ctx, replId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repl, err := m.store.GetRepl(replId)
+	if err != nil {
+		return err
+	}
+
+	// Catch unknown checkpoints and paths before touching anything
+	prefix := workspace.CheckpointPrefix(repl.User, repl.Id)
+	manifest, err := checkpoint.Load(ctx, m.workspaces, prefix, checkpointId)
+	if err != nil {
+		return err
+	}
+	if _, err := manifest.Select(p); err != nil {
+		return err
+	}
+
+	switch repl.State {
+	case models.ReplRunning:
+		return restoreRunner(ctx, m.orch.Endpoint(replId).RunnerURL, replId, checkpointId, p)
+	case models.ReplStopped, models.ReplFailed:
+		if err := checkpoint.RestoreToPrefix(ctx, m.workspaces, prefix, manifest, p, workspace.ReplPrefix(repl.User, repl.Id)); err != nil {
+			return err
+		}
+		log.Printf("⏪ Restored repl %s to checkpoint %s", replId, checkpointId)
+		return nil
+	default:
+		return fmt.Errorf("%w: repl is %s", ErrBusy, repl.State)
+	}
+}
+
+// restoreRunner asks the runner to restore its workspace
+func restoreRunner(ctx context.Context, runnerURL, replId, checkpointId, p string) error {
+	ctx, cancel := context.WithTimeout(ctx, runnerTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]string{"path": p})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/checkpoints/%s/restore", runnerURL, url.PathEscape(checkpointId))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := tokens.Authorize(req, replId); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("runner restore failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("runner restore failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
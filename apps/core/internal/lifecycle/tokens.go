@@ -0,0 +1,71 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"core/internal/tokens"
+	"core/models"
+	"packages/replauth"
+)
+
+// revokeTimeout bounds a revocation by the runner or mcp server
+const revokeTimeout = 10 * time.Second
+
+// RevokeTokens has the runner and the mcp server of a repl reject tokens
+// before they expire. The revocation is kept with the repl and handed to
+// them on every start, and sent to them right away when the repl is up.
+func (m *Manager) RevokeTokens(ctx context.Context, replId string, req replauth.RevokeRequest) error {
+	repl, err := m.store.RevokeTokens(replId, req)
+	if err != nil {
+		return err
+	}
+	// A starting repl may have been given the revocations before this one
+	if repl.State != models.ReplRunning && repl.State != models.ReplStarting {
+		return nil
+	}
+
+	endpoint := m.orch.Endpoint(replId)
+	if err := revokeAt(ctx, endpoint.RunnerURL, replId, req); err != nil {
+		return fmt.Errorf("runner: %w", err)
+	}
+	if err := revokeAt(ctx, endpoint.McpURL, replId, req); err != nil {
+		return fmt.Errorf("mcp server: %w", err)
+	}
+	return nil
+}
+
+func revokeAt(ctx context.Context, baseURL, replId string, revoke replauth.RevokeRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, revokeTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(revoke)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v1/auth/revoke", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := tokens.Authorize(req, replId); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("token revocation failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("token revocation failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package oauth
+
+import (
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// RepoScope lets DevEx write to the user's repositories. It is only requested
+// when the user opts in, e.g. to export a repl to GitHub.
+const RepoScope = "repo"
+
+// AuthCodeURL is the GitHub consent page asking for the default scopes plus
+// extraScopes
+func AuthCodeURL(state string, extraScopes ...string) string {
+	if len(extraScopes) == 0 {
+		return GithubOauthConfig.AuthCodeURL(state)
+	}
+	scopes := append(append([]string{}, GithubOauthConfig.Scopes...), extraScopes...)
+	return GithubOauthConfig.AuthCodeURL(state, oauth2.SetAuthURLParam("scope", strings.Join(scopes, " ")))
+}
+
+// GrantedScopes returns the scopes GitHub granted with a token, which may
+// differ from the requested ones
+func GrantedScopes(token *oauth2.Token) []string {
+	granted, _ := token.Extra("scope").(string)
+	return strings.FieldsFunc(granted, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
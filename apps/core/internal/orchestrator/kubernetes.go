@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"core/internal/k8s"
+	"core/models"
+)
+
+var _ Orchestrator = (*Kubernetes)(nil)
+
+// Kubernetes runs every repl as a Deployment, Service and Ingress
+type Kubernetes struct{}
+
+func NewKubernetes() *Kubernetes {
+	return &Kubernetes{}
+}
+
+func (k *Kubernetes) Start(ctx context.Context, repl models.Repl) error {
+	return k8s.CreateReplDeploymentAndService(repl.User, repl.Id, repl.Template, repl.TokenRevocations)
+}
+
+func (k *Kubernetes) Watch(ctx context.Context, replId string, onProgress func(Progress)) error {
+	return k8s.WatchReplPod(ctx, replId, func(p k8s.PodProgress) {
+		onProgress(Progress{Stage: p.Stage, Message: p.Message, Err: p.Err})
+	})
+}
+
+func (k *Kubernetes) Stop(ctx context.Context, repl models.Repl) error {
+	return k8s.DeleteReplDeploymentAndService(repl.User, repl.Id)
+}
+
+func (k *Kubernetes) Status(ctx context.Context, replId string) (Status, error) {
+	status, err := k8s.GetReplStatus(ctx, replId)
+	if err != nil {
+		return Status{}, err
+	}
+
+	switch {
+	case !status.Exists:
+		return Status{State: StateStopped}, nil
+	case status.Ready:
+		return Status{State: StateRunning}, nil
+	default:
+		return Status{State: StateStarting, Message: status.Message}, nil
+	}
+}
+
+func (k *Kubernetes) Logs(ctx context.Context, replId string, opts LogOptions) (io.ReadCloser, error) {
+	container := "runner"
+	if opts.Container == ContainerMcp {
+		container = "mcp-server"
+	}
+
+	return k8s.StreamReplLogs(ctx, replId, container, opts.Follow, opts.TailLines)
+}
+
+// Endpoint points at the repl's paths on the shared ingress
+func (k *Kubernetes) Endpoint(replId string) Endpoint {
+	return Endpoint{
+		RunnerURL: fmt.Sprintf("https://%s/%s", k8s.RUNNER_CLUSTER_IP, replId),
+		McpURL:    fmt.Sprintf("https://%s/mcp/%s", k8s.RUNNER_CLUSTER_IP, replId),
+	}
+}
+
+func (k *Kubernetes) Ping(ctx context.Context) error {
+	_, err := k8s.CheckStatus()
+	return err
+}
@@ -0,0 +1,519 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"core/internal/tokens"
+	"core/internal/workspace"
+	"core/models"
+	"core/pkg/dotenv"
+	"packages/storage"
+)
+
+var (
+	LOCAL_RUNNER_BIN = dotenv.EnvString("LOCAL_RUNNER_BIN", "runner")
+	LOCAL_MCP_BIN    = dotenv.EnvString("LOCAL_MCP_BIN", "mcp")
+	// LOCAL_DATA_DIR holds the workspaces and logs of local repls
+	LOCAL_DATA_DIR = dotenv.EnvString("LOCAL_DATA_DIR", filepath.Join(os.TempDir(), "devex"))
+	// LOCAL_PROXY_ADDR serves /<replId>/ and /mcp/<replId>/ like the k8s ingress
+	LOCAL_PROXY_ADDR = dotenv.EnvString("LOCAL_PROXY_ADDR", "localhost:8081")
+	// LOCAL_PREVIEW_DOMAIN serves the apps of the repls on
+	// <port>-<replId>.<LOCAL_PREVIEW_DOMAIN> through the local proxy, e.g.
+	// localhost:8081 as browsers resolve *.localhost. Off when unset.
+	LOCAL_PREVIEW_DOMAIN = dotenv.EnvString("LOCAL_PREVIEW_DOMAIN", "")
+	// LOCAL_CORE_URL is handed to the runner for its shutdown callback
+	LOCAL_CORE_URL = dotenv.EnvString("LOCAL_CORE_URL", "http://localhost:"+dotenv.EnvString("PORT", "8080"))
+	// FRONTEND_URL is the editor, the only origin the runners answer CORS
+	// requests of
+	FRONTEND_URL = dotenv.EnvString("FRONTEND_URL", "http://localhost:3000")
+)
+
+const (
+	// stopGracePeriod is how long processes get to exit after SIGTERM, the
+	// runner uploads the workspace in the meantime
+	stopGracePeriod = 60 * time.Second
+	// logPollInterval is how often followed logs are checked for new lines
+	logPollInterval = 500 * time.Millisecond
+)
+
+// inheritedEnv are the only variables of core's environment that the runner
+// and mcp processes get. The rest holds core's secrets (token secret, OAuth,
+// database, ...), which the repl's users could read from their processes.
+var inheritedEnv = []string{"PATH", "HOME"}
+
+var _ Orchestrator = (*Local)(nil)
+
+// Local runs the runner and mcp binaries of every repl as child processes,
+// each with its own workspace directory and ports. It needs no cluster, so
+// the whole create → activate → edit → shutdown flow works on one machine.
+// Like in a pod, the runner syncs the workspace with storage itself.
+type Local struct {
+	workspaces storage.WorkspaceStorage
+
+	mu    sync.Mutex
+	repls map[string]*localRepl
+}
+
+type localRepl struct {
+	runnerPort int
+	mcpPort    int
+	runner     *process
+	mcp        *process
+}
+
+type process struct {
+	cmd     *exec.Cmd
+	logPath string
+	done    chan struct{}
+	err     error
+}
+
+func NewLocal(workspaces storage.WorkspaceStorage) *Local {
+	l := &Local{
+		workspaces: workspaces,
+		repls:      make(map[string]*localRepl),
+	}
+
+	go func() {
+		log.Println("Local repl proxy has started at", LOCAL_PROXY_ADDR)
+		if err := http.ListenAndServe(LOCAL_PROXY_ADDR, http.HandlerFunc(l.proxy)); err != nil {
+			log.Printf("❌ Local repl proxy stopped: %v", err)
+		}
+	}()
+
+	return l
+}
+
+func (l *Local) workspaceDir(replId string) string {
+	return filepath.Join(LOCAL_DATA_DIR, "workspaces", replId)
+}
+
+func (l *Local) logPath(replId, container string) string {
+	return filepath.Join(LOCAL_DATA_DIR, "logs", replId, container+".log")
+}
+
+func (l *Local) get(replId string) (*localRepl, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	repl, ok := l.repls[replId]
+	return repl, ok
+}
+
+func (l *Local) Start(ctx context.Context, repl models.Repl) error {
+	if current, ok := l.get(repl.Id); ok {
+		if current.runner.running() && current.mcp.running() {
+			return nil
+		}
+		// A half dead pair is restarted from scratch
+		current.stop()
+	}
+
+	// A workspace left behind by a runner that could not sync is newer than
+	// storage, save it before the new runner downloads the workspace again.
+	dir := l.workspaceDir(repl.Id)
+	if _, err := os.Stat(dir); err == nil {
+		log.Printf("📤 Saving workspace left behind by repl %s in %s", repl.Id, dir)
+		if err := storage.UploadDir(ctx, l.workspaces, dir, workspace.ReplPrefix(repl.User, repl.Id)); err != nil {
+			return fmt.Errorf("failed to save leftover workspace: %w", err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.logPath(repl.Id, ContainerRunner)), 0755); err != nil {
+		return err
+	}
+
+	storageEnv, err := l.storageEnv(repl)
+	if err != nil {
+		return err
+	}
+
+	ports, err := freePorts(3)
+	if err != nil {
+		return fmt.Errorf("failed to allocate ports: %w", err)
+	}
+	runnerPort, grpcPort, mcpPort := ports[0], ports[1], ports[2]
+
+	runner, err := startProcess(LOCAL_RUNNER_BIN, l.logPath(repl.Id, ContainerRunner), append(storageEnv,
+		"REPL_ID="+repl.Id,
+		"TEMPLATE="+repl.Template,
+		"PORT="+strconv.Itoa(runnerPort),
+		"GRPC_PORT="+strconv.Itoa(grpcPort),
+		"WORKSPACE_DIR="+dir,
+		"CORE_URL="+LOCAL_CORE_URL,
+		"PUBLIC_URL="+l.Endpoint(repl.Id).RunnerURL,
+		"PREVIEW_DOMAIN="+LOCAL_PREVIEW_DOMAIN,
+		"FRONTEND_URL="+FRONTEND_URL,
+		"REPL_TOKEN_KEY="+tokens.KeyHex(repl.Id),
+		"REPL_TOKEN_REVOCATIONS="+tokens.Revocations(repl.TokenRevocations),
+	)...)
+	if err != nil {
+		return fmt.Errorf("failed to start runner: %w", err)
+	}
+
+	mcp, err := startProcess(LOCAL_MCP_BIN, l.logPath(repl.Id, ContainerMcp),
+		"REPL_ID="+repl.Id,
+		"TEMPLATE="+repl.Template,
+		"PORT="+strconv.Itoa(mcpPort),
+		"RUNNER_GRPC_ADDR="+fmt.Sprintf("127.0.0.1:%d", grpcPort),
+		"REPL_TOKEN_KEY="+tokens.KeyHex(repl.Id),
+		"REPL_TOKEN_REVOCATIONS="+tokens.Revocations(repl.TokenRevocations),
+	)
+	if err != nil {
+		runner.stop()
+		return fmt.Errorf("failed to start mcp server: %w", err)
+	}
+
+	l.mu.Lock()
+	l.repls[repl.Id] = &localRepl{
+		runnerPort: runnerPort,
+		mcpPort:    mcpPort,
+		runner:     runner,
+		mcp:        mcp,
+	}
+	l.mu.Unlock()
+
+	log.Printf("✅ Started runner (:%d) and mcp (:%d) for repl %s", runnerPort, mcpPort, repl.Id)
+	return nil
+}
+
+// Watch has no scheduling to report, it only notices processes exiting early
+func (l *Local) Watch(ctx context.Context, replId string, onProgress func(Progress)) error {
+	repl, ok := l.get(replId)
+	if !ok {
+		return ErrReplNotRunning
+	}
+
+	onProgress(Progress{Stage: StageContainerStarted, Message: "Runner and MCP server started"})
+
+	var p *process
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-repl.runner.done:
+		p = repl.runner
+	case <-repl.mcp.done:
+		p = repl.mcp
+	}
+
+	err := fmt.Errorf("%s exited: %v (logs in %s)", filepath.Base(p.cmd.Path), p.err, p.logPath)
+	onProgress(Progress{Err: err})
+	return err
+}
+
+// Stop sends SIGTERM, on which the runner uploads the workspace and exits
+func (l *Local) Stop(ctx context.Context, repl models.Repl) error {
+	l.mu.Lock()
+	current, ok := l.repls[repl.Id]
+	delete(l.repls, repl.Id)
+	l.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	current.stop()
+	if err := current.runner.err; err != nil {
+		// Keep the directory, the next start saves it before anything else
+		return fmt.Errorf("runner did not sync the workspace: %v (logs in %s)", err, current.runner.logPath)
+	}
+
+	return os.RemoveAll(l.workspaceDir(repl.Id))
+}
+
+// storageEnv tells the runner where to sync the repl's workspace. With s3 it
+// gets no storage credentials, core presigns its requests like for pods.
+// Filesystem storage is read directly, it is on this machine anyway.
+func (l *Local) storageEnv(repl models.Repl) ([]string, error) {
+	env := []string{
+		"STORAGE_PREFIX=" + workspace.ReplPrefix(repl.User, repl.Id),
+		"CHECKPOINT_PREFIX=" + workspace.CheckpointPrefix(repl.User, repl.Id),
+	}
+	if workspace.IsShared() {
+		return append(env, "STORAGE_PROVIDER=presigned"), nil
+	}
+
+	storageDir, err := filepath.Abs(workspace.STORAGE_DIR)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, "STORAGE_PROVIDER=fs", "STORAGE_DIR="+storageDir), nil
+}
+
+func (l *Local) Status(ctx context.Context, replId string) (Status, error) {
+	repl, ok := l.get(replId)
+	if !ok {
+		return Status{State: StateStopped}, nil
+	}
+
+	for _, p := range []*process{repl.runner, repl.mcp} {
+		if !p.running() {
+			return Status{
+				State:   StateFailed,
+				Message: fmt.Sprintf("%s exited: %v", filepath.Base(p.cmd.Path), p.err),
+			}, nil
+		}
+	}
+	return Status{State: StateRunning}, nil
+}
+
+func (l *Local) Logs(ctx context.Context, replId string, opts LogOptions) (io.ReadCloser, error) {
+	container := ContainerRunner
+	if opts.Container == ContainerMcp {
+		container = ContainerMcp
+	}
+
+	file, err := os.Open(l.logPath(replId, container))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReplNotRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.TailLines > 0 {
+		if err := seekTail(file, opts.TailLines); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if !opts.Follow {
+		return file, nil
+	}
+	return &followReader{ctx: ctx, file: file}, nil
+}
+
+// Endpoint points at the local proxy, which mimics the k8s ingress paths
+func (l *Local) Endpoint(replId string) Endpoint {
+	return Endpoint{
+		RunnerURL: fmt.Sprintf("http://%s/%s", LOCAL_PROXY_ADDR, replId),
+		McpURL:    fmt.Sprintf("http://%s/mcp/%s", LOCAL_PROXY_ADDR, replId),
+	}
+}
+
+// Ping checks that the runner and mcp binaries can be found
+func (l *Local) Ping(ctx context.Context) error {
+	for _, bin := range []string{LOCAL_RUNNER_BIN, LOCAL_MCP_BIN} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxy routes /<replId>/<path> to the runner and /mcp/<replId>/<path> to the
+// mcp server of a repl, stripping the prefix like the ingress rewrite does and
+// passing it as X-Forwarded-Prefix.
+func (l *Local) proxy(w http.ResponseWriter, r *http.Request) {
+	if replId, ok := l.previewRepl(r.Host); ok {
+		repl, ok := l.get(replId)
+		if !ok {
+			http.Error(w, ErrReplNotRunning.Error(), http.StatusBadGateway)
+			return
+		}
+		// The runner picks the app's port from the Host, kept as it is
+		target, _ := url.Parse(fmt.Sprintf("http://localhost:%d", repl.runnerPort))
+		httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+
+	isMcp := parts[0] == "mcp"
+	if isMcp {
+		parts = parts[1:]
+	}
+	if len(parts) < 2 {
+		http.Error(w, "Invalid repl path", http.StatusNotFound)
+		return
+	}
+
+	repl, ok := l.get(parts[0])
+	if !ok {
+		http.Error(w, ErrReplNotRunning.Error(), http.StatusBadGateway)
+		return
+	}
+
+	port := repl.runnerPort
+	if isMcp {
+		port = repl.mcpPort
+	}
+	target, _ := url.Parse(fmt.Sprintf("http://localhost:%d", port))
+
+	prefix := "/" + parts[0]
+	if isMcp {
+		prefix = "/mcp" + prefix
+	}
+	r.Header.Set("X-Forwarded-Prefix", prefix)
+
+	r.URL.Path = "/" + strings.Join(parts[1:], "/")
+	r.URL.RawPath = ""
+	httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+}
+
+// previewRepl returns the repl of a <port>-<replId>.<LOCAL_PREVIEW_DOMAIN>
+// host
+func (l *Local) previewRepl(host string) (string, bool) {
+	if LOCAL_PREVIEW_DOMAIN == "" {
+		return "", false
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(LOCAL_PREVIEW_DOMAIN))
+	if !ok {
+		return "", false
+	}
+	// repl ids hold dashes, ports do not
+	_, replId, ok := strings.Cut(label, "-")
+	return replId, ok
+}
+
+func (r *localRepl) stop() {
+	r.mcp.stop()
+	r.runner.stop()
+}
+
+func startProcess(bin, logPath string, env ...string) (*process, error) {
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Env = processEnv(env)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, logPath: logPath, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		logFile.Close()
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// processEnv is env along with the inheritedEnv of core
+func processEnv(env []string) []string {
+	var inherited []string
+	for _, key := range inheritedEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			inherited = append(inherited, key+"="+value)
+		}
+	}
+	return append(inherited, env...)
+}
+
+func (p *process) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop asks the process (and its children, e.g. terminal shells) to exit and
+// kills them if they are still around after stopGracePeriod.
+func (p *process) stop() {
+	if !p.running() {
+		return
+	}
+
+	terminateProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(stopGracePeriod):
+		log.Printf("⚠️ %s did not exit in time, killing it", filepath.Base(p.cmd.Path))
+		killProcessGroup(p.cmd)
+		<-p.done
+	}
+}
+
+// freePorts asks the kernel for n distinct unused ports
+func freePorts(n int) ([]int, error) {
+	ports := make([]int, 0, n)
+	for range n {
+		lis, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			return nil, err
+		}
+		defer lis.Close()
+		ports = append(ports, lis.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// seekTail moves file to the start of its last n lines
+func seekTail(file *os.File, n int64) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	end := bytes.TrimRight(data, "\n")
+	offset := len(end)
+	for ; n > 0 && offset > 0; n-- {
+		offset = bytes.LastIndexByte(end[:offset], '\n')
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > 0 {
+		offset++
+	}
+
+	_, err = file.Seek(int64(offset), io.SeekStart)
+	return err
+}
+
+// followReader keeps reading a log file as it grows, until ctx is done
+type followReader struct {
+	ctx  context.Context
+	file *os.File
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+func (f *followReader) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,101 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"core/internal/workspace"
+	"core/models"
+	"core/pkg/dotenv"
+	"packages/storage"
+)
+
+// ORCHESTRATOR picks where repls run: "kubernetes" or "local"
+var ORCHESTRATOR = dotenv.EnvString("ORCHESTRATOR", "kubernetes")
+
+// ErrReplNotRunning is returned for repls that have nothing running
+var ErrReplNotRunning = errors.New("repl is not running")
+
+// Stages reported to Watch, in the order a healthy repl goes through them
+const (
+	StagePodScheduled     = "podScheduled"
+	StagePullingImage     = "pullingImage"
+	StageContainerStarted = "containerStarted"
+)
+
+// Progress is a startup milestone (or failure) of a repl
+type Progress struct {
+	Stage   string
+	Message string
+	// Err is set when the repl can not come up without intervention
+	Err error
+}
+
+// State of the runner/mcp pair of a repl, as seen by the orchestrator
+type State string
+
+const (
+	StateStopped  State = "stopped"
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateFailed   State = "failed"
+)
+
+type Status struct {
+	State   State  `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// Containers whose logs can be read
+const (
+	ContainerRunner = "runner"
+	ContainerMcp    = "mcp"
+)
+
+type LogOptions struct {
+	Container string
+	Follow    bool
+	// TailLines limits the output to the last lines, 0 means everything
+	TailLines int64
+}
+
+// Endpoint is where the runner and the mcp server of a repl can be reached
+type Endpoint struct {
+	RunnerURL string `json:"runnerUrl"`
+	McpURL    string `json:"mcpUrl"`
+}
+
+// Orchestrator runs the runner and mcp server of a repl. Start and Stop must
+// be idempotent, the lifecycle manager retries them after interruptions.
+type Orchestrator interface {
+	// Start brings the repl's workspace and processes up
+	Start(ctx context.Context, repl models.Repl) error
+	// Watch reports startup milestones until ctx is done or the repl fails
+	Watch(ctx context.Context, replId string, onProgress func(Progress)) error
+	// Stop saves the repl's workspace and tears it down
+	Stop(ctx context.Context, repl models.Repl) error
+	Status(ctx context.Context, replId string) (Status, error)
+	Logs(ctx context.Context, replId string, opts LogOptions) (io.ReadCloser, error)
+	Endpoint(replId string) Endpoint
+	// Ping checks that the orchestrator itself is usable
+	Ping(ctx context.Context) error
+}
+
+// New returns the orchestrator selected by ORCHESTRATOR
+func New(workspaces storage.WorkspaceStorage) Orchestrator {
+	switch ORCHESTRATOR {
+	case "local":
+		log.Println("⚠️ Using the local orchestrator, repls run as child processes of core")
+		return NewLocal(workspaces)
+	case "kubernetes":
+		if !workspace.IsShared() {
+			log.Println("⚠️ Repl pods can not reach filesystem storage, use STORAGE_PROVIDER=s3 with kubernetes")
+		}
+		return NewKubernetes()
+	default:
+		log.Printf("⚠️ Unknown ORCHESTRATOR %q, falling back to kubernetes", ORCHESTRATOR)
+		return NewKubernetes()
+	}
+}
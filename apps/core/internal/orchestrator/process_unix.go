@@ -0,0 +1,22 @@
+//go:build unix
+
+package orchestrator
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup puts the process in its own group, so that the shells and
+// user apps it spawns are signalled along with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
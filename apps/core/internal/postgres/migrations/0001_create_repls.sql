@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS repls (
+    id          TEXT PRIMARY KEY,
+    name        TEXT        NOT NULL,
+    username    TEXT        NOT NULL,
+    template    TEXT        NOT NULL,
+    is_active   BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS repls_username_idx ON repls (username);
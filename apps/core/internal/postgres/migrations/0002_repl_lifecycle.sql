@@ -0,0 +1,13 @@
+ALTER TABLE repls
+    ADD COLUMN state            TEXT  NOT NULL DEFAULT 'stopped',
+    ADD COLUMN last_error       TEXT  NOT NULL DEFAULT '',
+    ADD COLUMN state_timestamps JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+UPDATE repls SET state = 'running' WHERE is_active;
+
+UPDATE repls SET state_timestamps = jsonb_build_object(state, updated_at);
+
+ALTER TABLE repls DROP COLUMN is_active;
+
+ALTER TABLE repls ADD CONSTRAINT repls_state_check
+    CHECK (state IN ('stopped', 'starting', 'running', 'stopping', 'failed'));
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS restore_points (
+    repl_id    TEXT        NOT NULL REFERENCES repls (id) ON DELETE CASCADE,
+    id         TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    size       BIGINT      NOT NULL,
+    files      INTEGER     NOT NULL,
+    PRIMARY KEY (repl_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS restore_points_created_at_idx ON restore_points (repl_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- The repl a repl was forked from. Kept as plain text rather than a foreign
+-- key so the lineage survives the parent being deleted.
+ALTER TABLE repls ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
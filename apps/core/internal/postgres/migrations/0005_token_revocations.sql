@@ -0,0 +1,3 @@
+-- Access tokens revoked before they expire, handed to the runner and mcp
+-- server when the repl starts (see packages/replauth.Revocations).
+ALTER TABLE repls ADD COLUMN token_revocations JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"core/internal/store"
+	"core/models"
+	"core/pkg/dotenv"
+	"packages/replauth"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Postgres struct {
+	pool *pgxpool.Pool
+	ctx  context.Context
+}
+
+var DATABASE_URL = dotenv.EnvString("DATABASE_URL", "")
+
+var _ store.ReplStore = (*Postgres)(nil)
+
+func NewPostgresStore() *Postgres {
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, DATABASE_URL)
+	if err != nil {
+		log.Printf("❌ Failed to configure Postgres: %v", err)
+	}
+
+	p := &Postgres{
+		pool: pool,
+		ctx:  ctx,
+	}
+
+	if err := p.Ping(); err != nil {
+		log.Printf("❌ Failed to connect to Postgres: %v", err)
+		return p
+	}
+
+	if err := p.migrate(ctx); err != nil {
+		log.Printf("❌ Failed to migrate Postgres: %v", err)
+		return p
+	}
+
+	log.Println("✅ Connected to Postgres")
+	return p
+}
+
+// Ping Postgres (Health Check)
+func (p *Postgres) Ping() error {
+	if p.pool == nil {
+		return errors.New("postgres pool is not configured")
+	}
+	return p.pool.Ping(p.ctx)
+}
+
+func (p *Postgres) CreateRepl(template, username, replName, replId, parentId string) error {
+	_, err := p.pool.Exec(p.ctx, `
+		INSERT INTO repls (id, name, username, template, parent_id, state, state_timestamps)
+		VALUES ($1, $2, $3, $4, $5, $6, jsonb_build_object($6::text, now()))`,
+		replId, replName, username, template, parentId, models.ReplStopped)
+	return err
+}
+
+func (p *Postgres) DeleteRepl(replId string) error {
+	tag, err := p.pool.Exec(p.ctx, `DELETE FROM repls WHERE id = $1`, replId)
+	if err != nil {
+		return fmt.Errorf("failed to delete repl: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", store.ErrReplNotFound, replId)
+	}
+	return nil
+}
+
+const replColumns = `id, name, username, template, parent_id, state, last_error, state_timestamps, token_revocations`
+
+func scanRepl(row pgx.Row) (models.Repl, error) {
+	var repl models.Repl
+	err := row.Scan(&repl.Id, &repl.Name, &repl.User, &repl.Template, &repl.ParentId,
+		&repl.State, &repl.LastError, &repl.StateTimestamps, &repl.TokenRevocations)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Repl{}, store.ErrReplNotFound
+	}
+	return repl, err
+}
+
+func (p *Postgres) GetRepl(replId string) (models.Repl, error) {
+	return scanRepl(p.pool.QueryRow(p.ctx,
+		`SELECT `+replColumns+` FROM repls WHERE id = $1`, replId))
+}
+
+// user-repl relationship
+func (p *Postgres) GetUserRepls(username string) ([]string, error) {
+	rows, err := p.pool.Query(p.ctx,
+		`SELECT id FROM repls WHERE username = $1 ORDER BY created_at`, username)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// Repl Lifecycle
+func (p *Postgres) TransitionRepl(replId string, next models.ReplState, reason string) (models.Repl, error) {
+	var repl models.Repl
+
+	err := pgx.BeginFunc(p.ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+		repl, err = scanRepl(tx.QueryRow(p.ctx,
+			`SELECT `+replColumns+` FROM repls WHERE id = $1 FOR UPDATE`, replId))
+		if err != nil {
+			return err
+		}
+
+		if err := repl.Transition(next, reason, time.Now()); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(p.ctx, `
+			UPDATE repls
+			SET state = $2, last_error = $3, state_timestamps = $4, updated_at = now()
+			WHERE id = $1`,
+			replId, repl.State, repl.LastError, repl.StateTimestamps)
+		return err
+	})
+
+	return repl, err
+}
+
+func (p *Postgres) RevokeTokens(replId string, req replauth.RevokeRequest) (models.Repl, error) {
+	var repl models.Repl
+
+	err := pgx.BeginFunc(p.ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+		repl, err = scanRepl(tx.QueryRow(p.ctx,
+			`SELECT `+replColumns+` FROM repls WHERE id = $1 FOR UPDATE`, replId))
+		if err != nil {
+			return err
+		}
+
+		repl.TokenRevocations = repl.TokenRevocations.Add(req, time.Now())
+		_, err = tx.Exec(p.ctx, `
+			UPDATE repls SET token_revocations = $2, updated_at = now() WHERE id = $1`,
+			replId, repl.TokenRevocations)
+		return err
+	})
+
+	return repl, err
+}
+
+// Restore points
+func (p *Postgres) AddRestorePoint(replId string, point models.RestorePoint) error {
+	return pgx.BeginFunc(p.ctx, p.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(p.ctx, `
+			INSERT INTO restore_points (repl_id, id, created_at, size, files)
+			SELECT id, $2, $3, $4, $5 FROM repls WHERE id = $1
+			ON CONFLICT (repl_id, id) DO NOTHING`,
+			replId, point.Id, point.CreatedAt, point.Size, point.Files)
+		if err != nil {
+			return err
+		}
+		// Either the repl does not exist or the point is already known
+		if tag.RowsAffected() == 0 {
+			_, err := scanRepl(tx.QueryRow(p.ctx,
+				`SELECT `+replColumns+` FROM repls WHERE id = $1`, replId))
+			return err
+		}
+
+		_, err = tx.Exec(p.ctx, `
+			DELETE FROM restore_points
+			WHERE repl_id = $1 AND id NOT IN (
+				SELECT id FROM restore_points WHERE repl_id = $1
+				ORDER BY created_at DESC LIMIT $2
+			)`, replId, store.MaxRestorePoints)
+		return err
+	})
+}
+
+func (p *Postgres) GetRestorePoints(replId string) ([]models.RestorePoint, error) {
+	if _, err := p.GetRepl(replId); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(p.ctx, `
+		SELECT id, created_at, size, files FROM restore_points
+		WHERE repl_id = $1 ORDER BY created_at DESC`, replId)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.RestorePoint, error) {
+		var point models.RestorePoint
+		err := row.Scan(&point.Id, &point.CreatedAt, &point.Size, &point.Files)
+		return point, err
+	})
+}
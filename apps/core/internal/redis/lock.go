@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"core/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ store.Locker = (*Redis)(nil)
+
+// Only touch the key if it still holds our token, so a lease that expired
+// and was re-acquired by another core replica is never extended or deleted.
+var (
+	refreshScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0`)
+
+	releaseScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0`)
+)
+
+type redisLease struct {
+	client *redis.Client
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+// Acquire takes a lock shared by every core replica using SET NX PX
+func (r *Redis) Acquire(ctx context.Context, key string, ttl time.Duration) (store.Lease, error) {
+	token := uuid.NewString()
+
+	ok, err := r.client.SetNX(ctx, "lock:"+key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, store.ErrLockHeld
+	}
+
+	return &redisLease{client: r.client, key: "lock:" + key, token: token, ttl: ttl}, nil
+}
+
+func (l *redisLease) Refresh(ctx context.Context) error {
+	n, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrLockLost
+	}
+	return nil
+}
+
+func (l *redisLease) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.client, []string{l.key}, l.token).Err()
+}
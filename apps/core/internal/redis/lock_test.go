@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"core/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// The Redis tests need a server, they run when REDIS_URL is set
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+	if REDIS_URL == "" {
+		t.Skip("REDIS_URL is not set")
+	}
+	r := NewRedisStore()
+	if err := r.Ping(); err != nil {
+		t.Fatalf("Redis is not reachable: %v", err)
+	}
+	t.Cleanup(func() { r.client.Close() })
+	return r
+}
+
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRedis(t)
+	key := "test:" + uuid.NewString()
+
+	lease, err := r.Acquire(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Acquire(ctx, key, time.Minute); !errors.Is(err, store.ErrLockHeld) {
+		t.Errorf("Acquire() of a held lock error = %v, want %v", err, store.ErrLockHeld)
+	}
+	if err := lease.Refresh(ctx); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other, err := r.Acquire(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	defer other.Release(ctx)
+
+	// The released lease must not touch the lock taken over
+	if err := lease.Refresh(ctx); !errors.Is(err, store.ErrLockLost) {
+		t.Errorf("Refresh() of a released lease error = %v, want %v", err, store.ErrLockLost)
+	}
+	lease.Release(ctx)
+	if _, err := r.Acquire(ctx, key, time.Minute); !errors.Is(err, store.ErrLockHeld) {
+		t.Errorf("Acquire() after a stale Release() error = %v, want %v", err, store.ErrLockHeld)
+	}
+}
+
+func TestRedisLockerExpiry(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRedis(t)
+	key := "test:" + uuid.NewString()
+
+	lease, err := r.Acquire(ctx, key, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := lease.Refresh(ctx); !errors.Is(err, store.ErrLockLost) {
+		t.Errorf("Refresh() of an expired lease error = %v, want %v", err, store.ErrLockLost)
+	}
+	other, err := r.Acquire(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() of an expired lock error = %v", err)
+	}
+	other.Release(ctx)
+}
@@ -2,12 +2,16 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"core/internal/store"
 	"core/models"
 	"core/pkg/dotenv"
+	"packages/replauth"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -19,6 +23,8 @@ type Redis struct {
 
 var REDIS_URL = dotenv.EnvString("REDIS_URL", "")
 
+var _ store.ReplStore = (*Redis)(nil)
+
 func NewRedisStore() *Redis {
 
 	ctx := context.Background()
@@ -45,13 +51,15 @@ func (r *Redis) Ping() error {
 }
 
 // Helper Functinos
-func (r *Redis) CreateRepl(template, username, replName, replId string) error {
+func (r *Redis) CreateRepl(template, username, replName, replId, parentId string) error {
 	if err := r.client.HSet(r.ctx, "repl:"+replId, map[string]string{
-		"id":       replId,
-		"name":     replName,
-		"user":     username,
-		"template": template,
-		"isActive": "false",
+		"id":                                    replId,
+		"name":                                  replName,
+		"user":                                  username,
+		"template":                              template,
+		"parentId":                              parentId,
+		"state":                                 string(models.ReplStopped),
+		stateTimestampField(models.ReplStopped): time.Now().Format(time.RFC3339Nano),
 	}).Err(); err != nil {
 		return err
 	}
@@ -71,7 +79,7 @@ func (r *Redis) DeleteRepl(replId string) error {
 	}
 
 	if len(replData) == 0 {
-		return fmt.Errorf("repl not found: %s", replId)
+		return fmt.Errorf("%w: %s", store.ErrReplNotFound, replId)
 	}
 
 	username := replData["user"]
@@ -84,8 +92,8 @@ func (r *Redis) DeleteRepl(replId string) error {
 		return fmt.Errorf("failed to remove repl from user set: %w", err)
 	}
 
-	// Delete the repl hash
-	if err := r.client.Del(r.ctx, "repl:"+replId).Err(); err != nil {
+	// Delete the repl hash and its restore points
+	if err := r.client.Del(r.ctx, "repl:"+replId, restorePointsKey(replId)).Err(); err != nil {
 		return fmt.Errorf("failed to delete repl: %w", err)
 	}
 
@@ -100,18 +108,10 @@ func (r *Redis) GetRepl(replId string) (models.Repl, error) {
 	}
 
 	if len(data) == 0 {
-		return models.Repl{}, errors.New("No such Repl Found")
-	}
-
-	repl := models.Repl{
-		Id:       replId,
-		Name:     data["name"],
-		User:     data["user"],
-		Template: data["template"],
-		IsActive: data["isActive"] == "true",
+		return models.Repl{}, store.ErrReplNotFound
 	}
 
-	return repl, nil
+	return replFromHash(replId, data), nil
 }
 
 // user-repl relationship
@@ -123,11 +123,179 @@ func (r *Redis) GetUserRepls(username string) ([]string, error) {
 	return r.client.SMembers(r.ctx, "user:"+username).Result()
 }
 
-// Repl Session
-func (r *Redis) CreateReplSession(replId string) error {
-	return r.client.HSet(r.ctx, "repl:"+replId, "isActive", "true").Err()
+// Repl Lifecycle
+func (r *Redis) TransitionRepl(replId string, next models.ReplState, reason string) (models.Repl, error) {
+	key := "repl:" + replId
+	var repl models.Repl
+
+	transition := func(tx *redis.Tx) error {
+		data, err := tx.HGetAll(r.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return store.ErrReplNotFound
+		}
+
+		repl = replFromHash(replId, data)
+		now := time.Now()
+		if err := repl.Transition(next, reason, now); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(r.ctx, key, map[string]string{
+				"state":                   string(repl.State),
+				"lastError":               repl.LastError,
+				stateTimestampField(next): now.Format(time.RFC3339Nano),
+			})
+			// Drop the pre-lifecycle flag once the repl has a real state
+			pipe.HDel(r.ctx, key, "isActive")
+			return nil
+		})
+		return err
+	}
+
+	// Optimistic locking: retry if another writer touched the hash between
+	// our read and the MULTI/EXEC.
+	for range 5 {
+		err := r.client.Watch(r.ctx, transition, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return repl, err
+	}
+
+	return repl, fmt.Errorf("failed to update state of repl %s: too much contention", replId)
 }
 
-func (r *Redis) DeleteReplSession(replId string) error {
-	return r.client.HSet(r.ctx, "repl:"+replId, "isActive", "false").Err()
+func (r *Redis) RevokeTokens(replId string, req replauth.RevokeRequest) (models.Repl, error) {
+	key := "repl:" + replId
+	var repl models.Repl
+
+	revoke := func(tx *redis.Tx) error {
+		data, err := tx.HGetAll(r.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return store.ErrReplNotFound
+		}
+
+		repl = replFromHash(replId, data)
+		repl.TokenRevocations = repl.TokenRevocations.Add(req, time.Now())
+		revocations, err := json.Marshal(repl.TokenRevocations)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(r.ctx, key, "tokenRevocations", string(revocations))
+			return nil
+		})
+		return err
+	}
+
+	for range 5 {
+		err := r.client.Watch(r.ctx, revoke, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return repl, err
+	}
+
+	return repl, fmt.Errorf("failed to revoke tokens of repl %s: too much contention", replId)
+}
+
+// Restore points are kept in a sorted set scored by creation time, with the
+// JSON encoded point as member.
+func (r *Redis) AddRestorePoint(replId string, point models.RestorePoint) error {
+	exists, err := r.client.Exists(r.ctx, "repl:"+replId).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return store.ErrReplNotFound
+	}
+
+	member, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+
+	key := restorePointsKey(replId)
+	_, err = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(r.ctx, key, redis.Z{Score: float64(point.CreatedAt.UnixMilli()), Member: member})
+		pipe.ZRemRangeByRank(r.ctx, key, 0, -store.MaxRestorePoints-1)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) GetRestorePoints(replId string) ([]models.RestorePoint, error) {
+	exists, err := r.client.Exists(r.ctx, "repl:"+replId).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, store.ErrReplNotFound
+	}
+
+	members, err := r.client.ZRevRange(r.ctx, restorePointsKey(replId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.RestorePoint, 0, len(members))
+	for _, member := range members {
+		var point models.RestorePoint
+		if err := json.Unmarshal([]byte(member), &point); err != nil {
+			return nil, fmt.Errorf("invalid restore point of repl %s: %w", replId, err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func restorePointsKey(replId string) string {
+	return "restorePoints:" + replId
+}
+
+func stateTimestampField(state models.ReplState) string {
+	return string(state) + "At"
+}
+
+func replFromHash(replId string, data map[string]string) models.Repl {
+	repl := models.Repl{
+		Id:              replId,
+		Name:            data["name"],
+		User:            data["user"],
+		Template:        data["template"],
+		ParentId:        data["parentId"],
+		State:           models.ReplState(data["state"]),
+		LastError:       data["lastError"],
+		StateTimestamps: make(map[models.ReplState]time.Time),
+	}
+
+	// Repls created before the lifecycle existed only carry isActive
+	if !repl.State.Valid() {
+		repl.State = models.ReplStopped
+		if data["isActive"] == "true" {
+			repl.State = models.ReplRunning
+		}
+	}
+
+	for _, state := range models.ReplStates {
+		if at, err := time.Parse(time.RFC3339Nano, data[stateTimestampField(state)]); err == nil {
+			repl.StateTimestamps[state] = at
+		}
+	}
+
+	if revocations := data["tokenRevocations"]; revocations != "" {
+		if err := json.Unmarshal([]byte(revocations), &repl.TokenRevocations); err != nil {
+			log.Printf("Error reading token revocations of repl %s: %v", replId, err)
+		}
+	}
+
+	return repl
 }
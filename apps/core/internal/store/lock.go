@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	// ErrLockHeld is returned by Locker.Acquire when someone else holds the lock
+	ErrLockHeld = errors.New("lock is held by another operation")
+	// ErrLockLost is returned when a lease expired and was taken over
+	ErrLockLost = errors.New("lock lease was lost")
+)
+
+// Locker hands out named, expiring locks. Leases expire on their own so that
+// a core replica crashing mid-operation never wedges a repl forever.
+type Locker interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// Lease is a held lock
+type Lease interface {
+	// Refresh pushes the expiry back by the lease ttl
+	Refresh(ctx context.Context) error
+	// Release gives the lock up; releasing a lost lease is a no-op
+	Release(ctx context.Context) error
+}
+
+// KeepAlive refreshes the lease every interval until the returned stop
+// function is called, so long operations keep their lock without needing a
+// long ttl. The returned context is cancelled with ErrLockLost as its cause
+// if the lease is lost, work done under the lock has to stop then because
+// another operation may already hold it.
+func KeepAlive(lease Lease, interval time.Duration) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lease.Refresh(ctx); err != nil {
+					log.Printf("⚠️ Failed to refresh lock lease: %v", err)
+					if errors.Is(err, ErrLockLost) {
+						cancel(ErrLockLost)
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ctx, func() { cancel(nil) }
+}
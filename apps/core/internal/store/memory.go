@@ -0,0 +1,167 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"core/models"
+	"packages/replauth"
+)
+
+// MemoryStore is an in-process ReplStore. Nothing survives a restart, so it
+// is meant for local development and for testing handlers without a database.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	repls map[string]models.Repl
+	// userRepls lists the repls of each user in creation order
+	userRepls     map[string][]string
+	restorePoints map[string][]models.RestorePoint
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		repls:         make(map[string]models.Repl),
+		userRepls:     make(map[string][]string),
+		restorePoints: make(map[string][]models.RestorePoint),
+	}
+}
+
+func (m *MemoryStore) Ping() error {
+	return nil
+}
+
+func (m *MemoryStore) CreateRepl(template, username, replName, replId, parentId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.repls[replId]; !exists {
+		m.userRepls[username] = append(m.userRepls[username], replId)
+	}
+
+	m.repls[replId] = models.Repl{
+		Id:       replId,
+		Name:     replName,
+		User:     username,
+		Template: template,
+		ParentId: parentId,
+		State:    models.ReplStopped,
+		StateTimestamps: map[models.ReplState]time.Time{
+			models.ReplStopped: time.Now(),
+		},
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteRepl(replId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repl, ok := m.repls[replId]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrReplNotFound, replId)
+	}
+
+	m.userRepls[repl.User] = slices.DeleteFunc(m.userRepls[repl.User], func(id string) bool {
+		return id == replId
+	})
+	delete(m.repls, replId)
+	delete(m.restorePoints, replId)
+
+	return nil
+}
+
+func (m *MemoryStore) GetRepl(replId string) (models.Repl, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	repl, ok := m.repls[replId]
+	if !ok {
+		return models.Repl{}, ErrReplNotFound
+	}
+	return repl, nil
+}
+
+func (m *MemoryStore) GetUserRepls(username string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.userRepls[username]), nil
+}
+
+func (m *MemoryStore) TransitionRepl(replId string, next models.ReplState, reason string) (models.Repl, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repl, ok := m.repls[replId]
+	if !ok {
+		return models.Repl{}, ErrReplNotFound
+	}
+
+	// Copy the timestamps so callers holding an older models.Repl never see
+	// the map change underneath them.
+	timestamps := make(map[models.ReplState]time.Time, len(repl.StateTimestamps)+1)
+	for state, at := range repl.StateTimestamps {
+		timestamps[state] = at
+	}
+	repl.StateTimestamps = timestamps
+
+	if err := repl.Transition(next, reason, time.Now()); err != nil {
+		return repl, err
+	}
+	m.repls[replId] = repl
+
+	return repl, nil
+}
+
+func (m *MemoryStore) RevokeTokens(replId string, req replauth.RevokeRequest) (models.Repl, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repl, ok := m.repls[replId]
+	if !ok {
+		return models.Repl{}, ErrReplNotFound
+	}
+	repl.TokenRevocations = repl.TokenRevocations.Add(req, time.Now())
+	m.repls[replId] = repl
+
+	return repl, nil
+}
+
+func (m *MemoryStore) AddRestorePoint(replId string, point models.RestorePoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.repls[replId]; !ok {
+		return ErrReplNotFound
+	}
+
+	points := m.restorePoints[replId]
+	for _, p := range points {
+		if p.Id == point.Id {
+			return nil
+		}
+	}
+
+	points = append([]models.RestorePoint{point}, points...)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].CreatedAt.After(points[j].CreatedAt) })
+	if len(points) > MaxRestorePoints {
+		points = points[:MaxRestorePoints]
+	}
+	m.restorePoints[replId] = points
+
+	return nil
+}
+
+func (m *MemoryStore) GetRestorePoints(replId string) ([]models.RestorePoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.repls[replId]; !ok {
+		return nil, ErrReplNotFound
+	}
+	return append([]models.RestorePoint(nil), m.restorePoints[replId]...), nil
+}
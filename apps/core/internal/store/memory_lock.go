@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryLocker is a Locker scoped to a single core process. It is used with
+// the memory store, or when no Redis is configured.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+type memoryLease struct {
+	locker *MemoryLocker
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		locks: make(map[string]memoryLock),
+	}
+}
+
+func (m *MemoryLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if held, ok := m.locks[key]; ok && time.Now().Before(held.expiresAt) {
+		return nil, ErrLockHeld
+	}
+
+	token := uuid.NewString()
+	m.locks[key] = memoryLock{token: token, expiresAt: time.Now().Add(ttl)}
+
+	return &memoryLease{locker: m, key: key, token: token, ttl: ttl}, nil
+}
+
+func (l *memoryLease) Refresh(ctx context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	held, ok := l.locker.locks[l.key]
+	if !ok || held.token != l.token || time.Now().After(held.expiresAt) {
+		return ErrLockLost
+	}
+	held.expiresAt = time.Now().Add(l.ttl)
+	l.locker.locks[l.key] = held
+
+	return nil
+}
+
+func (l *memoryLease) Release(ctx context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	if held, ok := l.locker.locks[l.key]; ok && held.token == l.token {
+		delete(l.locker.locks, l.key)
+	}
+	return nil
+}
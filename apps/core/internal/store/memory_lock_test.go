@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	ctx := context.Background()
+	locker := NewMemoryLocker()
+
+	lease, err := locker.Acquire(ctx, "repl-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.Acquire(ctx, "repl-1", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("Acquire() of a held lock error = %v, want %v", err, ErrLockHeld)
+	}
+	if _, err := locker.Acquire(ctx, "repl-2", time.Minute); err != nil {
+		t.Errorf("Acquire() of another key error = %v", err)
+	}
+	if err := lease.Refresh(ctx); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.Acquire(ctx, "repl-1", time.Minute); err != nil {
+		t.Errorf("Acquire() after Release() error = %v", err)
+	}
+}
+
+func TestMemoryLockerExpiry(t *testing.T) {
+	ctx := context.Background()
+	locker := NewMemoryLocker()
+
+	lease, err := locker.Acquire(ctx, "repl-1", 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	if err := lease.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Refresh() of an expired lease error = %v, want %v", err, ErrLockLost)
+	}
+
+	// Taken over once expired, the old lease must not release the new one
+	other, err := locker.Acquire(ctx, "repl-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() of an expired lock error = %v", err)
+	}
+	lease.Release(ctx)
+	if _, err := locker.Acquire(ctx, "repl-1", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("Acquire() after a stale Release() error = %v, want %v", err, ErrLockHeld)
+	}
+	if err := other.Refresh(ctx); err != nil {
+		t.Errorf("Refresh() of the new lease error = %v", err)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	ctx := context.Background()
+	locker := NewMemoryLocker()
+
+	lease, err := locker.Acquire(ctx, "repl-1", 40*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, stop := KeepAlive(lease, 10*time.Millisecond)
+
+	// Refreshed past its ttl, the lock is still held
+	time.Sleep(100 * time.Millisecond)
+	if _, err := locker.Acquire(ctx, "repl-1", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("Acquire() of a kept alive lock error = %v, want %v", err, ErrLockHeld)
+	}
+	if kept.Err() != nil {
+		t.Errorf("context of a kept alive lease is done: %v", context.Cause(kept))
+	}
+
+	stop()
+	if cause := context.Cause(kept); errors.Is(cause, ErrLockLost) {
+		t.Errorf("context of a stopped lease cause = %v, want no %v", cause, ErrLockLost)
+	}
+}
+
+func TestKeepAliveLost(t *testing.T) {
+	ctx := context.Background()
+	locker := NewMemoryLocker()
+
+	lease, err := locker.Acquire(ctx, "repl-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, stop := KeepAlive(lease, 10*time.Millisecond)
+	defer stop()
+
+	// Someone else takes the lock over
+	lease.Release(ctx)
+	if _, err := locker.Acquire(ctx, "repl-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-kept.Done():
+		if cause := context.Cause(kept); !errors.Is(cause, ErrLockLost) {
+			t.Errorf("context cause = %v, want %v", cause, ErrLockLost)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("context of a lost lease was not cancelled")
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"core/models"
+	"packages/replauth"
+)
+
+func TestMemoryStoreTransitionRepl(t *testing.T) {
+	m := NewMemoryStore()
+	if err := m.CreateRepl("node", "octocat", "demo", "repl-1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		next      models.ReplState
+		reason    string
+		wantErr   error
+		wantState models.ReplState
+	}{
+		{next: models.ReplRunning, wantErr: models.ErrInvalidTransition, wantState: models.ReplStopped},
+		{next: models.ReplStarting, wantState: models.ReplStarting},
+		{next: models.ReplFailed, reason: "pod evicted", wantState: models.ReplFailed},
+		{next: models.ReplStarting, wantState: models.ReplStarting},
+		{next: models.ReplRunning, wantState: models.ReplRunning},
+		{next: models.ReplStopped, wantErr: models.ErrInvalidTransition, wantState: models.ReplRunning},
+		{next: models.ReplStopping, wantState: models.ReplStopping},
+		{next: models.ReplStopped, wantState: models.ReplStopped},
+	}
+
+	for i, step := range steps {
+		_, err := m.TransitionRepl("repl-1", step.next, step.reason)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("step %d: TransitionRepl(%s) error = %v, want %v", i, step.next, err, step.wantErr)
+		}
+		repl, err := m.GetRepl("repl-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repl.State != step.wantState {
+			t.Fatalf("step %d: state = %s, want %s", i, repl.State, step.wantState)
+		}
+	}
+
+	if _, err := m.TransitionRepl("missing", models.ReplStarting, ""); !errors.Is(err, ErrReplNotFound) {
+		t.Errorf("TransitionRepl() of an unknown repl error = %v, want %v", err, ErrReplNotFound)
+	}
+}
+
+func TestMemoryStoreTransitionCopiesTimestamps(t *testing.T) {
+	m := NewMemoryStore()
+	m.CreateRepl("node", "octocat", "demo", "repl-1", "")
+
+	before, _ := m.GetRepl("repl-1")
+	if _, err := m.TransitionRepl("repl-1", models.ReplStarting, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := before.StateTimestamps[models.ReplStarting]; ok {
+		t.Error("transition changed the timestamps of a repl read before")
+	}
+}
+
+func TestMemoryStoreRevokeTokens(t *testing.T) {
+	m := NewMemoryStore()
+	if err := m.CreateRepl("node", "octocat", "demo", "repl-1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if _, err := m.RevokeTokens("repl-1", replauth.RevokeRequest{TokenId: "t1", ExpiresAt: expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if _, err := m.RevokeTokens("repl-1", replauth.RevokeRequest{Before: before}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kept with the repl for its next start
+	repl, err := m.GetRepl("repl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repl.TokenRevocations; !got.Tokens["t1"].Equal(expiresAt) || !got.Before.Equal(before) {
+		t.Errorf("TokenRevocations = %+v", got)
+	}
+
+	if _, err := m.RevokeTokens("missing", replauth.RevokeRequest{Before: before}); !errors.Is(err, ErrReplNotFound) {
+		t.Errorf("RevokeTokens() error = %v, want %v", err, ErrReplNotFound)
+	}
+}
+
+func TestMemoryStoreUserRepls(t *testing.T) {
+	m := NewMemoryStore()
+	for _, id := range []string{"repl-c", "repl-a", "repl-b"} {
+		if err := m.CreateRepl("node", "octocat", id, id, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.CreateRepl("node", "hubot", "other", "repl-d", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.DeleteRepl("repl-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteRepl("repl-a"); !errors.Is(err, ErrReplNotFound) {
+		t.Errorf("DeleteRepl() error = %v, want %v", err, ErrReplNotFound)
+	}
+
+	// In creation order, every time
+	for range 5 {
+		ids, err := m.GetUserRepls("octocat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, []string{"repl-c", "repl-b"}) {
+			t.Fatalf("GetUserRepls() = %v, want [repl-c repl-b]", ids)
+		}
+	}
+}
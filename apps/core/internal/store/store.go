@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+
+	"core/models"
+	"packages/replauth"
+)
+
+// ErrReplNotFound is returned by every ReplStore when a repl id is unknown.
+var ErrReplNotFound = errors.New("No such Repl Found")
+
+// MaxRestorePoints is how many restore points are kept per repl, older ones
+// are dropped as new checkpoints come in.
+const MaxRestorePoints = 100
+
+// ReplStore persists repl metadata and the user -> repl relationship.
+// The handlers in services/repl and services/runner only talk to this
+// interface, so the backing database can be swapped via REPL_STORE.
+type ReplStore interface {
+	// Ping checks the connection to the underlying database (Health Check)
+	Ping() error
+
+	// CreateRepl records a new stopped repl, parentId is the repl it was
+	// forked from or "" for repls created from a template.
+	CreateRepl(template, username, replName, replId, parentId string) error
+	DeleteRepl(replId string) error
+	GetRepl(replId string) (models.Repl, error)
+
+	// user-repl relationship
+	GetUserRepls(username string) ([]string, error)
+
+	// TransitionRepl atomically moves a repl to the next lifecycle state,
+	// rejecting moves not allowed by models.ReplState with
+	// models.ErrInvalidTransition. reason is recorded as the last error
+	// when moving to models.ReplFailed.
+	TransitionRepl(replId string, next models.ReplState, reason string) (models.Repl, error)
+
+	// RevokeTokens adds req to the token revocations of a repl, dropping
+	// the ones of tokens that expired.
+	RevokeTokens(replId string, req replauth.RevokeRequest) (models.Repl, error)
+
+	// Restore points reported by the runner, listed newest first. Adding a
+	// point that is already known is a no-op.
+	AddRestorePoint(replId string, point models.RestorePoint) error
+	GetRestorePoints(replId string) ([]models.RestorePoint, error)
+}
@@ -0,0 +1,106 @@
+// Package tokens mints the access tokens of the repl endpoints (runner
+// WebSocket and API, app previews, mcp server), see packages/replauth.
+//
+// Every repl gets its own signing key, derived from REPL_TOKEN_SECRET and
+// its replId, and handed to its runner and mcp server on start.
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"core/pkg/dotenv"
+	"packages/replauth"
+)
+
+var (
+	// REPL_TOKEN_SECRET derives the signing keys of the repls. It must be
+	// the same on every core replica and across restarts, or the tokens of
+	// running repls stop being accepted.
+	REPL_TOKEN_SECRET = dotenv.EnvString("REPL_TOKEN_SECRET", "")
+	// REPL_TOKEN_TTL is how long the tokens of users are valid, they are
+	// refreshed by the frontend before that
+	REPL_TOKEN_TTL = dotenv.EnvString("REPL_TOKEN_TTL", "1h")
+)
+
+const (
+	defaultTTL = time.Hour
+	// coreTTL is enough for a single call of core to a repl
+	coreTTL = time.Minute
+)
+
+var (
+	secret = loadSecret()
+	ttl    = loadTTL()
+)
+
+func loadSecret() []byte {
+	if REPL_TOKEN_SECRET != "" {
+		return []byte(REPL_TOKEN_SECRET)
+	}
+	log.Println("⚠️ REPL_TOKEN_SECRET is not set, using a random one: running repls reject their tokens after a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("failed to generate REPL_TOKEN_SECRET: %v", err)
+	}
+	return secret
+}
+
+func loadTTL() time.Duration {
+	ttl, err := time.ParseDuration(REPL_TOKEN_TTL)
+	if err != nil || ttl <= 0 {
+		log.Printf("⚠️ Invalid REPL_TOKEN_TTL %q, using %s", REPL_TOKEN_TTL, defaultTTL)
+		return defaultTTL
+	}
+	return ttl
+}
+
+// KeyHex is the signing key of replId, as the REPL_TOKEN_KEY of its runner
+// and mcp server
+func KeyHex(replId string) string {
+	return hex.EncodeToString(replauth.ReplKey(secret, replId))
+}
+
+// Revocations encodes the token revocations of a repl, as the
+// REPL_TOKEN_REVOCATIONS of its runner and mcp server
+func Revocations(revocations replauth.Revocations) string {
+	encoded, err := json.Marshal(revocations)
+	if err != nil {
+		log.Fatalf("failed to encode token revocations: %v", err)
+	}
+	return string(encoded)
+}
+
+// Issue mints a token of replId for user
+func Issue(replId, user string) (string, *replauth.Claims, error) {
+	return replauth.Issue(replauth.ReplKey(secret, replId), replId, user, replauth.ScopeUser, ttl)
+}
+
+// Authorize lets core itself make req to the runner or mcp server of replId
+func Authorize(req *http.Request, replId string) error {
+	token, _, err := replauth.Issue(replauth.ReplKey(secret, replId), replId, "core", replauth.ScopeCore, coreTTL)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// VerifyRunner checks that req was made by the runner of replId, with a
+// token it signed with the repl's key
+func VerifyRunner(req *http.Request, replId string) error {
+	token, _ := replauth.FromRequest(req, replauth.SourceHeader)
+	claims, err := replauth.NewVerifier(replauth.ReplKey(secret, replId), replId).Verify(token)
+	if err != nil {
+		return err
+	}
+	if claims.Scope != replauth.ScopeRunner {
+		return fmt.Errorf("%w: not a runner token", replauth.ErrInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package workspace configures the storage used for repl files and knows the
+// layout of keys in it.
+package workspace
+
+import (
+	"fmt"
+	"log"
+
+	"core/pkg/dotenv"
+	"packages/storage"
+)
+
+// STORAGE_* fall back to the SPACES_* variables used before storage was
+// configurable, so existing deployments keep working unchanged.
+var (
+	STORAGE_PROVIDER   = dotenv.EnvString("STORAGE_PROVIDER", "s3")
+	STORAGE_DIR        = dotenv.EnvString("STORAGE_DIR", "./data/storage")
+	STORAGE_ENDPOINT   = dotenv.EnvString("STORAGE_ENDPOINT", dotenv.EnvString("SPACES_ENDPOINT", "https://blr1.digitaloceanspaces.com"))
+	STORAGE_REGION     = dotenv.EnvString("STORAGE_REGION", dotenv.EnvString("SPACES_REGION", "blr1"))
+	STORAGE_BUCKET     = dotenv.EnvString("STORAGE_BUCKET", dotenv.EnvString("SPACES_BUCKET", "devex"))
+	STORAGE_ACCESS_KEY = dotenv.EnvString("STORAGE_ACCESS_KEY", dotenv.EnvString("SPACES_ACCESS_KEY", "YOUR_SPACES_ACCESS_KEY"))
+	STORAGE_SECRET_KEY = dotenv.EnvString("STORAGE_SECRET_KEY", dotenv.EnvString("SPACES_SECRET_KEY", "YOUR_SPACES_SECRET_KEY"))
+)
+
+// NewStorage returns the storage selected by STORAGE_PROVIDER ("s3" or "fs")
+func NewStorage() storage.WorkspaceStorage {
+	switch STORAGE_PROVIDER {
+	case "fs":
+		log.Printf("⚠️ Using filesystem storage in %s, only reachable from this machine", STORAGE_DIR)
+		return storage.NewFS(STORAGE_DIR)
+	case "s3":
+		return storage.NewS3(S3Config())
+	default:
+		log.Printf("⚠️ Unknown STORAGE_PROVIDER %q, falling back to s3", STORAGE_PROVIDER)
+		return storage.NewS3(S3Config())
+	}
+}
+
+// IsShared reports whether the storage can be reached from repl pods
+func IsShared() bool {
+	return STORAGE_PROVIDER != "fs"
+}
+
+// S3Config is the S3-compatible endpoint used by core and the pod sync steps
+func S3Config() storage.S3Config {
+	return storage.S3Config{
+		Endpoint:  STORAGE_ENDPOINT,
+		Region:    STORAGE_REGION,
+		Bucket:    STORAGE_BUCKET,
+		AccessKey: STORAGE_ACCESS_KEY,
+		SecretKey: STORAGE_SECRET_KEY,
+	}
+}
+
+// ReplPrefix is where the files of a repl are kept
+func ReplPrefix(userName, replId string) string {
+	return fmt.Sprintf("repl/%s/%s/", userName, replId)
+}
+
+// CheckpointPrefix is where the checkpoints of a repl are kept, see package
+// checkpoint for the layout below it
+func CheckpointPrefix(userName, replId string) string {
+	return fmt.Sprintf("checkpoints/%s/%s/", userName, replId)
+}
+
+// ExportPrefix holds the snapshot of a repl while it is exported to GitHub
+func ExportPrefix(userName, exportId string) string {
+	return fmt.Sprintf("exports/%s/%s/", userName, exportId)
+}
+
+// TemplatePrefix is where the starter files of a template are kept
+func TemplatePrefix(template string) string {
+	return fmt.Sprintf("templates/%s/", template)
+}
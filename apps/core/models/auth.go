@@ -28,4 +28,6 @@ type TokenInfo struct {
 	Token     *oauth2.Token `json:"token"`
 	User      *User         `json:"user"`
 	ExpiresAt time.Time     `json:"expires_at"`
+	// Scopes granted to Token, "repo" is only requested when the user opts in
+	Scopes []string `json:"scopes,omitempty"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RestorePoint is a checkpoint of a repl's workspace taken by its runner
+type RestorePoint struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Size is the total size of the workspace files, in bytes
+	Size  int64 `json:"size"`
+	Files int   `json:"files"`
+}
@@ -1,9 +1,95 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"packages/replauth"
+)
+
+// ReplState is the lifecycle state of a repl's runtime (pod / process)
+type ReplState string
+
+const (
+	ReplStopped  ReplState = "stopped"
+	ReplStarting ReplState = "starting"
+	ReplRunning  ReplState = "running"
+	ReplStopping ReplState = "stopping"
+	ReplFailed   ReplState = "failed"
+)
+
+// ReplStates lists every lifecycle state
+var ReplStates = []ReplState{ReplStopped, ReplStarting, ReplRunning, ReplStopping, ReplFailed}
+
+var ErrInvalidTransition = errors.New("invalid repl state transition")
+
+// replTransitions lists the states each state is allowed to move to.
+//
+//	stopped → starting → running → stopping → stopped
+//
+// and any in-flight state may end up in failed, from where the repl can be
+// started again or cleaned up.
+var replTransitions = map[ReplState][]ReplState{
+	ReplStopped:  {ReplStarting},
+	ReplStarting: {ReplRunning, ReplStopping, ReplFailed},
+	ReplRunning:  {ReplStopping, ReplFailed},
+	ReplStopping: {ReplStopped, ReplFailed},
+	ReplFailed:   {ReplStarting, ReplStopping, ReplStopped},
+}
+
 type Repl struct {
 	User     string `json:"user"`
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Template string `json:"template"`
-	IsActive bool   `json:"isActive"`
+	// ParentId is the repl this one was forked from, if any
+	ParentId  string    `json:"parentId,omitempty"`
+	State     ReplState `json:"state"`
+	LastError string    `json:"lastError,omitempty"`
+	// StateTimestamps records when the repl last entered each state
+	StateTimestamps map[ReplState]time.Time `json:"stateTimestamps,omitempty"`
+	// TokenRevocations are handed to the runner and mcp server on start, so
+	// that revoked tokens stay revoked across restarts
+	TokenRevocations replauth.Revocations `json:"-"`
+}
+
+// Valid reports whether s is one of the known lifecycle states
+func (s ReplState) Valid() bool {
+	_, ok := replTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed
+func (s ReplState) CanTransitionTo(next ReplState) bool {
+	for _, allowed := range replTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition validates and applies a state change on the repl, stamping the
+// time it happened. reason is kept as LastError when moving to failed, and
+// LastError is cleared on every new start attempt.
+func (r *Repl) Transition(next ReplState, reason string, at time.Time) error {
+	if !r.State.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, r.State, next)
+	}
+
+	r.State = next
+	switch next {
+	case ReplFailed:
+		r.LastError = reason
+	case ReplStarting:
+		r.LastError = ""
+	}
+
+	if r.StateTimestamps == nil {
+		r.StateTimestamps = make(map[ReplState]time.Time)
+	}
+	r.StateTimestamps[next] = at
+
+	return nil
 }
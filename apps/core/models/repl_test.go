@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	allowed := map[ReplState]map[ReplState]bool{
+		ReplStopped:  {ReplStarting: true},
+		ReplStarting: {ReplRunning: true, ReplStopping: true, ReplFailed: true},
+		ReplRunning:  {ReplStopping: true, ReplFailed: true},
+		ReplStopping: {ReplStopped: true, ReplFailed: true},
+		ReplFailed:   {ReplStarting: true, ReplStopping: true, ReplStopped: true},
+	}
+
+	for _, from := range ReplStates {
+		for _, to := range ReplStates {
+			if got, want := from.CanTransitionTo(to), allowed[from][to]; got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	if ReplState("paused").Valid() {
+		t.Error("unknown state is valid")
+	}
+	if ReplState("paused").CanTransitionTo(ReplStarting) {
+		t.Error("unknown state can transition")
+	}
+}
+
+func TestTransition(t *testing.T) {
+	at := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		repl      Repl
+		next      ReplState
+		reason    string
+		wantErr   error
+		wantState ReplState
+		wantError string
+	}{
+		{name: "start", repl: Repl{State: ReplStopped}, next: ReplStarting, wantState: ReplStarting},
+		{name: "fail keeps reason", repl: Repl{State: ReplStarting}, next: ReplFailed, reason: "image pull", wantState: ReplFailed, wantError: "image pull"},
+		{name: "restart clears error", repl: Repl{State: ReplFailed, LastError: "image pull"}, next: ReplStarting, wantState: ReplStarting},
+		{name: "stop keeps error", repl: Repl{State: ReplFailed, LastError: "crash"}, next: ReplStopped, wantState: ReplStopped, wantError: "crash"},
+		{name: "invalid", repl: Repl{State: ReplStopped}, next: ReplRunning, wantErr: ErrInvalidTransition, wantState: ReplStopped},
+		{name: "same state", repl: Repl{State: ReplRunning}, next: ReplRunning, wantErr: ErrInvalidTransition, wantState: ReplRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repl := tt.repl
+			err := repl.Transition(tt.next, tt.reason, at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Transition() error = %v, want %v", err, tt.wantErr)
+			}
+			if repl.State != tt.wantState || repl.LastError != tt.wantError {
+				t.Errorf("got state %s, error %q, want %s, %q", repl.State, repl.LastError, tt.wantState, tt.wantError)
+			}
+			if err == nil && !repl.StateTimestamps[tt.next].Equal(at) {
+				t.Errorf("timestamp of %s = %v, want %v", tt.next, repl.StateTimestamps[tt.next], at)
+			}
+			if err != nil && repl.StateTimestamps != nil {
+				t.Errorf("rejected transition stamped %v", repl.StateTimestamps)
+			}
+		})
+	}
+}
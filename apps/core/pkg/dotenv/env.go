@@ -4,6 +4,9 @@ import (
 	"os"
 	"strings"
 	"syscall"
+
+	// Load .env before any package level EnvString call
+	_ "github.com/joho/godotenv/autoload"
 )
 
 func EnvString(key, fallback string) string {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"core/internal/oauth"
@@ -29,6 +30,7 @@ func githubLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	session.Values["state"] = state
+	session.Values["redirect"] = safeRedirect(r.URL.Query().Get("redirect"))
 	session.Options.MaxAge = 600 // 10 minutes
 	if err := session.Save(r, w); err != nil {
 		log.Printf("Error saving session: %v", err)
@@ -36,10 +38,26 @@ func githubLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url := oauth.GithubOauthConfig.AuthCodeURL(state)
+	// Write access to repositories is opt-in (?scope=repo), asked for when
+	// the user first exports a repl
+	var extraScopes []string
+	if r.URL.Query().Get("scope") == oauth.RepoScope {
+		extraScopes = append(extraScopes, oauth.RepoScope)
+	}
+
+	url := oauth.AuthCodeURL(state, extraScopes...)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
+// safeRedirect keeps the post-login redirect on the frontend, "" for anything
+// else than a path
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.Contains(redirect, "\\") {
+		return ""
+	}
+	return redirect
+}
+
 func githubCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	state := r.FormValue("state")
 
@@ -104,6 +122,7 @@ func githubCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		Token:     token,
 		User:      user,
 		ExpiresAt: token.Expiry,
+		Scopes:    oauth.GrantedScopes(token),
 	}
 
 	// Save session
@@ -113,11 +132,16 @@ func githubCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	redirect, _ := session.Values["redirect"].(string)
+	if redirect == "" {
+		redirect = "/dashboard"
+	}
+
 	// Clear state session
 	session.Options.MaxAge = -1
 	session.Save(r, w)
 
 	// Redirect to frontend
 	frontendURL := dotenv.EnvString("FRONTEND_URL", "http://localhost:3000")
-	http.Redirect(w, r, frontendURL+"/dashboard", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, frontendURL+redirect, http.StatusTemporaryRedirect)
 }
@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"core/cmd/middleware"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"packages/checkpoint"
+	"packages/utils/json"
+)
+
+type restoreRequest struct {
+	// Path limits the restore to a file or folder, the whole workspace by default
+	Path string `json:"path"`
+}
+
+// getRestorePoints lists the checkpoints of a repl, newest first
+func getRestorePoints(w http.ResponseWriter, r *http.Request, replStore store.ReplStore) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	points, err := replStore.GetRestorePoints(replId)
+	if err != nil {
+		log.Println(err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, map[string]any{
+		"replId":        replId,
+		"restorePoints": points,
+	})
+}
+
+// restoreCheckpoint brings the repl, or a single file or folder of it, back
+// to one of its restore points.
+func restoreCheckpoint(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+	checkpointId := r.PathValue("checkpointId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	var req restoreRequest
+	if err := json.ReadJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := manager.Restore(r.Context(), replId, checkpointId, req.Path); err != nil {
+		log.Println("Checkpoint Restore Failed", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, checkpoint.ErrNotFound), errors.Is(err, checkpoint.ErrPathNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, lifecycle.ErrBusy):
+			status = http.StatusConflict
+		}
+		json.WriteError(w, status, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, map[string]any{
+		"replId":       replId,
+		"checkpointId": checkpointId,
+		"path":         "/" + checkpoint.CleanPath(req.Path),
+	})
+}
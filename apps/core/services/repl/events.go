@@ -0,0 +1,118 @@
+package repl
+
+import (
+	encjson "encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"core/cmd/middleware"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"packages/utils/json"
+)
+
+// streamReplEvents streams the progress of a repl activation as Server-Sent
+// Events. Every lifecycle.Event is sent as a "progress" event, followed by a
+// final "done" event once the runner is ready or the start failed.
+//
+// Operations live in the core replica that started them, so with several
+// replicas this endpoint needs sticky sessions.
+func streamReplEvents(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	op, ok := manager.LatestOperation(replId)
+	if operationId := r.URL.Query().Get("operationId"); operationId != "" {
+		op, ok = manager.Operation(operationId)
+	}
+	if !ok || op.ReplID != replId {
+		json.WriteError(w, http.StatusNotFound, "No activation in progress for this Repl")
+		return
+	}
+
+	writeOperationEvents(w, r, op)
+}
+
+// streamImportEvents streams the progress of a GitHub import like
+// streamReplEvents does for activations. The repl only exists once the import
+// is done, so the operation is looked up by id.
+func streamImportEvents(w http.ResponseWriter, r *http.Request, manager *lifecycle.Manager) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	op, ok := manager.Operation(r.PathValue("operationId"))
+	if !ok || op.Owner == "" {
+		json.WriteError(w, http.StatusNotFound, "No import in progress with this id")
+		return
+	}
+	if op.Owner != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this import")
+		return
+	}
+
+	writeOperationEvents(w, r, op)
+}
+
+// writeOperationEvents sends every event of op as a "progress" event,
+// followed by a final "done" event once it has finished.
+func writeOperationEvents(w http.ResponseWriter, r *http.Request, op *lifecycle.Operation) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		json.WriteError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	history, updates, cancel := op.Subscribe()
+	defer cancel()
+
+	var last lifecycle.Event
+	send := func(event string, data lifecycle.Event) {
+		payload, _ := encjson.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+		last = data
+	}
+
+	for _, event := range history {
+		send("progress", event)
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-updates:
+			if !open {
+				// Updates may have been dropped for a slow client, always
+				// end with the final state of the operation.
+				if final := op.Last(); final != last {
+					send("progress", final)
+				}
+				send("done", op.Last())
+				return
+			}
+			send("progress", event)
+		}
+	}
+}
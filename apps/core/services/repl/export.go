@@ -0,0 +1,135 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"core/cmd/middleware"
+	"core/internal/githubrepo"
+	"core/internal/lifecycle"
+	"core/internal/oauth"
+	"core/internal/store"
+	"core/internal/workspace"
+	"packages/storage"
+	"packages/utils/json"
+
+	"github.com/google/uuid"
+)
+
+// exportTimeout bounds an export, from the workspace snapshot to the branch
+// update
+const exportTimeout = 5 * time.Minute
+
+type exportReplRequest struct {
+	// Repo is a repository URL, owner/repo, or a bare name for a repository
+	// of the user
+	Repo string `json:"repo"`
+	// Branch defaults to the default branch of the repository
+	Branch  string `json:"branch"`
+	Message string `json:"message"`
+	// Create makes the repository when it does not exist, Private applies
+	// to it
+	Create  bool `json:"create"`
+	Private bool `json:"private"`
+}
+
+// exportRepl commits the current files of a repl to a GitHub repository.
+// Writing to repositories needs the repo scope, which users grant by logging
+// in again through the returned authorizeUrl.
+func exportRepl(w http.ResponseWriter, r *http.Request, workspaces storage.WorkspaceStorage, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	replId := r.PathValue("replId")
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	token, ok := middleware.GetTokenFromContext(r.Context())
+	if !ok {
+		json.WriteError(w, http.StatusForbidden, "Exporting to GitHub needs a GitHub login")
+		return
+	}
+	if !slices.Contains(middleware.GetScopesFromContext(r.Context()), oauth.RepoScope) {
+		json.WriteJSON(w, http.StatusForbidden, map[string]string{
+			"error":        "DevEx needs access to your repositories to export to GitHub",
+			"authorizeUrl": fmt.Sprintf("/auth/github/login?scope=%s", oauth.RepoScope),
+		})
+		return
+	}
+
+	var req exportReplRequest
+	if err := json.ReadJSON(r, &req); err != nil {
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var repo githubrepo.Repo
+	if req.Repo != "" && !strings.ContainsAny(req.Repo, "/:") {
+		repo = githubrepo.Repo{Owner: user.Login, Name: req.Repo}
+	} else if repo, err = githubrepo.ParseURL(req.Repo); err != nil {
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Branch == "" {
+		req.Branch = repo.Ref
+	}
+	if req.Message == "" {
+		req.Message = fmt.Sprintf("Export %s from DevEx", repl.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	// Export a snapshot, the repl may keep changing while it is uploaded
+	prefix := workspace.ExportPrefix(userName, uuid.New().String())
+	defer func() {
+		if err := workspaces.DeletePrefix(context.WithoutCancel(ctx), prefix); err != nil {
+			log.Println("Cleaning up export files failed: ", err)
+		}
+	}()
+	if err := manager.CopyWorkspace(ctx, replId, prefix); err != nil {
+		log.Println("Export CopyWorkspace is giving Err: ", err)
+		writeLifecycleError(w, err)
+		return
+	}
+
+	result, err := githubrepo.Export(ctx, githubrepo.NewClient(ctx, token), repo, workspaces, prefix, githubrepo.ExportOptions{
+		Branch:  req.Branch,
+		Message: req.Message,
+		Create:  req.Create,
+		Private: req.Private,
+	})
+	switch {
+	case errors.Is(err, githubrepo.ErrRepoNotFound):
+		json.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, githubrepo.ErrNoPushAccess):
+		json.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, githubrepo.ErrEmptyWorkspace):
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		log.Printf("Export of repl %s to %s failed: %v", replId, repo, err)
+		json.WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	log.Printf("✅ Exported repl %s to %s (%s)", replId, result.Repo, result.Commit)
+	json.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,96 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"core/cmd/middleware"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"core/internal/workspace"
+	"core/pkg/dotenv"
+	"packages/storage"
+	"packages/utils/json"
+
+	"github.com/google/uuid"
+)
+
+// FORK_SOURCE_USERS lists (comma separated) the users whose repls anybody can
+// fork, e.g. the account holding a team's starter repls. Everyone can fork
+// their own repls.
+var FORK_SOURCE_USERS = dotenv.EnvString("FORK_SOURCE_USERS", "")
+
+type forkReplRequest struct {
+	// ReplName defaults to the name of the forked repl
+	ReplName string `json:"replName"`
+}
+
+// forkRepl creates a repl for the caller holding a copy of another repl's files
+func forkRepl(w http.ResponseWriter, r *http.Request, workspaces storage.WorkspaceStorage, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	parent, err := replStore.GetRepl(r.PathValue("replId"))
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if parent.User != userName && !canForkFrom(parent.User) {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	var req forkReplRequest
+	if err := json.ReadJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ReplName == "" {
+		req.ReplName = parent.Name
+	}
+
+	if replLimitReached(replStore, userName) {
+		log.Println("Cannot Fork Repl (Free Account Limit Reached): ")
+		json.WriteError(w, http.StatusInternalServerError, "Free Account Limit Reached")
+		return
+	}
+
+	replId := fmt.Sprintf("repl-%s", uuid.New().String())
+	destinationPrefix := workspace.ReplPrefix(userName, replId)
+
+	if err := manager.CopyWorkspace(r.Context(), parent.Id, destinationPrefix); err != nil {
+		log.Println("Fork CopyWorkspace is giving Err: ", err)
+		writeLifecycleError(w, err)
+		return
+	}
+
+	if err := replStore.CreateRepl(parent.Template, userName, req.ReplName, replId, parent.Id); err != nil {
+		log.Println(err)
+		if err := workspaces.DeletePrefix(r.Context(), destinationPrefix); err != nil {
+			log.Println("Cleaning up fork files failed: ", err)
+		}
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("✅ Forked repl %s into %s for %s", parent.Id, replId, userName)
+	json.WriteJSON(w, http.StatusOK, repl)
+}
+
+func canForkFrom(userName string) bool {
+	return slices.ContainsFunc(strings.Split(FORK_SOURCE_USERS, ","), func(u string) bool {
+		return strings.EqualFold(strings.TrimSpace(u), userName)
+	})
+}
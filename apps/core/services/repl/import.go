@@ -0,0 +1,101 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"core/cmd/middleware"
+	"core/internal/githubrepo"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"core/internal/workspace"
+	"core/models"
+	"packages/storage"
+	"packages/utils/json"
+
+	"github.com/google/uuid"
+)
+
+type importReplRequest struct {
+	RepoURL string `json:"repoUrl"`
+	// Ref is a branch, tag or commit, the default branch when empty
+	Ref string `json:"ref"`
+	// ReplName defaults to the repository name
+	ReplName string `json:"replName"`
+	// Template is detected from the repository files when empty
+	Template string `json:"template"`
+}
+
+// importRepl creates a repl from a GitHub repository. Private repositories
+// are read with the user's GitHub token. The import runs in the background,
+// its progress is streamed by GET /import/{operationId}/events.
+func importRepl(w http.ResponseWriter, r *http.Request, workspaces storage.WorkspaceStorage, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	var req importReplRequest
+	if err := json.ReadJSON(r, &req); err != nil {
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	repo, err := githubrepo.ParseURL(req.RepoURL)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Ref != "" {
+		repo.Ref = req.Ref
+	}
+	if req.ReplName == "" {
+		req.ReplName = repo.Name
+	}
+	if _, ok := models.TemplateConfigs[req.Template]; req.Template != "" && !ok {
+		json.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unknown template %q", req.Template))
+		return
+	}
+
+	if replLimitReached(replStore, userName) {
+		log.Println("Cannot Import Repl (Free Account Limit Reached): ")
+		json.WriteError(w, http.StatusInternalServerError, "Free Account Limit Reached")
+		return
+	}
+
+	token, _ := middleware.GetTokenFromContext(r.Context())
+	replId := fmt.Sprintf("repl-%s", uuid.New().String())
+
+	op := manager.Import(replId, userName, func(ctx context.Context, progress func(lifecycle.Step, string)) error {
+		prefix := workspace.ReplPrefix(userName, replId)
+
+		template, err := githubrepo.Import(ctx, githubrepo.NewClient(ctx, token), repo, workspaces, prefix, githubrepo.ImportOptions{
+			Template: req.Template,
+			OnProgress: func(stage, message string) {
+				progress(lifecycle.Step(stage), message)
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := replStore.CreateRepl(template, userName, req.ReplName, replId, ""); err != nil {
+			if cleanupErr := workspaces.DeletePrefix(ctx, prefix); cleanupErr != nil {
+				log.Println("Cleaning up imported files failed: ", cleanupErr)
+			}
+			return err
+		}
+
+		log.Printf("✅ Imported %s into repl %s for %s", repo, replId, userName)
+		return nil
+	})
+
+	json.WriteJSON(w, http.StatusAccepted, map[string]any{
+		"replId":      replId,
+		"replName":    req.ReplName,
+		"operationId": op.ID,
+		"events":      fmt.Sprintf("/api/repl/import/%s/events", op.ID),
+	})
+}
@@ -0,0 +1,25 @@
+package repl
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"core/models"
+	"packages/utils/json"
+)
+
+// writeLifecycleError maps errors from lifecycle.Manager to a response
+func writeLifecycleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, lifecycle.ErrBusy), errors.Is(err, models.ErrInvalidTransition):
+		json.WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, store.ErrReplNotFound):
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+	default:
+		log.Println("Repl lifecycle operation failed:", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -7,17 +7,19 @@ import (
 	"strings"
 
 	"core/cmd/middleware"
-	"core/internal/k8s"
-	"core/internal/redis"
-	"core/internal/s3"
+	"core/internal/lifecycle"
+	"core/internal/orchestrator"
+	"core/internal/store"
+	"core/internal/tokens"
+	"core/internal/workspace"
 	"core/models"
-	"core/pkg/dotenv"
+	"packages/storage"
 	"packages/utils/json"
 
 	"github.com/google/uuid"
 )
 
-func NewHandler(s3Client *s3.S3Client, rds *redis.Redis) http.Handler {
+func NewHandler(workspaces storage.WorkspaceStorage, replStore store.ReplStore, manager *lifecycle.Manager, orch orchestrator.Orchestrator) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /test", func(w http.ResponseWriter, r *http.Request) {
@@ -26,25 +28,58 @@ func NewHandler(s3Client *s3.S3Client, rds *redis.Redis) http.Handler {
 	})
 
 	mux.HandleFunc("POST /new", func(w http.ResponseWriter, r *http.Request) {
-		newRepl(w, r, s3Client, rds)
+		newRepl(w, r, workspaces, replStore)
+	})
+	mux.HandleFunc("POST /import", func(w http.ResponseWriter, r *http.Request) {
+		importRepl(w, r, workspaces, replStore, manager)
+	})
+	mux.HandleFunc("GET /import/{operationId}/events", func(w http.ResponseWriter, r *http.Request) {
+		streamImportEvents(w, r, manager)
 	})
 	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		getUserRepls(w, r, rds)
+		getUserRepls(w, r, replStore)
 	})
 	mux.HandleFunc("GET /session/{replId}", func(w http.ResponseWriter, r *http.Request) {
-		activateRepl(w, r, rds)
+		activateRepl(w, r, replStore, manager)
+	})
+	mux.HandleFunc("POST /session/{replId}/token", func(w http.ResponseWriter, r *http.Request) {
+		issueToken(w, r, replStore)
+	})
+	mux.HandleFunc("POST /session/{replId}/token/revoke", func(w http.ResponseWriter, r *http.Request) {
+		revokeToken(w, r, replStore, manager)
+	})
+	mux.HandleFunc("GET /session/{replId}/events", func(w http.ResponseWriter, r *http.Request) {
+		streamReplEvents(w, r, replStore, manager)
+	})
+	mux.HandleFunc("GET /session/{replId}/status", func(w http.ResponseWriter, r *http.Request) {
+		replStatus(w, r, replStore, orch)
+	})
+	mux.HandleFunc("GET /session/{replId}/logs", func(w http.ResponseWriter, r *http.Request) {
+		replLogs(w, r, replStore, orch)
 	})
 	mux.HandleFunc("DELETE /session/{replId}", func(w http.ResponseWriter, r *http.Request) {
-		deactivateRepl(w, r, rds)
+		deactivateRepl(w, r, replStore, manager)
 	})
 	mux.HandleFunc("DELETE /{replId}", func(w http.ResponseWriter, r *http.Request) {
-		deleteRepl(w, r, s3Client, rds)
+		deleteRepl(w, r, workspaces, replStore)
+	})
+	mux.HandleFunc("POST /{replId}/fork", func(w http.ResponseWriter, r *http.Request) {
+		forkRepl(w, r, workspaces, replStore, manager)
+	})
+	mux.HandleFunc("POST /{replId}/export", func(w http.ResponseWriter, r *http.Request) {
+		exportRepl(w, r, workspaces, replStore, manager)
+	})
+	mux.HandleFunc("GET /session/{replId}/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		getRestorePoints(w, r, replStore)
+	})
+	mux.HandleFunc("POST /session/{replId}/checkpoints/{checkpointId}/restore", func(w http.ResponseWriter, r *http.Request) {
+		restoreCheckpoint(w, r, replStore, manager)
 	})
 
 	return mux
 }
 
-func newRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, rds *redis.Redis) {
+func newRepl(w http.ResponseWriter, r *http.Request, workspaces storage.WorkspaceStorage, replStore store.ReplStore) {
 
 	var repl *newReplRequest
 	if err := json.ReadJSON(r, &repl); err != nil {
@@ -56,7 +91,7 @@ func newRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, rds
 	user, _ := middleware.GetUserFromContext(r.Context())
 	userName := strings.ToLower(user.Login)
 
-	if userRepls, err := rds.GetUserRepls(userName); err == nil && len(userRepls) == 2 {
+	if replLimitReached(replStore, userName) {
 		log.Println("Cannot Create More Repls (Free Account Limit Reached): ")
 		json.WriteError(w, http.StatusInternalServerError, "Free Account Limit Reached")
 		return
@@ -66,17 +101,17 @@ func newRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, rds
 	id := uuid.New()
 	replId := fmt.Sprintf("repl-%s", strings.TrimSpace(id.String()))
 
-	sourcePrefix := fmt.Sprintf("templates/%s", repl.Template)
-	destinationPrefix := fmt.Sprintf("repl/%s/%s/", userName, replId)
+	sourcePrefix := workspace.TemplatePrefix(repl.Template)
+	destinationPrefix := workspace.ReplPrefix(userName, replId)
 
-	if err := s3Client.CopyFolder(sourcePrefix, destinationPrefix); err != nil {
-		log.Println("S3 CopyTemplate is giving Err: ", err)
+	if err := workspaces.CopyPrefix(r.Context(), sourcePrefix, destinationPrefix); err != nil {
+		log.Println("Storage CopyTemplate is giving Err: ", err)
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// Create Repl in Store
-	if err := rds.CreateRepl(repl.Template, userName, repl.ReplName, replId); err != nil {
+	if err := replStore.CreateRepl(repl.Template, userName, repl.ReplName, replId, ""); err != nil {
 		log.Println(err)
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -85,14 +120,22 @@ func newRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, rds
 	json.WriteJSON(w, http.StatusOK, "Success")
 }
 
-func deleteRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, rds *redis.Redis) {
+// maxUserRepls is the free account limit on repls per user
+const maxUserRepls = 2
+
+func replLimitReached(replStore store.ReplStore, userName string) bool {
+	userRepls, err := replStore.GetUserRepls(userName)
+	return err == nil && len(userRepls) >= maxUserRepls
+}
+
+func deleteRepl(w http.ResponseWriter, r *http.Request, workspaces storage.WorkspaceStorage, replStore store.ReplStore) {
 
 	user, _ := middleware.GetUserFromContext(r.Context())
 	userName := strings.ToLower(user.Login)
 
 	replId := r.PathValue("replId")
 
-	repl, err := rds.GetRepl(replId)
+	repl, err := replStore.GetRepl(replId)
 	if err != nil {
 		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
 		return
@@ -102,22 +145,21 @@ func deleteRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, r
 		return
 	}
 
-	if repl.IsActive == true {
-		if err := rds.DeleteReplSession(replId); err != nil {
-			json.WriteError(w, http.StatusInternalServerError, "Unable to Create Repl Session")
-			return
-		}
+	if repl.State != models.ReplStopped && repl.State != models.ReplFailed {
+		json.WriteError(w, http.StatusConflict, fmt.Sprintf("Repl is %s, stop it before deleting", repl.State))
+		return
 	}
 
-	destination := fmt.Sprintf("repl/%s/%s/", userName, repl.Id)
-	if err := s3Client.DeleteFolder(destination); err != nil {
-		log.Println("Delete S3 is giving Err: ", err)
-		json.WriteError(w, http.StatusInternalServerError, err.Error())
-		return
+	for _, prefix := range []string{workspace.ReplPrefix(userName, repl.Id), workspace.CheckpointPrefix(userName, repl.Id)} {
+		if err := workspaces.DeletePrefix(r.Context(), prefix); err != nil {
+			log.Println("Delete Storage is giving Err: ", err)
+			json.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
 	// Create Repl in Store
-	if err := rds.DeleteRepl(repl.Id); err != nil {
+	if err := replStore.DeleteRepl(repl.Id); err != nil {
 		log.Println(err)
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -126,12 +168,12 @@ func deleteRepl(w http.ResponseWriter, r *http.Request, s3Client *s3.S3Client, r
 	json.WriteJSON(w, http.StatusOK, "Success")
 }
 
-func getUserRepls(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
+func getUserRepls(w http.ResponseWriter, r *http.Request, replStore store.ReplStore) {
 
 	user, _ := middleware.GetUserFromContext(r.Context())
 	userName := strings.ToLower(user.Login)
 
-	replIds, err := rds.GetUserRepls(userName)
+	replIds, err := replStore.GetUserRepls(userName)
 	if err != nil {
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -139,7 +181,7 @@ func getUserRepls(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
 
 	var repls []models.Repl
 	for _, id := range replIds {
-		repl, err := rds.GetRepl(id)
+		repl, err := replStore.GetRepl(id)
 		if err != nil {
 			log.Printf("This replId: %s doesn't exists for %s user", id, userName)
 			continue
@@ -150,14 +192,14 @@ func getUserRepls(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
 	json.WriteJSON(w, http.StatusOK, repls)
 }
 
-func activateRepl(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
+func activateRepl(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
 
 	user, _ := middleware.GetUserFromContext(r.Context())
 	userName := strings.ToLower(user.Login)
 
 	replId := r.PathValue("replId")
 
-	repl, err := rds.GetRepl(replId)
+	repl, err := replStore.GetRepl(replId)
 	if err != nil {
 		log.Println(err)
 		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
@@ -168,37 +210,45 @@ func activateRepl(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
 		return
 	}
 
-	if err := rds.CreateReplSession(replId); err != nil {
-		json.WriteError(w, http.StatusInternalServerError, "Unable to Create Repl Session")
-	}
-
-	if err := k8s.CreateReplDeploymentAndService(userName, replId, repl.Template); err != nil {
-		log.Println("K8s Deployment Failed", err)
-		json.WriteError(w, http.StatusInternalServerError, err.Error())
+	op, err := manager.Start(r.Context(), replId)
+	if err != nil {
+		writeLifecycleError(w, err)
 		return
 	}
 
-	url := fmt.Sprintf("https://%s/%s/ping", dotenv.EnvString("RUNNER_CLUSTER_IP", "localhost:8081"), replId)
-
-	if err := pingRunner(url); err != nil {
+	// The endpoints of the repl take it, refreshed with POST /session/{replId}/token
+	token, claims, err := tokens.Issue(replId, userName)
+	if err != nil {
+		log.Println("Issuing Repl Token Failed", err)
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	json.WriteJSON(w, http.StatusOK, map[string]string{
-		"replId":   replId,
-		"replName": repl.Name,
+	// Activation continues in the background, progress is streamed by
+	// GET /session/{replId}/events?operationId=...
+	status := http.StatusAccepted
+	if op.Done() {
+		status = http.StatusOK
+	}
+	json.WriteJSON(w, status, map[string]any{
+		"replId":      replId,
+		"replName":    repl.Name,
+		"operationId": op.ID,
+		"progress":    op.Last(),
+		"endpoint":    manager.Endpoint(replId),
+		"token":       token,
+		"expiresAt":   claims.Expiry(),
 	})
 }
 
-func deactivateRepl(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
+func deactivateRepl(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
 
 	user, _ := middleware.GetUserFromContext(r.Context())
 	userName := strings.ToLower(user.Login)
 
 	replId := r.PathValue("replId")
 
-	repl, err := rds.GetRepl(replId)
+	repl, err := replStore.GetRepl(replId)
 	if err != nil {
 		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
 		return
@@ -208,13 +258,8 @@ func deactivateRepl(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
 		return
 	}
 
-	if err := rds.DeleteReplSession(replId); err != nil {
-		json.WriteError(w, http.StatusInternalServerError, "Unable to Create Repl Session")
-	}
-
-	if err := k8s.DeleteReplDeploymentAndService(userName, replId); err != nil {
-		log.Println("k8s Repl Deletion Failed", err)
-		json.WriteError(w, http.StatusInternalServerError, err.Error())
+	if _, err := manager.Stop(r.Context(), replId); err != nil {
+		writeLifecycleError(w, err)
 		return
 	}
 
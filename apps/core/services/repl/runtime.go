@@ -0,0 +1,120 @@
+package repl
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"core/cmd/middleware"
+	"core/internal/orchestrator"
+	"core/internal/store"
+	"packages/utils/json"
+)
+
+// replStatus reports both the stored lifecycle state of a repl and what the
+// orchestrator actually has running for it.
+func replStatus(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, orch orchestrator.Orchestrator) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	status, err := orch.Status(r.Context(), replId)
+	if err != nil {
+		log.Println("Orchestrator Status Failed", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, map[string]any{
+		"replId":       replId,
+		"state":        repl.State,
+		"lastError":    repl.LastError,
+		"orchestrator": status,
+		"endpoint":     orch.Endpoint(replId),
+	})
+}
+
+// replLogs streams the logs of the repl's runner (default) or mcp server as
+// plain text. Query params: container=runner|mcp, tail=<lines>, follow=true.
+func replLogs(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, orch orchestrator.Orchestrator) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	query := r.URL.Query()
+	opts := orchestrator.LogOptions{
+		Container: query.Get("container"),
+		Follow:    query.Get("follow") == "true",
+	}
+	if tail := query.Get("tail"); tail != "" {
+		lines, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || lines < 0 {
+			json.WriteError(w, http.StatusBadRequest, "tail must be a positive number of lines")
+			return
+		}
+		opts.TailLines = lines
+	}
+
+	logs, err := orch.Logs(r.Context(), replId, opts)
+	if errors.Is(err, orchestrator.ErrReplNotRunning) {
+		json.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println("Orchestrator Logs Failed", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Println("Reading repl logs failed:", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"core/cmd/middleware"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"core/internal/tokens"
+	"packages/replauth"
+	"packages/utils/json"
+)
+
+type revokeTokenRequest struct {
+	// TokenId and ExpiresAt revoke a single token, every token of the repl
+	// issued so far by default
+	TokenId   string    `json:"tokenId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// issueToken mints the access token of the runner, app previews and mcp
+// server of a repl for its owner, the frontend calls it again before the
+// token expires
+func issueToken(w http.ResponseWriter, r *http.Request, replStore store.ReplStore) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	token, claims, err := tokens.Issue(replId, userName)
+	if err != nil {
+		log.Println("Issuing Repl Token Failed", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, map[string]any{
+		"replId":    replId,
+		"token":     token,
+		"tokenId":   claims.ID,
+		"expiresAt": claims.Expiry(),
+	})
+}
+
+// revokeToken has a repl reject one or all of its tokens before they
+// expire, e.g. a leaked preview link. The owner gets a new one with
+// issueToken.
+func revokeToken(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
+
+	user, _ := middleware.GetUserFromContext(r.Context())
+	userName := strings.ToLower(user.Login)
+
+	replId := r.PathValue("replId")
+
+	repl, err := replStore.GetRepl(replId)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+		return
+	}
+	if repl.User != userName {
+		json.WriteError(w, http.StatusUnauthorized, "This User doesn't have access to this Repl")
+		return
+	}
+
+	var req revokeTokenRequest
+	if err := json.ReadJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TokenId != "" && req.ExpiresAt.IsZero() {
+		json.WriteError(w, http.StatusBadRequest, "expiresAt is required with tokenId")
+		return
+	}
+
+	revoke := replauth.RevokeRequest{TokenId: req.TokenId, ExpiresAt: req.ExpiresAt}
+	if req.TokenId == "" {
+		revoke.Before = time.Now()
+	}
+	if err := manager.RevokeTokens(r.Context(), replId, revoke); err != nil {
+		log.Println("Revoking Repl Tokens Failed", err)
+		json.WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, "Success")
+}
@@ -1,41 +1,93 @@
 package runner
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
-	"core/internal/k8s"
-	"core/internal/redis"
+	"core/internal/lifecycle"
+	"core/internal/store"
+	"core/internal/tokens"
+	"core/models"
+	"packages/storage"
 	"packages/utils/json"
 )
 
-func NewHandler(rds *redis.Redis) http.Handler {
+func NewHandler(replStore store.ReplStore, manager *lifecycle.Manager, workspaces storage.WorkspaceStorage) http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("DELETE /{replId}", func(w http.ResponseWriter, r *http.Request) {
-		endReplSession(w, r, rds)
-	})
+	// Callbacks of the runner, signed with the key of its repl
+	mux.HandleFunc("DELETE /{replId}", requireRunner(func(w http.ResponseWriter, r *http.Request) {
+		endReplSession(w, r, replStore, manager)
+	}))
+	mux.HandleFunc("POST /{replId}/checkpoints", requireRunner(func(w http.ResponseWriter, r *http.Request) {
+		addRestorePoint(w, r, replStore)
+	}))
+
+	// The runner holds no storage credentials, it goes through core
+	mux.HandleFunc("GET /{replId}/storage/objects", requireRunner(func(w http.ResponseWriter, r *http.Request) {
+		listObjects(w, r, replStore, workspaces)
+	}))
+	mux.HandleFunc("POST /{replId}/storage/presign", requireRunner(func(w http.ResponseWriter, r *http.Request) {
+		presignObject(w, r, replStore, workspaces)
+	}))
 
 	return mux
 }
 
-func endReplSession(w http.ResponseWriter, r *http.Request, rds *redis.Redis) {
+// requireRunner serves next the requests signed by the runner of the repl
+func requireRunner(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := tokens.VerifyRunner(r, r.PathValue("replId")); err != nil {
+			json.WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+func endReplSession(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, manager *lifecycle.Manager) {
 
 	replId := r.PathValue("replId")
 
-	repl, err := rds.GetRepl(replId)
+	repl, err := replStore.GetRepl(replId)
 	if err != nil {
 		json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
 		return
 	}
-	userName := repl.User
 
-	if err := rds.DeleteReplSession(replId); err != nil {
-		json.WriteError(w, http.StatusInternalServerError, "Unable to Create Repl Session")
+	// The runner calls this on inactivity; stopping is idempotent so a repl
+	// the user already stopped from the dashboard is left as is.
+	if _, err := manager.Stop(r.Context(), repl.Id); err != nil {
+		log.Println("Repl Shutdown Failed", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, lifecycle.ErrBusy) || errors.Is(err, models.ErrInvalidTransition) {
+			status = http.StatusConflict
+		}
+		json.WriteError(w, status, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, "Success")
+}
+
+// addRestorePoint records a checkpoint the runner just took
+func addRestorePoint(w http.ResponseWriter, r *http.Request, replStore store.ReplStore) {
+
+	replId := r.PathValue("replId")
+
+	var point models.RestorePoint
+	if err := json.ReadJSON(r, &point); err != nil || point.Id == "" || point.CreatedAt.IsZero() {
+		json.WriteError(w, http.StatusBadRequest, "Invalid restore point")
+		return
 	}
 
-	if err := k8s.DeleteReplDeploymentAndService(userName, replId); err != nil {
-		log.Println("k8s Repl Deletion Failed", err)
+	if err := replStore.AddRestorePoint(replId, point); err != nil {
+		if errors.Is(err, store.ErrReplNotFound) {
+			json.WriteError(w, http.StatusBadRequest, "This Repl Id doesn't exists")
+			return
+		}
+		log.Println("Failed to add restore point", err)
 		json.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
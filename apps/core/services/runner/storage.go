@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"core/internal/store"
+	"core/internal/workspace"
+	"core/models"
+	"packages/storage"
+	"packages/utils/json"
+)
+
+// presignTTL is how long a presigned request can be started, the runner
+// makes it right away
+const presignTTL = 5 * time.Minute
+
+// listObjects lists the objects under a prefix of the repl for its runner
+func listObjects(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, workspaces storage.WorkspaceStorage) {
+	repl, ok := runnerRepl(w, r, replStore)
+	if !ok {
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	if !replKey(repl, prefix) {
+		json.WriteError(w, http.StatusForbidden, "Prefix is outside of the repl")
+		return
+	}
+
+	objects, err := workspaces.List(r.Context(), prefix)
+	if err != nil {
+		log.Println("Error listing objects for runner:", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if objects == nil {
+		objects = []storage.Object{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, objects)
+}
+
+// presignObject signs a single request of the runner on an object of the repl
+func presignObject(w http.ResponseWriter, r *http.Request, replStore store.ReplStore, workspaces storage.WorkspaceStorage) {
+	repl, ok := runnerRepl(w, r, replStore)
+	if !ok {
+		return
+	}
+
+	var req storage.PresignRequest
+	if err := json.ReadJSON(r, &req); err != nil {
+		json.WriteError(w, http.StatusBadRequest, "Invalid presign request")
+		return
+	}
+	if !slices.Contains([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, req.Method) || req.Size < 0 {
+		json.WriteError(w, http.StatusBadRequest, "Invalid presign request")
+		return
+	}
+	if !replKey(repl, req.Key) || strings.HasSuffix(req.Key, "/") {
+		json.WriteError(w, http.StatusForbidden, "Key is outside of the repl")
+		return
+	}
+
+	presigner, ok := workspaces.(storage.Presigner)
+	if !ok {
+		json.WriteError(w, http.StatusNotImplemented, "Storage can not presign requests")
+		return
+	}
+
+	presigned, err := presigner.Presign(r.Context(), req, presignTTL)
+	if err != nil {
+		log.Println("Error presigning request for runner:", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, presigned)
+}
+
+func runnerRepl(w http.ResponseWriter, r *http.Request, replStore store.ReplStore) (models.Repl, bool) {
+	repl, err := replStore.GetRepl(r.PathValue("replId"))
+	if err != nil {
+		if errors.Is(err, store.ErrReplNotFound) {
+			json.WriteError(w, http.StatusNotFound, "This Repl Id doesn't exists")
+			return repl, false
+		}
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return repl, false
+	}
+	return repl, true
+}
+
+// replKey tells whether key (or prefix) lies in the workspace or the
+// checkpoints of repl, the only places its runner may touch
+func replKey(repl models.Repl, key string) bool {
+	if slices.Contains(strings.Split(key, "/"), "..") {
+		return false
+	}
+	return strings.HasPrefix(key, workspace.ReplPrefix(repl.User, repl.Id)) ||
+		strings.HasPrefix(key, workspace.CheckpointPrefix(repl.User, repl.Id))
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"testing"
+
+	"core/models"
+)
+
+func TestReplKey(t *testing.T) {
+	repl := models.Repl{Id: "repl-1", User: "octocat"}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "repl/octocat/repl-1/", want: true},
+		{key: "repl/octocat/repl-1/src/main.go", want: true},
+		{key: "checkpoints/octocat/repl-1/blobs/ab/abcdef", want: true},
+		{key: ""},
+		{key: "repl/octocat/"},
+		{key: "repl/octocat/repl-10/main.go"},
+		{key: "repl/octocat/repl-2/main.go"},
+		{key: "checkpoints/octocat/repl-2/manifests/1.json"},
+		{key: "templates/node/index.js"},
+		{key: "repl/octocat/repl-1/../repl-2/main.go"},
+		{key: "repl/octocat/repl-1/.."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := replKey(repl, tt.key); got != tt.want {
+				t.Errorf("replKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"log"
 	"mcp/internal/gRPC"
 	"mcp/internal/tools"
+	"mcp/pkg/dotenv"
 	"net/http"
 
+	"packages/replauth"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -14,6 +17,8 @@ type McpServer struct {
 	replClient *gRPC.ReplClient
 	tools      *tools.ToolsHandler
 	httpAddr   string
+	// tokens is nil when the server takes no access tokens
+	tokens *replauth.Verifier
 }
 
 func NewMcpServer() *McpServer {
@@ -25,7 +30,8 @@ func NewMcpServer() *McpServer {
 		Version: "v1.0.0",
 	}, nil)
 
-	replClient, err := gRPC.NewReplClient()
+	key := replKey()
+	replClient, err := gRPC.NewReplClient(key, REPL_ID)
 	if err != nil {
 		log.Println("repl client error:", err)
 	}
@@ -42,6 +48,11 @@ func NewMcpServer() *McpServer {
 		Description: "Read the contents of a file in the workspace",
 	}, tools.ReadFile)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "run_command",
+		Description: "Run a shell command in the workspace and return its exit code and output",
+	}, tools.RunCommand)
+
 	// File System Tools
 	// mcp.AddTool(server, &mcp.Tool{
 	// 	Name:        "read_file",
@@ -253,7 +264,8 @@ func NewMcpServer() *McpServer {
 	return &McpServer{
 		server:   server,
 		tools:    tools,
-		httpAddr: ":8080",
+		httpAddr: ":" + dotenv.EnvString("PORT", "8080"),
+		tokens:   newVerifier(key),
 	}
 }
 
@@ -265,6 +277,11 @@ func (m *McpServer) Run() error {
 		return m.server
 	}, nil)
 
-	http.ListenAndServe(m.httpAddr, handler)
+	router := http.NewServeMux()
+	router.Handle("/", handler)
+	// called by core to revoke access tokens before they expire
+	router.HandleFunc("POST /api/v1/auth/revoke", m.tokens.RevokeHandler())
+
+	http.ListenAndServe(m.httpAddr, m.tokens.Middleware(router, nil, nil))
 	return nil
 }
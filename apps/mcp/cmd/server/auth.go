@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+
+	"mcp/pkg/dotenv"
+	"packages/replauth"
+)
+
+var (
+	REPL_ID = dotenv.EnvString("REPL_ID", "")
+	// REPL_TOKEN_KEY is the hex signing key of the access tokens of this
+	// repl, handed over by core. The server is open to anyone when unset.
+	REPL_TOKEN_KEY = dotenv.EnvString("REPL_TOKEN_KEY", "")
+	// REPL_TOKEN_REVOCATIONS are the tokens core revoked before the server
+	// started, as JSON replauth.Revocations
+	REPL_TOKEN_REVOCATIONS = dotenv.EnvString("REPL_TOKEN_REVOCATIONS", "")
+)
+
+// replKey decodes REPL_TOKEN_KEY, nil when it is unset. An invalid key is
+// fatal, the server must not end up open to anyone.
+func replKey() []byte {
+	if REPL_TOKEN_KEY == "" {
+		log.Println("⚠️ REPL_TOKEN_KEY is not set, the MCP server is open to anyone")
+		return nil
+	}
+	key, err := hex.DecodeString(REPL_TOKEN_KEY)
+	if err != nil || len(key) == 0 {
+		log.Fatal("Invalid REPL_TOKEN_KEY: not a hex key")
+	}
+	return key
+}
+
+// newVerifier checks the access tokens signed with key, nil when there is
+// none
+func newVerifier(key []byte) *replauth.Verifier {
+	if key == nil {
+		return nil
+	}
+	verifier := replauth.NewVerifier(key, REPL_ID)
+
+	if REPL_TOKEN_REVOCATIONS != "" {
+		var revocations replauth.Revocations
+		if err := json.Unmarshal([]byte(REPL_TOKEN_REVOCATIONS), &revocations); err != nil {
+			log.Fatalf("Invalid REPL_TOKEN_REVOCATIONS: %v", err)
+		}
+		verifier.Apply(revocations)
+	}
+	return verifier
+}
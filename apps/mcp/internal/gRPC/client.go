@@ -1,24 +1,41 @@
 package gRPC
 
 import (
+	"context"
+	"time"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"mcp/pkg/dotenv"
 	"packages/pb"
+	"packages/replauth"
 )
 
 var (
-	replGrpcAddr = ":50051"
+	// The runner's gRPC server, in the same pod unless told otherwise. It
+	// only listens on the loopback interface.
+	replGrpcAddr = dotenv.EnvString("RUNNER_GRPC_ADDR", "127.0.0.1:50051")
 )
 
+// tokenTTL is the lifetime of the token of a call to the runner
+const tokenTTL = time.Minute
+
 type ReplClient struct {
 	Client pb.ReplServiceClient
 	conn   *grpc.ClientConn
 }
 
-func NewReplClient() (*ReplClient, error) {
+// NewReplClient connects to the runner of replId, signing every call with
+// key. Calls carry no token when key is nil.
+func NewReplClient(key []byte, replId string) (*ReplClient, error) {
+
+	options := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if key != nil {
+		options = append(options, grpc.WithPerRPCCredentials(tokenCredentials{key: key, replId: replId}))
+	}
 
-	conn, err := grpc.NewClient(replGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(replGrpcAddr, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -38,3 +55,22 @@ func (r *ReplClient) Close() {
 		r.conn.Close()
 	}
 }
+
+// tokenCredentials signs a short-lived mcp token for every call
+type tokenCredentials struct {
+	key    []byte
+	replId string
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, _, err := replauth.Issue(c.key, c.replId, "mcp", replauth.ScopeMcp, tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity is false, the runner is reached over loopback
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
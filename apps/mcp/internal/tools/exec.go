@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"packages/pb"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxToolOutput bounds the output of a command returned to the model
+const maxToolOutput = 64 << 10
+
+type RunCommandParams struct {
+	Command   string `json:"command" jsonschema:"The command line to run with bash"`
+	Cwd       string `json:"cwd,omitempty" jsonschema:"The working directory relative to workspace root"`
+	TimeoutMs int64  `json:"timeoutMs,omitempty" jsonschema:"How long the command may run, 5 minutes when unset"`
+}
+
+func (h *ToolsHandler) RunCommand(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[RunCommandParams]) (*mcp.CallToolResultFor[any], error) {
+
+	cwd, err := workspacePath(params.Arguments.Cwd)
+	if err != nil {
+		return toolError("Failed to run command: %v", err), nil
+	}
+
+	stream, err := h.replClient.Client.Exec(ctx, &pb.ExecRequest{
+		Shell:     params.Arguments.Command,
+		Cwd:       cwd,
+		TimeoutMs: params.Arguments.TimeoutMs,
+	})
+	if err != nil {
+		return toolError("Failed to run command: %v", err), nil
+	}
+
+	var stdout, stderr strings.Builder
+	var exit *pb.ExecEvent
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return toolError("Failed to run command: %v", err), nil
+		}
+		switch {
+		case event.Exited:
+			exit = event
+		case event.Stream == "stderr":
+			appendBounded(&stderr, event.Data)
+		default:
+			appendBounded(&stdout, event.Data)
+		}
+	}
+	if exit == nil {
+		return toolError("Command ended without an exit code"), nil
+	}
+
+	text := fmt.Sprintf("exit code: %d\n", exit.ExitCode)
+	if exit.TimedOut {
+		text += "timed out\n"
+	}
+	if exit.Error != "" {
+		text += fmt.Sprintf("error: %s\n", exit.Error)
+	}
+	text += fmt.Sprintf("stdout:\n%s\nstderr:\n%s", stdout.String(), stderr.String())
+
+	return &mcp.CallToolResultFor[any]{
+		IsError: exit.ExitCode != 0,
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, nil
+}
+
+func appendBounded(b *strings.Builder, data []byte) {
+	if room := maxToolOutput - b.Len(); len(data) > room {
+		data = data[:max(room, 0)]
+	}
+	b.Write(data)
+}
+
+func toolError(format string, args ...any) *mcp.CallToolResultFor[any] {
+	return &mcp.CallToolResultFor[any]{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf(format, args...)}},
+	}
+}
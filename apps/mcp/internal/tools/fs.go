@@ -14,8 +14,18 @@ type ReadFileParams struct {
 
 func (h *ToolsHandler) ReadFile(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ReadFileParams]) (*mcp.CallToolResultFor[any], error) {
 
+	filePath, err := workspacePath(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{
+				Text: fmt.Sprintf("Failed to read file: %v", err),
+			}},
+		}, nil
+	}
+
 	resp, err := h.replClient.Client.FetchContent(ctx, &pb.FetchContentRequest{
-		Path: params.Arguments.Path,
+		Path: filePath,
 	})
 
 	if err != nil {
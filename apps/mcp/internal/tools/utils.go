@@ -1,6 +1,12 @@
 package tools
 
-import "mcp/internal/gRPC"
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"mcp/internal/gRPC"
+)
 
 type ToolsHandler struct {
 	replClient *gRPC.ReplClient
@@ -11,3 +17,13 @@ func NewToolsHandler(c *gRPC.ReplClient) *ToolsHandler {
 		replClient: c,
 	}
 }
+
+// workspacePath rejects paths leading out of the workspace before they reach
+// the runner, which resolves them again (symlinks included)
+func workspacePath(p string) (string, error) {
+	rel := path.Clean(strings.TrimLeft(p, "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") || strings.ContainsRune(rel, 0) {
+		return "", fmt.Errorf("path %q is outside of the workspace", p)
+	}
+	return rel, nil
+}
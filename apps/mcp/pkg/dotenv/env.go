@@ -0,0 +1,11 @@
+package dotenv
+
+import "syscall"
+
+func EnvString(key, fallback string) string {
+
+	if value, ok := syscall.Getenv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -6,12 +6,23 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"packages/replauth"
 	"packages/utils/json"
-	"runner/cmd/proxy"
-	"runner/pkg/dotenv"
+	"runner/pkg/collab"
+	"runner/pkg/command"
+	"runner/pkg/fs"
+	"runner/pkg/inspector"
+	"runner/pkg/ports"
+	"runner/pkg/pty"
 	"runner/pkg/shutdown"
+	"runner/pkg/supervisor"
+	"runner/pkg/syncer"
+	"runner/pkg/watcher"
 	"runner/services/mcp"
 	"runner/services/repl"
+	"syscall"
 
 	"github.com/rs/cors"
 	"golang.org/x/sync/errgroup"
@@ -20,6 +31,26 @@ import (
 type APIServer struct {
 	httpAddr string
 	grpcAddr string
+	// workspaceSync is nil when the runner does not sync its workspace
+	workspaceSync *syncer.Syncer
+	// watcher is nil when the workspace can not be watched
+	watcher *watcher.Watcher
+	// workspace is shared by the editor connections
+	workspace *fs.Workspace
+	// docs are the files edited live by several clients
+	docs *collab.Sessions
+	// terminals outlive the connections that opened them
+	terminals *pty.PTYManager
+	// commands run without a terminal, from any API
+	commands *command.Manager
+	// app is the user's app, run with the Run/Stop button
+	app *supervisor.Supervisor
+	// ports are the ports the user's processes listen on
+	ports *ports.Monitor
+	// requests to the user's app, captured while inspecting
+	requests *inspector.Inspector
+	// tokens is nil when the runner takes no access tokens
+	tokens *replauth.Verifier
 }
 
 func NewAPIServer(httpAddr, grpcAddr string) *APIServer {
@@ -29,14 +60,78 @@ func NewAPIServer(httpAddr, grpcAddr string) *APIServer {
 	}
 }
 
+// Run downloads the workspace, serves HTTP and gRPC, and uploads the
+// workspace again on SIGTERM (pod deletion or eviction) before exiting.
 func (api *APIServer) Run() error {
 
-	g, _ := errgroup.WithContext(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Before anything is started, the key must not be inherited
+	tokens, err := newVerifier()
+	if err != nil {
+		return err
+	}
+	api.tokens = tokens
+
+	workspaceSync := newSyncer()
+	if workspaceSync != nil {
+		// Serve only once the files are there, /ping tells core we're ready
+		if err := workspaceSync.Download(ctx); err != nil {
+			return fmt.Errorf("failed to download workspace: %w", err)
+		}
+		go workspaceSync.Run(ctx, syncInterval())
+
+		if CHECKPOINT_PREFIX != "" {
+			go workspaceSync.RunCheckpoints(ctx, checkpointInterval(), reportCheckpoint)
+		}
+	}
+	api.workspaceSync = workspaceSync
+
+	// Watch once the files are there, the download is not worth reporting
+	if api.watcher = newWatcher(); api.watcher != nil {
+		go api.watcher.Run(ctx)
+	}
+
+	api.workspace = fs.NewWorkspace(fs.WORKSPACE_DIR)
+	api.docs = collab.NewSessions(api.workspace)
+	go api.docs.Run(ctx, collabSaveInterval())
+	api.terminals = newPTYManager()
+	defer api.terminals.Cleanup()
+	api.commands = command.NewManager(api.workspace)
+	api.app = supervisor.New(api.workspace, TEMPLATE)
+	api.ports = ports.NewMonitor(previewURL)
+	go api.ports.Run(ctx, portScanInterval())
+	api.requests = newInspector()
+
+	g, gctx := errgroup.WithContext(ctx)
 
 	g.Go(api.RunGRPC)
 	g.Go(api.RunHTTP)
 
-	return g.Wait()
+	<-gctx.Done()
+	log.Println("Shutting down runner:", context.Cause(gctx))
+
+	// The app may still be writing to the workspace
+	api.app.Stop()
+
+	// Shared documents may hold edits not on disk yet
+	api.docs.Flush()
+
+	if workspaceSync != nil {
+		syncCtx, cancel := context.WithTimeout(context.Background(), finalSyncTimeout)
+		defer cancel()
+		if err := workspaceSync.Upload(syncCtx); err != nil {
+			log.Printf("❌ Final workspace sync failed: %v", err)
+			return err
+		}
+		log.Println("✅ Final workspace sync done")
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return context.Cause(gctx)
 }
 
 func (api *APIServer) RunGRPC() error {
@@ -46,7 +141,7 @@ func (api *APIServer) RunGRPC() error {
 		return err
 	}
 
-	mcp.NewGrpcServer(lis)
+	mcp.NewGrpcServer(lis, api.workspace, api.commands, api.tokens)
 	return nil
 
 }
@@ -54,28 +149,78 @@ func (api *APIServer) RunGRPC() error {
 func (api *APIServer) RunHTTP() error {
 
 	router := http.NewServeMux()
-	sm := shutdown.NewShutdownManager(dotenv.EnvString("REPL_ID", "repl_id_not_found"), shutdownCallback)
+	sm := shutdown.NewShutdownManager(REPL_ID, shutdownCallback)
 
 	// background repl services
-	router.Handle("/api/v1/repl/", http.StripPrefix("/api/v1/repl", repl.NewHandler(sm)))
+	router.Handle("/api/v1/repl/", http.StripPrefix("/api/v1/repl", repl.NewHandler(sm, repl.Services{
+		Workspace: api.workspace,
+		Watcher:   api.watcher,
+		Docs:      api.docs,
+		Terminals: api.terminals,
+		Commands:  api.commands,
+		App:       api.app,
+		Ports:     api.ports,
+		Requests:  api.requests,
+		Tokens:    api.tokens,
+	})))
+
+	// called by core to checkpoint / restore the workspace of a running repl
+	router.HandleFunc("POST /api/v1/checkpoints", api.tokens.RequireScope(replauth.ScopeCore, func(w http.ResponseWriter, r *http.Request) {
+		takeCheckpoint(w, r, api.workspaceSync)
+	}))
+	router.HandleFunc("POST /api/v1/checkpoints/{checkpointId}/restore", api.tokens.RequireScope(replauth.ScopeCore, func(w http.ResponseWriter, r *http.Request) {
+		restoreCheckpoint(w, r, api.workspaceSync)
+	}))
+
+	// called by core to revoke access tokens before they expire
+	router.HandleFunc("POST /api/v1/auth/revoke", api.tokens.RevokeHandler())
+
+	// run a command to completion, e.g. a CI step
+	router.HandleFunc("POST /api/v1/exec", func(w http.ResponseWriter, r *http.Request) {
+		runCommand(w, r, api.commands)
+	})
+	router.HandleFunc("DELETE /api/v1/exec/{execId}", func(w http.ResponseWriter, r *http.Request) {
+		cancelCommand(w, r, api.commands)
+	})
 
 	// user app usage
-	router.HandleFunc("/user-app/", proxy.ReverseProxyHandler)
+	userApp := newProxy(api.requests)
+	router.Handle("/user-app/", userApp)
+
+	// requests captured by the user app proxy
+	router.HandleFunc("GET /api/v1/inspector/requests", func(w http.ResponseWriter, r *http.Request) {
+		listRequests(w, r, api.requests)
+	})
+	router.HandleFunc("GET /api/v1/inspector/requests/{requestId}", func(w http.ResponseWriter, r *http.Request) {
+		getRequest(w, r, api.requests)
+	})
+	router.HandleFunc("POST /api/v1/inspector/requests/{requestId}/replay", func(w http.ResponseWriter, r *http.Request) {
+		replayRequest(w, r, api.requests)
+	})
+	router.HandleFunc("DELETE /api/v1/inspector/requests", func(w http.ResponseWriter, r *http.Request) {
+		api.requests.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.HandleFunc("PUT /api/v1/inspector", func(w http.ResponseWriter, r *http.Request) {
+		setInspector(w, r, api.requests)
+	})
 
 	router.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("ping-pong")
 		json.WriteJSON(w, http.StatusOK, "pong")
 	})
 
+	// The editor sends its token in a header, no credentials are needed
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+		AllowedOrigins: []string{FRONTEND_URL},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"*"},
 	})
+	// Preview hosts are the app's, CORS included. Both take an access
+	// token, but for the public ports.
 	server := http.Server{
 		Addr:    api.httpAddr,
-		Handler: c.Handler(router),
+		Handler: requireToken(api.tokens, api.ports, servePreviews(userApp, c.Handler(router))),
 	}
 
 	log.Println("Server has started at ", api.httpAddr)
@@ -0,0 +1,7 @@
+package api
+
+import "runner/pkg/dotenv"
+
+// TEMPLATE is the template of the repl, it picks how the app is run when
+// the workspace has no run configuration
+var TEMPLATE = dotenv.EnvString("TEMPLATE", "")
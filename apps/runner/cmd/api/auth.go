@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"packages/replauth"
+	"runner/cmd/proxy"
+	"runner/pkg/dotenv"
+	"runner/pkg/ports"
+)
+
+// REPL_TOKEN_KEY is the hex signing key of the access tokens of this repl,
+// handed over by core. Every endpoint but /ping and the public ports is open
+// to anyone when unset.
+var REPL_TOKEN_KEY = dotenv.EnvString("REPL_TOKEN_KEY", "")
+
+// REPL_TOKEN_REVOCATIONS are the tokens core revoked before the runner
+// started, as JSON replauth.Revocations
+var REPL_TOKEN_REVOCATIONS = dotenv.EnvString("REPL_TOKEN_REVOCATIONS", "")
+
+// tokenKey is the decoded REPL_TOKEN_KEY, it signs the calls to core too
+var tokenKey []byte
+
+// newVerifier checks the access tokens signed with REPL_TOKEN_KEY, nil when
+// it is unset. An invalid key is an error, the runner must not end up open
+// to anyone.
+//
+// The key is removed from the environment the user's processes inherit, but
+// they run as the same user as the runner and can still read it, e.g. from
+// /proc/1/environ. It only signs tokens of this repl, which its user has
+// access to anyway.
+func newVerifier() (*replauth.Verifier, error) {
+	os.Unsetenv("REPL_TOKEN_KEY")
+
+	if REPL_TOKEN_KEY == "" {
+		log.Println("⚠️ REPL_TOKEN_KEY is not set, the runner is open to anyone")
+		return nil, nil
+	}
+	key, err := hex.DecodeString(REPL_TOKEN_KEY)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid REPL_TOKEN_KEY: not a hex key")
+	}
+	tokenKey = key
+	verifier := replauth.NewVerifier(key, REPL_ID)
+
+	if REPL_TOKEN_REVOCATIONS != "" {
+		var revocations replauth.Revocations
+		if err := json.Unmarshal([]byte(REPL_TOKEN_REVOCATIONS), &revocations); err != nil {
+			return nil, fmt.Errorf("invalid REPL_TOKEN_REVOCATIONS: %w", err)
+		}
+		verifier.Apply(revocations)
+	}
+	return verifier, nil
+}
+
+// requireToken serves next the requests with an access token, but for the
+// health check, CORS preflights and the ports the user made public. The
+// user's app takes it from the query or the cookie, the runner's own
+// endpoints from the header or the query.
+func requireToken(verifier *replauth.Verifier, monitor *ports.Monitor, next http.Handler) http.Handler {
+	public := func(r *http.Request) bool {
+		if r.URL.Path == "/ping" {
+			return true
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			return true
+		}
+		port, ok := proxiedPort(r)
+		return ok && isPublic(monitor, port)
+	}
+	sources := func(r *http.Request) replauth.Source {
+		if _, ok := proxiedPort(r); ok {
+			return replauth.ProxySources
+		}
+		return replauth.APISources
+	}
+	return verifier.Middleware(next, public, sources)
+}
+
+// proxiedPort returns the port of the user's app r is for, on a preview host
+// or under /user-app/
+func proxiedPort(r *http.Request) (int, bool) {
+	if port, ok := previewPort(r.Host); ok {
+		return port, true
+	}
+	return userAppPort(r.URL.Path)
+}
+
+// userAppPort returns the port of a /user-app/<port>/... path
+func userAppPort(path string) (int, bool) {
+	rest, ok := strings.CutPrefix(path, "/user-app/")
+	if !ok {
+		return 0, false
+	}
+	port, _, _ := strings.Cut(rest, "/")
+	return proxy.ParsePort(port)
+}
+
+func isPublic(monitor *ports.Monitor, port int) bool {
+	p, ok := monitor.Get(port)
+	return ok && p.Settings.Public
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"packages/checkpoint"
+	"packages/utils/json"
+	"runner/pkg/syncer"
+)
+
+type restoreRequest struct {
+	// Path limits the restore to a file or folder of the workspace
+	Path string `json:"path"`
+}
+
+// takeCheckpoint checkpoints the workspace right away, e.g. before core forks
+// the repl, and answers with the checkpoint holding the current files.
+func takeCheckpoint(w http.ResponseWriter, r *http.Request, workspaceSync *syncer.Syncer) {
+	if workspaceSync == nil {
+		json.WriteError(w, http.StatusServiceUnavailable, "Workspace sync is disabled on this runner")
+		return
+	}
+
+	info, created, err := workspaceSync.Checkpoint(r.Context())
+	if err != nil {
+		log.Println("Checkpoint Failed", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if created {
+		if err := reportCheckpoint(r.Context(), info); err != nil {
+			log.Printf("⚠️ Failed to report checkpoint %s: %v", info.Id, err)
+		}
+	}
+
+	json.WriteJSON(w, http.StatusOK, info)
+}
+
+func restoreCheckpoint(w http.ResponseWriter, r *http.Request, workspaceSync *syncer.Syncer) {
+	if workspaceSync == nil {
+		json.WriteError(w, http.StatusServiceUnavailable, "Workspace sync is disabled on this runner")
+		return
+	}
+
+	var req restoreRequest
+	if err := json.ReadJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := workspaceSync.Restore(r.Context(), r.PathValue("checkpointId"), req.Path)
+	switch {
+	case errors.Is(err, checkpoint.ErrNotFound), errors.Is(err, checkpoint.ErrPathNotFound):
+		json.WriteError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		log.Println("Checkpoint Restore Failed", err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+	default:
+		json.WriteJSON(w, http.StatusOK, "Success")
+	}
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"time"
+
+	"runner/pkg/dotenv"
+)
+
+// COLLAB_SAVE_INTERVAL is how often shared documents are written to disk
+var COLLAB_SAVE_INTERVAL = dotenv.EnvString("COLLAB_SAVE_INTERVAL", "2s")
+
+// defaultCollabSaveInterval is used when COLLAB_SAVE_INTERVAL can not be parsed
+const defaultCollabSaveInterval = 2 * time.Second
+
+func collabSaveInterval() time.Duration {
+	return parseInterval("COLLAB_SAVE_INTERVAL", COLLAB_SAVE_INTERVAL, defaultCollabSaveInterval)
+}
@@ -1,14 +1,45 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"packages/checkpoint"
+	"packages/replauth"
+	"runner/pkg/dotenv"
+)
+
+var (
+	// CORE_URL is the core API the runner reports back to
+	CORE_URL = dotenv.EnvString("CORE_URL", "https://api.devx.parthkapoor.me")
+	REPL_ID  = dotenv.EnvString("REPL_ID", "repl_id_not_found")
+	// FRONTEND_URL is the editor, the only origin allowed by CORS
+	FRONTEND_URL = dotenv.EnvString("FRONTEND_URL", "https://devx.parthkapoor.me")
 )
 
+// coreTokenTTL is enough for a single call to core
+const coreTokenTTL = time.Minute
+
+// authorizeCore signs a request to core with the key of the repl, core only
+// takes the callbacks and storage requests of a repl from its runner
+func authorizeCore(req *http.Request) error {
+	if tokenKey == nil {
+		return nil
+	}
+	token, _, err := replauth.Issue(tokenKey, REPL_ID, "runner", replauth.ScopeRunner, coreTokenTTL)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
 func shutdownCallback(replId string) error {
-	baseURL := "https://api.devx.parthkapoor.me"
-	url := fmt.Sprintf("%s/api/runner/%s", baseURL, replId)
+	url := fmt.Sprintf("%s/api/runner/%s", CORE_URL, replId)
 
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
@@ -16,6 +47,9 @@ func shutdownCallback(replId string) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if err := authorizeCore(req); err != nil {
+		return err
+	}
 
 	client := &http.Client{
 		Timeout: 5 * time.Second,
@@ -33,3 +67,37 @@ func shutdownCallback(replId string) error {
 
 	return nil
 }
+
+// reportCheckpoint lets core know about a new restore point of the repl
+func reportCheckpoint(ctx context.Context, info checkpoint.Info) error {
+	url := fmt.Sprintf("%s/api/runner/%s/checkpoints", CORE_URL, REPL_ID)
+
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := authorizeCore(req); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
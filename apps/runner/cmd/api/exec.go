@@ -0,0 +1,111 @@
+package api
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"packages/utils/json"
+	"runner/pkg/command"
+	"runner/pkg/fs"
+)
+
+type execRequest struct {
+	// ExecId names the command to cancel it, one is made up when empty
+	ExecId string `json:"execId"`
+	command.Request
+}
+
+// execEvent is a line of a streamed exec response, output until the last
+// one which has Exited set
+type execEvent struct {
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	Exited bool   `json:"exited,omitempty"`
+	*command.Result
+	Error string `json:"error,omitempty"`
+}
+
+// runCommand runs a command to completion. It answers with its result and
+// output, or streams it as JSON lines when the client accepts
+// application/x-ndjson.
+func runCommand(w http.ResponseWriter, r *http.Request, commands *command.Manager) {
+	var req execRequest
+	if err := json.ReadJSON(r, &req); err != nil {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ExecId == "" {
+		req.ExecId = command.NewId()
+	}
+
+	if r.Header.Get("Accept") == "application/x-ndjson" {
+		streamCommand(w, r, commands, req)
+		return
+	}
+
+	var output command.Collector
+	result, err := commands.Run(r.Context(), req.ExecId, req.Request, output.Write)
+	if status, rejected := execRejected(err); rejected {
+		json.WriteError(w, status, err.Error())
+		return
+	}
+
+	response := map[string]any{"execId": req.ExecId, "result": result, "output": output.Output()}
+	if err != nil {
+		log.Printf("Error running command %s: %v", req.ExecId, err)
+		response["error"] = err.Error()
+	}
+	json.WriteJSON(w, http.StatusOK, response)
+}
+
+func streamCommand(w http.ResponseWriter, r *http.Request, commands *command.Manager, req execRequest) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Exec-Id", req.ExecId)
+	flusher, _ := w.(http.Flusher)
+	encoder := stdjson.NewEncoder(w)
+
+	// The two streams write from their own goroutines
+	var mu sync.Mutex
+	write := func(event execEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(event)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	result, err := commands.Run(r.Context(), req.ExecId, req.Request, func(stream string, data []byte) {
+		write(execEvent{Stream: stream, Data: string(data)})
+	})
+	exit := execEvent{Exited: true, Result: &result}
+	if err != nil {
+		exit.Error = err.Error()
+	}
+	write(exit)
+}
+
+func cancelCommand(w http.ResponseWriter, r *http.Request, commands *command.Manager) {
+	if !commands.Cancel(r.PathValue("execId")) {
+		json.WriteError(w, http.StatusNotFound, "No such command running")
+		return
+	}
+	json.WriteJSON(w, http.StatusOK, "Success")
+}
+
+// execRejected tells whether err means the command was not run at all
+func execRejected(err error) (int, bool) {
+	switch {
+	case errors.Is(err, command.ErrNoCommand), errors.Is(err, command.ErrNotFound):
+		return http.StatusBadRequest, true
+	case errors.Is(err, fs.ErrOutsideWorkspace):
+		return http.StatusForbidden, true
+	case errors.Is(err, command.ErrAlreadyRunning):
+		return http.StatusConflict, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"packages/utils/json"
+	"runner/pkg/dotenv"
+	"runner/pkg/inspector"
+)
+
+// INSPECT_REQUESTS captures the requests to the user's app from the start,
+// clients can turn it on and off with inspectRequests
+var INSPECT_REQUESTS = dotenv.EnvString("INSPECT_REQUESTS", "false")
+
+func newInspector() *inspector.Inspector {
+	return inspector.New(INSPECT_REQUESTS == "true")
+}
+
+type inspectorState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// listRequests answers with the summaries of the captured requests, after
+// ?since=<id>
+func listRequests(w http.ResponseWriter, r *http.Request, requests *inspector.Inspector) {
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseInt(s, 10, 64); err != nil {
+			json.WriteError(w, http.StatusBadRequest, "Invalid since")
+			return
+		}
+	}
+	json.WriteJSON(w, http.StatusOK, map[string]any{"enabled": requests.Enabled(), "requests": requests.List(since)})
+}
+
+// getRequest answers with a captured request, headers and bodies included
+func getRequest(w http.ResponseWriter, r *http.Request, requests *inspector.Inspector) {
+	id, err := strconv.ParseInt(r.PathValue("requestId"), 10, 64)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request id")
+		return
+	}
+	exchange, err := requests.Get(id)
+	if err != nil {
+		json.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	json.WriteJSON(w, http.StatusOK, exchange)
+}
+
+// replayRequest sends a captured request to the app again and answers with
+// the new exchange
+func replayRequest(w http.ResponseWriter, r *http.Request, requests *inspector.Inspector) {
+	id, err := strconv.ParseInt(r.PathValue("requestId"), 10, 64)
+	if err != nil {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request id")
+		return
+	}
+	exchange, err := requests.Replay(r.Context(), id)
+	switch {
+	case errors.Is(err, inspector.ErrNotFound):
+		json.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, inspector.ErrTruncated):
+		json.WriteError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		log.Printf("Error replaying request %d: %v", id, err)
+		json.WriteError(w, http.StatusInternalServerError, err.Error())
+	default:
+		json.WriteJSON(w, http.StatusOK, exchange)
+	}
+}
+
+// setInspector turns capturing on or off
+func setInspector(w http.ResponseWriter, r *http.Request, requests *inspector.Inspector) {
+	var req inspectorState
+	if err := json.ReadJSON(r, &req); err != nil {
+		json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	requests.SetEnabled(req.Enabled)
+	json.WriteJSON(w, http.StatusOK, inspectorState{Enabled: requests.Enabled()})
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"runner/pkg/dotenv"
+)
+
+var (
+	// PUBLIC_URL is where users reach the runner, e.g.
+	// https://repl.parthkapoor.me/<replId>, preview URLs are relative to the
+	// runner when unset
+	PUBLIC_URL = dotenv.EnvString("PUBLIC_URL", "")
+	// PORT_SCAN_INTERVAL is how often listening ports are looked for
+	PORT_SCAN_INTERVAL = dotenv.EnvString("PORT_SCAN_INTERVAL", "1s")
+)
+
+// defaultPortScanInterval is used when PORT_SCAN_INTERVAL can not be parsed
+const defaultPortScanInterval = time.Second
+
+func portScanInterval() time.Duration {
+	return parseInterval("PORT_SCAN_INTERVAL", PORT_SCAN_INTERVAL, defaultPortScanInterval)
+}
+
+// previewURL is where a port is served by the user-app proxy, on its preview
+// host when PREVIEW_DOMAIN is set
+func previewURL(port int) string {
+	if PREVIEW_DOMAIN != "" {
+		return fmt.Sprintf("%s://%d-%s.%s/", previewScheme(), port, REPL_ID, PREVIEW_DOMAIN)
+	}
+	return fmt.Sprintf("%s/user-app/%d/", strings.TrimSuffix(PUBLIC_URL, "/"), port)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"runner/cmd/proxy"
+	"runner/pkg/dotenv"
+)
+
+// PREVIEW_DOMAIN serves the user's app on <port>-<replId>.<PREVIEW_DOMAIN>,
+// at the root, for apps that break under /user-app/<port>/. It may hold a
+// port, e.g. localhost:8081. Subdomain previews are off when unset.
+var PREVIEW_DOMAIN = dotenv.EnvString("PREVIEW_DOMAIN", "")
+
+// previewPort returns the port of the app a preview host points to, hosts of
+// other repls are not served
+func previewPort(host string) (int, bool) {
+	if PREVIEW_DOMAIN == "" {
+		return 0, false
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(PREVIEW_DOMAIN))
+	if !ok {
+		return 0, false
+	}
+	// repl ids hold dashes, ports do not
+	port, replId, ok := strings.Cut(label, "-")
+	if !ok || replId != strings.ToLower(REPL_ID) {
+		return 0, false
+	}
+	return proxy.ParsePort(port)
+}
+
+// previewScheme is the scheme of PUBLIC_URL, https by default
+func previewScheme() string {
+	if u, err := url.Parse(PUBLIC_URL); err == nil && u.Scheme != "" {
+		return u.Scheme
+	}
+	return "https"
+}
+
+// servePreviews sends the requests made to a preview host to the app, the
+// others to next
+func servePreviews(p *proxy.Proxy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if port, ok := previewPort(r.Host); ok {
+			p.ServeRoot(w, r, port)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"runner/cmd/proxy"
+	"runner/pkg/dotenv"
+	"runner/pkg/inspector"
+)
+
+// PROXY_REWRITE_HTML prefixes the root-relative URLs (src="/main.js") of the
+// pages served by the user-app proxy, for apps unaware of X-Forwarded-Prefix
+var PROXY_REWRITE_HTML = dotenv.EnvString("PROXY_REWRITE_HTML", "false")
+
+func newProxy(requests *inspector.Inspector) *proxy.Proxy {
+	return proxy.New(proxy.Options{
+		RewriteHTML: PROXY_REWRITE_HTML == "true",
+		Inspector:   requests,
+	})
+}
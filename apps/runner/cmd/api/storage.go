@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"packages/storage"
+	"runner/pkg/dotenv"
+	"runner/pkg/fs"
+	"runner/pkg/syncer"
+)
+
+// Storage settings are handed over by core's orchestrator. The runner holds
+// no storage credentials, with the presigned provider core signs each of its
+// requests, and only for the keys of this repl.
+var (
+	STORAGE_PROVIDER = dotenv.EnvString("STORAGE_PROVIDER", "presigned")
+	STORAGE_DIR      = dotenv.EnvString("STORAGE_DIR", "./data/storage")
+	// STORAGE_PREFIX is the repl's folder in storage, sync is off when unset
+	STORAGE_PREFIX = dotenv.EnvString("STORAGE_PREFIX", "")
+	SYNC_INTERVAL  = dotenv.EnvString("SYNC_INTERVAL", "1m")
+	// CHECKPOINT_PREFIX is where checkpoints of the repl are kept, they are
+	// off when unset
+	CHECKPOINT_PREFIX   = dotenv.EnvString("CHECKPOINT_PREFIX", "")
+	CHECKPOINT_INTERVAL = dotenv.EnvString("CHECKPOINT_INTERVAL", "10m")
+)
+
+const (
+	// finalSyncTimeout must stay below the pod's termination grace period
+	finalSyncTimeout = 45 * time.Second
+	// defaultSyncInterval is used when SYNC_INTERVAL can not be parsed
+	defaultSyncInterval = time.Minute
+	// defaultCheckpointInterval is used when CHECKPOINT_INTERVAL can not be parsed
+	defaultCheckpointInterval = 10 * time.Minute
+)
+
+// newSyncer returns the workspace syncer, or nil when no prefix is configured
+// (e.g. when running the runner by hand against an existing directory).
+func newSyncer() *syncer.Syncer {
+	if STORAGE_PREFIX == "" {
+		log.Println("⚠️ STORAGE_PREFIX is not set, workspace sync is disabled")
+		return nil
+	}
+
+	var store storage.WorkspaceStorage
+	switch STORAGE_PROVIDER {
+	case "fs":
+		store = storage.NewFS(STORAGE_DIR)
+	default:
+		store = storage.NewPresigned(fmt.Sprintf("%s/api/runner/%s/storage", CORE_URL, REPL_ID), authorizeCore)
+	}
+
+	if CHECKPOINT_PREFIX == "" {
+		log.Println("⚠️ CHECKPOINT_PREFIX is not set, checkpoints are disabled")
+	}
+
+	return syncer.NewSyncer(store, fs.WORKSPACE_DIR, STORAGE_PREFIX, CHECKPOINT_PREFIX)
+}
+
+func syncInterval() time.Duration {
+	return parseInterval("SYNC_INTERVAL", SYNC_INTERVAL, defaultSyncInterval)
+}
+
+func checkpointInterval() time.Duration {
+	return parseInterval("CHECKPOINT_INTERVAL", CHECKPOINT_INTERVAL, defaultCheckpointInterval)
+}
+
+func parseInterval(name, value string, fallback time.Duration) time.Duration {
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Printf("⚠️ Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return interval
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"runner/pkg/dotenv"
+	"runner/pkg/pty"
+)
+
+var (
+	// TERMINAL_GRACE_PERIOD is how long a terminal with no client attached
+	// keeps running, 0 closes it with its last connection
+	TERMINAL_GRACE_PERIOD = dotenv.EnvString("TERMINAL_GRACE_PERIOD", "5m")
+	// TERMINAL_SCROLLBACK is how many bytes of output a terminal replays to
+	// a client attaching to it
+	TERMINAL_SCROLLBACK = dotenv.EnvString("TERMINAL_SCROLLBACK", "262144")
+)
+
+const (
+	// defaultTerminalGracePeriod is used when TERMINAL_GRACE_PERIOD can not be parsed
+	defaultTerminalGracePeriod = 5 * time.Minute
+	// defaultTerminalScrollback is used when TERMINAL_SCROLLBACK can not be parsed
+	defaultTerminalScrollback = 256 << 10
+)
+
+func newPTYManager() *pty.PTYManager {
+	gracePeriod, err := time.ParseDuration(TERMINAL_GRACE_PERIOD)
+	if err != nil || gracePeriod < 0 {
+		log.Printf("⚠️ Invalid TERMINAL_GRACE_PERIOD %q, using %s", TERMINAL_GRACE_PERIOD, defaultTerminalGracePeriod)
+		gracePeriod = defaultTerminalGracePeriod
+	}
+
+	scrollback, err := strconv.Atoi(TERMINAL_SCROLLBACK)
+	if err != nil || scrollback < 0 {
+		log.Printf("⚠️ Invalid TERMINAL_SCROLLBACK %q, using %d", TERMINAL_SCROLLBACK, defaultTerminalScrollback)
+		scrollback = defaultTerminalScrollback
+	}
+
+	return pty.NewPTYManager(gracePeriod, scrollback)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"runner/pkg/dotenv"
+	"runner/pkg/fs"
+	"runner/pkg/watcher"
+)
+
+// WATCH_IGNORE lists (comma separated) the file and folder names, or
+// patterns, whose changes are not reported to editors
+var WATCH_IGNORE = dotenv.EnvString("WATCH_IGNORE", "node_modules,.git")
+
+// newWatcher returns the workspace watcher, or nil when the workspace can not
+// be watched (editors then only see changes they make)
+func newWatcher() *watcher.Watcher {
+	var ignore []string
+	for _, pattern := range strings.Split(WATCH_IGNORE, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			ignore = append(ignore, pattern)
+		}
+	}
+
+	w, err := watcher.New(fs.WORKSPACE_DIR, ignore)
+	if err != nil {
+		log.Printf("⚠️ Failed to watch the workspace, fsChanged events are disabled: %v", err)
+		return nil
+	}
+	return w
+}
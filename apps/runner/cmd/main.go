@@ -4,10 +4,15 @@ import (
 	"log"
 
 	"runner/cmd/api"
+	"runner/pkg/dotenv"
 )
 
 func main() {
-	if err := api.NewAPIServer(":8081", ":50051").Run(); err != nil {
-		log.Fatal("Unable to run server")
+	httpAddr := ":" + dotenv.EnvString("PORT", "8081")
+	// Only the mcp server in the same pod calls the gRPC server
+	grpcAddr := "127.0.0.1:" + dotenv.EnvString("GRPC_PORT", "50051")
+
+	if err := api.NewAPIServer(httpAddr, grpcAddr).Run(); err != nil {
+		log.Fatal("Unable to run server: ", err)
 	}
 }
@@ -1,15 +1,79 @@
 package proxy
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+
+	"packages/replauth"
+	"runner/pkg/inspector"
 )
 
-func ReverseProxyHandler(w http.ResponseWriter, r *http.Request) {
+// maxRewriteSize bounds the HTML pages rewritten, larger ones are passed as
+// they are
+const maxRewriteSize = 8 << 20
+
+// rootRelative matches the root-relative URLs of src, href and action
+// attributes, not the protocol-relative ones (//cdn...)
+var rootRelative = regexp.MustCompile(`(?i)(\s(?:src|href|action)\s*=\s*["'])/([^/"'])`)
+
+// Options of the user-app proxy
+type Options struct {
+	// RewriteHTML prefixes the root-relative URLs of HTML pages, for apps
+	// that assume they are served at /
+	RewriteHTML bool
+	// Inspector captures the requests while it is enabled, it may be nil
+	Inspector *inspector.Inspector
+}
+
+// Proxy serves the user's app at /user-app/<port>/..., or at the root of a
+// preview host, WebSockets (e.g. Vite or webpack HMR) included. The app sees
+// the request as if it was made at its root, with X-Forwarded-Prefix telling
+// where it is really served.
+type Proxy struct {
+	options   Options
+	transport *http.Transport
+
+	mu      sync.Mutex
+	proxies map[int]*httputil.ReverseProxy
+}
+
+// prefixKey holds the prefix the app is served at in the request context
+type prefixKey struct{}
+
+func New(options Options) *Proxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	return &Proxy{
+		options:   options,
+		transport: transport,
+		proxies:   make(map[int]*httputil.ReverseProxy),
+	}
+}
+
+// ParsePort returns the port of an app the proxy serves, the privileged ones
+// are not
+func ParsePort(s string) (int, bool) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1024 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	parts := strings.SplitN(r.URL.Path, "/", 4) // ["", "user-app", "5000", "some/path"]
 	if len(parts) < 3 {
@@ -17,24 +81,184 @@ func ReverseProxyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	portStr := parts[2]
-	port, err := strconv.Atoi(portStr)
-	if err != nil || port < 1024 || port > 65535 {
+	port, ok := ParsePort(parts[2])
+	if !ok {
 		http.Error(w, "Invalid port", http.StatusBadRequest)
 		return
 	}
 
-	targetURL := fmt.Sprintf("http://localhost:%d", port)
-	remote, err := url.Parse(targetURL)
-	if err != nil {
-		http.Error(w, "Invalid target URL", http.StatusInternalServerError)
+	prefix := fmt.Sprintf("%s/user-app/%d", strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/"), port)
+
+	// /user-app/5000 has relative URLs resolved against /user-app/
+	if len(parts) == 3 {
+		http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	// Strip /user-app/<port>
+	r = r.Clone(r.Context())
+	r.URL.Path = "/" + parts[3]
+	r.URL.RawPath = ""
+	p.serve(w, r, port, prefix)
+}
+
+// ServeRoot serves the app of port at the root of the request, e.g. on
+// <port>-<replId>.<preview-domain>
+func (p *Proxy) ServeRoot(w http.ResponseWriter, r *http.Request, port int) {
+	p.serve(w, r, port, "")
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, port int, prefix string) {
+	r = r.WithContext(context.WithValue(r.Context(), prefixKey{}, prefix))
+	if p.options.Inspector != nil {
+		p.options.Inspector.Serve(w, r, port, p.proxy(port))
 		return
 	}
+	p.proxy(port).ServeHTTP(w, r)
+}
+
+// proxy returns the proxy of port, built once so that its connections are
+// reused
+func (p *Proxy) proxy(port int) *httputil.ReverseProxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if proxy, ok := p.proxies[port]; ok {
+		return proxy
+	}
+
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)}
+	proxy := &httputil.ReverseProxy{
+		Transport: p.transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			p.rewriteRequest(pr, target)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			return p.rewriteResponse(resp, port)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				http.Error(w, fmt.Sprintf("Nothing is listening on port %d", port), http.StatusBadGateway)
+				return
+			}
+			log.Printf("Error proxying to port %d: %v", port, err)
+			http.Error(w, "Failed to reach the app", http.StatusBadGateway)
+		},
+	}
+	p.proxies[port] = proxy
+	return proxy
+}
+
+func (p *Proxy) rewriteRequest(pr *httputil.ProxyRequest, target *url.URL) {
+	pr.SetURL(target)
+
+	// Keep what the ingress in front of the runner saw, it is what the user
+	// sees
+	pr.SetXForwarded()
+	if host := pr.In.Header.Get("X-Forwarded-Host"); host != "" {
+		pr.Out.Header.Set("X-Forwarded-Host", host)
+	}
+	if proto := pr.In.Header.Get("X-Forwarded-Proto"); proto != "" {
+		pr.Out.Header.Set("X-Forwarded-Proto", proto)
+	}
+	pr.Out.Header.Del("X-Forwarded-Prefix")
+	if prefix := pr.In.Context().Value(prefixKey{}).(string); prefix != "" {
+		pr.Out.Header.Set("X-Forwarded-Prefix", prefix)
+	}
+
+	// The access token of the repl is the runner's, not the app's
+	removeCookie(pr.Out, replauth.CookieName)
+
+	// Pages are rewritten as plain text
+	if p.options.RewriteHTML {
+		pr.Out.Header.Del("Accept-Encoding")
+	}
+}
 
-	proxyService := httputil.NewSingleHostReverseProxy(remote)
+// removeCookie drops the cookie name from the Cookie headers of r
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			r.AddCookie(cookie)
+		}
+	}
+}
+
+func (p *Proxy) rewriteResponse(resp *http.Response, port int) error {
+	prefix := resp.Request.Context().Value(prefixKey{}).(string)
+
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", rewriteLocation(location, prefix, port))
+	}
+
+	// Served at the root, the URLs are right already
+	if p.options.RewriteHTML && prefix != "" && isHTML(resp) {
+		return rewriteHTML(resp, prefix)
+	}
+	return nil
+}
 
-	// Rewrite the request path (strip /user-app/<port>)
-	r.URL.Path = "/" + strings.Join(parts[3:], "/")
+// rewriteLocation points a redirect of the app, to its root or to itself
+// (http://localhost:<port>/...), back behind prefix
+func rewriteLocation(location, prefix string, port int) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	if u.IsAbs() {
+		host, portStr, err := net.SplitHostPort(u.Host)
+		if err != nil || portStr != strconv.Itoa(port) || !isLoopback(host) {
+			// Somewhere else
+			return location
+		}
+		u.Scheme, u.Host = "", ""
+	}
+
+	// Root-relative (not protocol-relative //host/...) paths only, relative
+	// ones already resolve behind the prefix
+	if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return location
+	}
+	u.Path = prefix + u.Path
+	u.RawPath = ""
+	return u.String()
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+func isHTML(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	encoding := resp.Header.Get("Content-Encoding")
+	return mediaType == "text/html" && (encoding == "" || encoding == "identity")
+}
+
+// rewriteHTML prefixes the root-relative URLs of the page
+func rewriteHTML(resp *http.Response, prefix string) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRewriteSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxRewriteSize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
 
-	proxyService.ServeHTTP(w, r)
+	body = rootRelative.ReplaceAll(body, []byte("${1}"+prefix+"/${2}"))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
 }
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newApp serves handler on a local port and returns the proxy in front of it
+// and the port
+func newApp(t *testing.T, options Options, handler http.HandlerFunc) (*httptest.Server, int) {
+	t.Helper()
+	app := httptest.NewServer(handler)
+	t.Cleanup(app.Close)
+	u, _ := url.Parse(app.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	proxy := httptest.NewServer(New(options))
+	t.Cleanup(proxy.Close)
+	return proxy, port
+}
+
+func TestRewriteLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{location: "/login?next=/", want: "/repl/user-app/5000/login?next=/"},
+		{location: "http://localhost:5000/done", want: "/repl/user-app/5000/done"},
+		{location: "http://127.0.0.1:5000/", want: "/repl/user-app/5000/"},
+		{location: "http://localhost:3000/other", want: "http://localhost:3000/other"},
+		{location: "https://github.com/login", want: "https://github.com/login"},
+		{location: "//cdn.example.com/x", want: "//cdn.example.com/x"},
+		{location: "next", want: "next"},
+	}
+
+	for _, tt := range tests {
+		if got := rewriteLocation(tt.location, "/repl/user-app/5000", 5000); got != tt.want {
+			t.Errorf("rewriteLocation(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	proxy, port := newApp(t, Options{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		fmt.Fprintf(w, "%s %s %s", r.URL.RequestURI(), r.Header.Get("X-Forwarded-Prefix"), r.Header.Get("X-Forwarded-Proto"))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/user-app/%d/api/items?q=1", proxy.URL, port), nil)
+	req.Header.Set("X-Forwarded-Prefix", "/repl")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := fmt.Sprintf("/api/items?q=1 /repl/user-app/%d https", port); string(body) != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get(fmt.Sprintf("%s/user-app/%d/old", proxy.URL, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := fmt.Sprintf("/user-app/%d/new", port); resp.Header.Get("Location") != want {
+		t.Errorf("got Location %q, want %q", resp.Header.Get("Location"), want)
+	}
+}
+
+func TestProxyCookies(t *testing.T) {
+	proxy, port := newApp(t, Options{}, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Cookie"))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/user-app/%d/", proxy.URL, port), nil)
+	req.Header.Set("Cookie", "session=abc; devex_token=secret; theme=dark")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "session=abc; theme=dark"; string(body) != want {
+		t.Errorf("app got cookies %q, want %q", body, want)
+	}
+}
+
+func TestServeRoot(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "http://localhost:"+strings.Split(r.Host, ":")[1]+"/new", http.StatusFound)
+			return
+		}
+		fmt.Fprintf(w, "%s %q", r.URL.RequestURI(), r.Header.Get("X-Forwarded-Prefix"))
+	}))
+	defer app.Close()
+	u, _ := url.Parse(app.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	p := New(Options{RewriteHTML: true})
+	preview := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeRoot(w, r, port)
+	}))
+	defer preview.Close()
+
+	resp, err := http.Get(preview.URL + "/user-app/assets/main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := `/user-app/assets/main.js ""`; string(body) != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get(preview.URL + "/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Location") != "/new" {
+		t.Errorf("got Location %q, want %q", resp.Header.Get("Location"), "/new")
+	}
+}
+
+func TestProxyRewriteHTML(t *testing.T) {
+	page := `<script type="module" src="/@vite/client"></script><a href="//cdn.example.com/x">x</a><link href='/style.css'>`
+	proxy, port := newApp(t, Options{RewriteHTML: true}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	})
+
+	resp, err := http.Get(fmt.Sprintf("%s/user-app/%d/", proxy.URL, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	prefix := fmt.Sprintf("/user-app/%d", port)
+	want := strings.NewReplacer(`src="/`, `src="`+prefix+`/`, `href='/`, `href='`+prefix+`/`).Replace(page)
+	if string(body) != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	proxy, port := newApp(t, Options{}, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		kind, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(kind, append([]byte(r.URL.Path+" "), message...))
+	})
+
+	wsURL := strings.Replace(proxy.URL, "http", "ws", 1) + fmt.Sprintf("/user-app/%d/hmr", port)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message) != "/hmr ping" {
+		t.Errorf("got %q, want %q", message, "/hmr ping")
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	proxy := httptest.NewServer(New(Options{}))
+	defer proxy.Close()
+
+	// A port nothing listens on anymore
+	closed := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(closed.URL)
+	closed.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/user-app/80/", want: http.StatusBadRequest},
+		{path: "/user-app/abc/", want: http.StatusBadRequest},
+		{path: "/user-app/" + u.Port() + "/", want: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(proxy.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s: got status %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
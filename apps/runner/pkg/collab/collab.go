@@ -0,0 +1,160 @@
+// Package collab lets several clients edit the same workspace file live. Each
+// open file is a Document, clients send operational transforms (ot.js
+// format) and get the operations and cursors of the others.
+package collab
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"runner/pkg/fs"
+)
+
+// Sessions holds the open documents of a workspace. A document is loaded
+// from disk when its first client joins, written back periodically and
+// dropped once its last client left. Changes made to the file on disk in
+// the meantime are merged into the document before it is written.
+type Sessions struct {
+	workspace *fs.Workspace
+
+	mu   sync.Mutex
+	docs map[string]*Document
+}
+
+func NewSessions(workspace *fs.Workspace) *Sessions {
+	return &Sessions{workspace: workspace, docs: make(map[string]*Document)}
+}
+
+// key is the workspace path of a document, "a.txt" and "/a.txt" are the same
+func key(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Join adds clientId to the document of p, loading it if needed. send gets
+// the events of the other clients of the document and must not block.
+func (s *Sessions) Join(p, clientId string, send func(Event)) (*Document, Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[key(p)]
+	if !ok {
+		content, _, err := s.workspace.FetchFileContent(p)
+		if err != nil {
+			return nil, Snapshot{}, err
+		}
+		doc = newDocument(key(p), content)
+		s.docs[key(p)] = doc
+	}
+	return doc, doc.join(clientId, send), nil
+}
+
+// Get returns the open document of p
+func (s *Sessions) Get(p string) (*Document, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[key(p)]
+	return doc, ok
+}
+
+// Leave removes clientId from the document of p, the last client out saves
+// and closes it
+func (s *Sessions) Leave(p, clientId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[key(p)]
+	if !ok {
+		return
+	}
+	if doc.leave(clientId) == 0 {
+		s.persist(doc)
+		delete(s.docs, key(p))
+	}
+}
+
+// LeaveAll removes clientId from every document, when it disconnects
+func (s *Sessions) LeaveAll(clientId string) {
+	s.mu.Lock()
+	paths := make([]string, 0, len(s.docs))
+	for p := range s.docs {
+		paths = append(paths, p)
+	}
+	s.mu.Unlock()
+
+	for _, p := range paths {
+		s.Leave(p, clientId)
+	}
+}
+
+// Reload merges the changes made on disk to the files of paths into their
+// open documents, and writes the documents back if they had edits of their
+// own. It is meant for the workspace watcher.
+func (s *Sessions) Reload(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range paths {
+		if doc, ok := s.docs[key(p)]; ok {
+			s.persist(doc)
+		}
+	}
+}
+
+// Run writes the changed documents to disk every interval until ctx is done,
+// and a last time then
+func (s *Sessions) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush()
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Flush writes the changed documents to disk
+func (s *Sessions) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range s.docs {
+		s.persist(doc)
+	}
+}
+
+// persist merges the changes made on disk into doc, and writes doc if it
+// changed, s.mu must be held
+func (s *Sessions) persist(doc *Document) {
+	disk, _, err := s.workspace.FetchFileContent(doc.path)
+	switch {
+	case err == nil:
+		if doc.merge(disk) {
+			log.Printf("⚠️ %s changed on disk and could not be merged, the shared document was replaced", doc.path)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		// Deleted meanwhile, writing the document creates it again
+	default:
+		log.Printf("Failed to read shared document %s: %v", doc.path, err)
+	}
+
+	content, revision, dirty := doc.takeDirty()
+	if !dirty {
+		return
+	}
+	if err := s.workspace.WriteFile(doc.path, content); err != nil {
+		log.Printf("Failed to save shared document %s: %v", doc.path, err)
+		doc.markDirty()
+		return
+	}
+	doc.saved(content, revision)
+}
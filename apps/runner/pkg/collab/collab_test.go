@@ -0,0 +1,149 @@
+package collab
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"runner/pkg/fs"
+)
+
+func TestPersistMergesDiskChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit is applied by a client, against revision 0, before the file
+		// changes on disk
+		edit string
+		disk string
+		// remove deletes the file instead of changing it
+		remove       bool
+		want         string
+		wantConflict bool
+	}{
+		{
+			name: "edits only",
+			edit: `[11, "!"]`,
+			disk: "hello world",
+			want: "hello world!",
+		},
+		{
+			name: "disk changes only",
+			disk: "hello, world",
+			want: "hello, world",
+		},
+		{
+			name: "both",
+			edit: `[11, "!"]`,
+			disk: "Hey, hello world",
+			want: "Hey, hello world!",
+		},
+		{
+			name: "same change on both",
+			edit: `[5, -6]`,
+			disk: "hello",
+			want: "hello",
+		},
+		{
+			name:   "deleted",
+			edit:   `[11, "!"]`,
+			remove: true,
+			want:   "hello world!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "a.txt")
+			if err := os.WriteFile(file, []byte("hello world"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewSessions(fs.NewWorkspace(dir))
+			var events []Event
+			doc, _, err := s.Join("a.txt", "alice", func(Event) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := s.Join("a.txt", "bob", func(e Event) { events = append(events, e) }); err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != "" {
+				if _, _, err := doc.Apply("alice", 0, parseOp(t, tt.edit), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.remove {
+				err = os.Remove(file)
+			} else {
+				err = os.WriteFile(file, []byte(tt.disk), 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Reload([]string{"a.txt"})
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("disk = %q, want %q", data, tt.want)
+			}
+
+			// bob's copy follows the operations he was sent
+			content := "hello world"
+			for _, e := range events {
+				if e.Type == EventOperation {
+					content = apply(t, e.Operation, content)
+				}
+			}
+			if content != tt.want {
+				t.Errorf("document = %q, want %q", content, tt.want)
+			}
+
+			// Written once, the document does not change again
+			s.Flush()
+			if data, _ := os.ReadFile(file); string(data) != tt.want {
+				t.Errorf("disk after Flush = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersistConflict(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSessions(fs.NewWorkspace(dir))
+	var events []Event
+	doc, _, err := s.Join("a.txt", "alice", func(e Event) { events = append(events, e) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The revision the file was loaded at leaves the history
+	for i := 0; i <= maxHistory; i++ {
+		if _, _, err := doc.Apply("alice", i, Operation{{Retain: i}, {Insert: "x"}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(file, []byte("from disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Flush()
+
+	if data, _ := os.ReadFile(file); string(data) != "from disk" {
+		t.Errorf("disk = %q, want the change made on disk", data)
+	}
+	if len(events) == 0 || events[len(events)-1].Type != EventConflict {
+		t.Errorf("events = %+v, want a conflict last", events)
+	}
+	if snapshot := doc.join("bob", func(Event) {}); snapshot.Content != "from disk" {
+		t.Errorf("document = %q, want the change made on disk", snapshot.Content)
+	}
+}
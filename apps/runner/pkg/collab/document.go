@@ -0,0 +1,326 @@
+package collab
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf16"
+)
+
+// maxHistory is how many operations a document keeps to transform edits
+// made against an older revision, clients further behind must resync
+const maxHistory = 1000
+
+var ErrRevisionTooOld = errors.New("revision is too old, reload the document")
+
+// Selection of a client, as positions in the document. Anchor and Head are
+// equal for a plain cursor.
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+func (s Selection) transform(op Operation) Selection {
+	return Selection{Anchor: op.TransformIndex(s.Anchor, false), Head: op.TransformIndex(s.Head, false)}
+}
+
+// Event is sent to the clients of a document
+type Event struct {
+	// Type is EventOperation, EventSelection, EventJoined, EventLeft or
+	// EventConflict
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// ClientId is empty for changes made on disk
+	ClientId string `json:"clientId"`
+	// Revision is the revision of the document after Operation
+	Revision  int        `json:"revision,omitempty"`
+	Operation Operation  `json:"operation,omitempty"`
+	Selection *Selection `json:"selection,omitempty"`
+}
+
+// Kinds of event
+const (
+	EventOperation = "operation"
+	EventSelection = "selection"
+	EventJoined    = "joined"
+	EventLeft      = "left"
+	// EventConflict is sent when a change made on disk could not be merged
+	// and replaced the document, unsaved edits included
+	EventConflict = "conflict"
+)
+
+// Snapshot is what a joining client loads
+type Snapshot struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Revision int    `json:"revision"`
+	// Selections of the other clients, by client id
+	Selections map[string]Selection `json:"selections"`
+}
+
+// Document is a file being edited by one or more clients. Every operation
+// moves it to the next revision, operations made against an older revision
+// are transformed against the ones applied since.
+type Document struct {
+	path string
+
+	mu      sync.Mutex
+	content []uint16
+	// history[i] moved the document from revision base+i to base+i+1
+	history []Operation
+	base    int
+	clients map[string]*client
+	// dirty is set when content changed since it was last persisted
+	dirty bool
+	// disk is the file as it was loaded or last persisted, at revision
+	// diskRevision. Anything else on disk was changed outside of the
+	// document (terminal, git, agents).
+	disk         string
+	diskRevision int
+}
+
+type client struct {
+	send      func(Event)
+	selection *Selection
+}
+
+func newDocument(path, content string) *Document {
+	return &Document{
+		path:    path,
+		content: utf16.Encode([]rune(content)),
+		clients: make(map[string]*client),
+		disk:    content,
+	}
+}
+
+// revision is the current revision, mu must be held
+func (d *Document) revision() int {
+	return d.base + len(d.history)
+}
+
+// join adds a client, send gets the events of the other clients and must
+// not block
+func (d *Document) join(clientId string, send func(Event)) Snapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	selections := make(map[string]Selection)
+	for id, c := range d.clients {
+		if c.selection != nil {
+			selections[id] = *c.selection
+		}
+	}
+	d.clients[clientId] = &client{send: send}
+	d.broadcast(clientId, Event{Type: EventJoined})
+
+	return Snapshot{
+		Path:       d.path,
+		Content:    string(utf16.Decode(d.content)),
+		Revision:   d.revision(),
+		Selections: selections,
+	}
+}
+
+// leave removes a client and returns how many are left
+func (d *Document) leave(clientId string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.clients[clientId]; !ok {
+		return len(d.clients)
+	}
+	delete(d.clients, clientId)
+	d.broadcast(clientId, Event{Type: EventLeft})
+	return len(d.clients)
+}
+
+// Apply applies op, made by clientId against revision, and returns it as
+// applied to the current revision along with the new revision. selection is
+// the client's selection after op, if it sent one.
+func (d *Document) Apply(clientId string, revision int, op Operation, selection *Selection) (Operation, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.clients[clientId]
+	if !ok {
+		return nil, 0, fmt.Errorf("client %s has not joined %s", clientId, d.path)
+	}
+	if revision < d.base {
+		return nil, 0, ErrRevisionTooOld
+	}
+	if revision > d.revision() {
+		return nil, 0, fmt.Errorf("revision %d does not exist", revision)
+	}
+
+	// Ops applied since revision go first, they were accepted first
+	for _, applied := range d.history[revision-d.base:] {
+		appliedPrime, opPrime, err := Transform(applied, op)
+		if err != nil {
+			return nil, 0, err
+		}
+		// selection is after op, so it moves by applied as seen after op
+		if selection != nil {
+			s := selection.transform(appliedPrime)
+			selection = &s
+		}
+		op = opPrime
+	}
+
+	content, err := op.Apply(d.content)
+	if err != nil {
+		return nil, 0, err
+	}
+	d.content = content
+	d.dirty = true
+
+	d.history = append(d.history, op)
+	if len(d.history) > maxHistory {
+		d.base += len(d.history) - maxHistory
+		d.history = d.history[len(d.history)-maxHistory:]
+	}
+
+	// Move the other selections along
+	for _, other := range d.clients {
+		if other != c && other.selection != nil {
+			s := other.selection.transform(op)
+			other.selection = &s
+		}
+	}
+	if selection != nil {
+		c.selection = selection
+	}
+
+	d.broadcast(clientId, Event{
+		Type:      EventOperation,
+		Revision:  d.revision(),
+		Operation: op,
+		Selection: selection,
+	})
+	return op, d.revision(), nil
+}
+
+// Select updates the selection of clientId, made against revision
+func (d *Document) Select(clientId string, revision int, selection Selection) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.clients[clientId]
+	if !ok {
+		return fmt.Errorf("client %s has not joined %s", clientId, d.path)
+	}
+	if revision < d.base || revision > d.revision() {
+		// Stale selections are dropped, the next one will do
+		return nil
+	}
+	for _, applied := range d.history[revision-d.base:] {
+		selection = selection.transform(applied)
+	}
+
+	c.selection = &selection
+	d.broadcast(clientId, Event{Type: EventSelection, Revision: d.revision(), Selection: &selection})
+	return nil
+}
+
+// broadcast sends event from clientId to the other clients, mu must be held
+func (d *Document) broadcast(clientId string, event Event) {
+	event.Path = d.path
+	event.ClientId = clientId
+	for id, c := range d.clients {
+		if id != clientId {
+			c.send(event)
+		}
+	}
+}
+
+// merge applies the changes made on disk since the document was loaded or
+// last persisted, as an operation made against diskRevision. When that
+// revision is gone from the history, or the operation does not apply, disk
+// replaces the document and the clients get EventConflict. It reports
+// whether there was such a conflict.
+func (d *Document) merge(disk string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if disk == d.disk {
+		return false
+	}
+
+	var content []uint16
+	op, err := d.transformDisk(diff(d.disk, disk))
+	if err == nil {
+		content, err = op.Apply(d.content)
+	}
+	conflict := err != nil
+	if conflict {
+		op = diff(string(utf16.Decode(d.content)), disk)
+		content = utf16.Encode([]rune(disk))
+	}
+
+	// With edits not persisted yet, the document now differs from disk
+	// until the next persist writes it
+	d.disk = disk
+	if !op.IsNoop() {
+		d.content = content
+		d.history = append(d.history, op)
+		if len(d.history) > maxHistory {
+			d.base += len(d.history) - maxHistory
+			d.history = d.history[len(d.history)-maxHistory:]
+		}
+		for _, c := range d.clients {
+			if c.selection != nil {
+				s := c.selection.transform(op)
+				c.selection = &s
+			}
+		}
+		d.broadcast("", Event{Type: EventOperation, Revision: d.revision(), Operation: op})
+	}
+	d.diskRevision = d.revision()
+	if conflict {
+		d.broadcast("", Event{Type: EventConflict, Revision: d.revision()})
+	}
+	return conflict
+}
+
+// transformDisk transforms op, made on disk against diskRevision, against
+// the operations applied since, mu must be held
+func (d *Document) transformDisk(op Operation) (Operation, error) {
+	if d.diskRevision < d.base {
+		return nil, ErrRevisionTooOld
+	}
+	for _, applied := range d.history[d.diskRevision-d.base:] {
+		_, opPrime, err := Transform(applied, op)
+		if err != nil {
+			return nil, err
+		}
+		op = opPrime
+	}
+	return op, nil
+}
+
+// takeDirty returns the content, and its revision, when it changed since
+// the last call
+func (d *Document) takeDirty() (string, int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.dirty {
+		return "", 0, false
+	}
+	d.dirty = false
+	return string(utf16.Decode(d.content)), d.revision(), true
+}
+
+// saved records the content of revision as the one on disk
+func (d *Document) saved(content string, revision int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disk = content
+	d.diskRevision = revision
+}
+
+// markDirty flags the content as not persisted, after a failed write
+func (d *Document) markDirty() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dirty = true
+}
@@ -0,0 +1,289 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	ErrInvalidOperation = errors.New("invalid operation")
+	// ErrLengthMismatch is returned for an operation made against a
+	// document of another length
+	ErrLengthMismatch = errors.New("operation does not match the document length")
+)
+
+// Operation is an edit of a whole document in the ot.js format: a list of
+// components, a positive number retains that many characters, a negative
+// number deletes them and a string is inserted. Lengths count UTF-16 code
+// units, like the offsets of the browser editors.
+type Operation []Component
+
+// Component is one step of an Operation, exactly one field is set
+type Component struct {
+	Retain int
+	Delete int
+	Insert string
+}
+
+func (c Component) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Insert != "":
+		return json.Marshal(c.Insert)
+	case c.Delete > 0:
+		return json.Marshal(-c.Delete)
+	default:
+		return json.Marshal(c.Retain)
+	}
+}
+
+func (c *Component) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		*c = Component{Insert: v}
+	case float64:
+		n := int(v)
+		if float64(n) != v || n == 0 {
+			return fmt.Errorf("%w: component %v", ErrInvalidOperation, v)
+		}
+		if n > 0 {
+			*c = Component{Retain: n}
+		} else {
+			*c = Component{Delete: -n}
+		}
+	default:
+		return fmt.Errorf("%w: component %s", ErrInvalidOperation, data)
+	}
+	return nil
+}
+
+// insertLen is the length of an insert in UTF-16 code units
+func insertLen(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// BaseLen is the length of the document the operation applies to
+func (op Operation) BaseLen() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// TargetLen is the length of the document once the operation is applied
+func (op Operation) TargetLen() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + insertLen(c.Insert)
+	}
+	return n
+}
+
+// IsNoop is true for an operation leaving the document unchanged
+func (op Operation) IsNoop() bool {
+	for _, c := range op {
+		if c.Delete > 0 || c.Insert != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// builder appends components, merging them with the last one when possible
+type builder struct {
+	op Operation
+}
+
+func (b *builder) retain(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(b.op) - 1; last >= 0 && b.op[last].Retain > 0 {
+		b.op[last].Retain += n
+		return
+	}
+	b.op = append(b.op, Component{Retain: n})
+}
+
+func (b *builder) insert(s string) {
+	if s == "" {
+		return
+	}
+	last := len(b.op) - 1
+	switch {
+	case last >= 0 && b.op[last].Insert != "":
+		b.op[last].Insert += s
+	case last >= 0 && b.op[last].Delete > 0:
+		// Inserts go before deletes, which keeps operations canonical
+		if last > 0 && b.op[last-1].Insert != "" {
+			b.op[last-1].Insert += s
+			return
+		}
+		b.op = append(b.op, b.op[last])
+		b.op[last] = Component{Insert: s}
+	default:
+		b.op = append(b.op, Component{Insert: s})
+	}
+}
+
+func (b *builder) delete(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(b.op) - 1; last >= 0 && b.op[last].Delete > 0 {
+		b.op[last].Delete += n
+		return
+	}
+	b.op = append(b.op, Component{Delete: n})
+}
+
+// diff returns the operation turning a into b, for changes made to a file
+// outside of its document
+func diff(a, b string) Operation {
+	var op builder
+	for _, d := range diffmatchpatch.New().DiffMain(a, b, false) {
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			op.retain(insertLen(d.Text))
+		case diffmatchpatch.DiffInsert:
+			op.insert(d.Text)
+		case diffmatchpatch.DiffDelete:
+			op.delete(insertLen(d.Text))
+		}
+	}
+	return op.op
+}
+
+// Apply applies op to doc
+func (op Operation) Apply(doc []uint16) ([]uint16, error) {
+	if op.BaseLen() != len(doc) {
+		return nil, ErrLengthMismatch
+	}
+	out := make([]uint16, 0, op.TargetLen())
+	pos := 0
+	for _, c := range op {
+		switch {
+		case c.Insert != "":
+			out = append(out, utf16.Encode([]rune(c.Insert))...)
+		case c.Delete > 0:
+			pos += c.Delete
+		default:
+			out = append(out, doc[pos:pos+c.Retain]...)
+			pos += c.Retain
+		}
+	}
+	return out, nil
+}
+
+// Transform returns a' and b' such that applying a then b' gives the same
+// document as applying b then a'. a and b must apply to the same document,
+// inserts of a at the same position go first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLen() != b.BaseLen() {
+		return nil, nil, ErrLengthMismatch
+	}
+
+	var aPrime, bPrime builder
+	// Components are consumed partially, the remainders are kept here
+	i, j := 0, 0
+	var ca, cb *Component
+	next := func(op Operation, idx *int) *Component {
+		if *idx >= len(op) {
+			return nil
+		}
+		c := op[*idx]
+		*idx++
+		return &c
+	}
+	ca, cb = next(a, &i), next(b, &j)
+
+	for ca != nil || cb != nil {
+		// Inserts do not consume the base document
+		if ca != nil && ca.Insert != "" {
+			aPrime.insert(ca.Insert)
+			bPrime.retain(insertLen(ca.Insert))
+			ca = next(a, &i)
+			continue
+		}
+		if cb != nil && cb.Insert != "" {
+			aPrime.retain(insertLen(cb.Insert))
+			bPrime.insert(cb.Insert)
+			cb = next(b, &j)
+			continue
+		}
+		if ca == nil || cb == nil {
+			return nil, nil, ErrInvalidOperation
+		}
+
+		n := min(ca.Retain+ca.Delete, cb.Retain+cb.Delete)
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			aPrime.retain(n)
+			bPrime.retain(n)
+		case ca.Delete > 0 && cb.Retain > 0:
+			aPrime.delete(n)
+		case ca.Retain > 0 && cb.Delete > 0:
+			bPrime.delete(n)
+		}
+		// Both deleting the same characters leaves nothing to do
+
+		ca = consume(ca, n)
+		cb = consume(cb, n)
+		if ca == nil {
+			ca = next(a, &i)
+		}
+		if cb == nil {
+			cb = next(b, &j)
+		}
+	}
+	return aPrime.op, bPrime.op, nil
+}
+
+// consume removes n base characters from a retain or delete, nil when it is
+// used up
+func consume(c *Component, n int) *Component {
+	if c.Retain > 0 {
+		c.Retain -= n
+		if c.Retain == 0 {
+			return nil
+		}
+		return c
+	}
+	c.Delete -= n
+	if c.Delete == 0 {
+		return nil
+	}
+	return c
+}
+
+// TransformIndex moves a position of the document before op to where it is
+// after op. Text inserted at the position goes before it when own is set
+// (the author's cursor follows its typing), after it otherwise.
+func (op Operation) TransformIndex(index int, own bool) int {
+	pos, newIndex := 0, index
+	for _, c := range op {
+		if pos > index {
+			break
+		}
+		switch {
+		case c.Insert != "":
+			if pos < index || own {
+				newIndex += insertLen(c.Insert)
+			}
+		case c.Delete > 0:
+			newIndex -= min(c.Delete, index-pos)
+			pos += c.Delete
+		default:
+			pos += c.Retain
+		}
+	}
+	return newIndex
+}
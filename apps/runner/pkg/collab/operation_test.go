@@ -0,0 +1,151 @@
+package collab
+
+import (
+	"encoding/json"
+	"testing"
+	"unicode/utf16"
+)
+
+func parseOp(t *testing.T, s string) Operation {
+	t.Helper()
+	var op Operation
+	if err := json.Unmarshal([]byte(s), &op); err != nil {
+		t.Fatal(err)
+	}
+	return op
+}
+
+func apply(t *testing.T, op Operation, doc string) string {
+	t.Helper()
+	out, err := op.Apply(utf16.Encode([]rune(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(utf16.Decode(out))
+}
+
+func TestTransformConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a, b string
+		want string
+	}{
+		{
+			name: "inserts at different positions",
+			doc:  "hello world",
+			a:    `["Hey, ", 11]`,
+			b:    `[11, "!"]`,
+			want: "Hey, hello world!",
+		},
+		{
+			name: "inserts at the same position, a first",
+			doc:  "ab",
+			a:    `[1, "x", 1]`,
+			b:    `[1, "y", 1]`,
+			want: "axyb",
+		},
+		{
+			name: "overlapping deletes",
+			doc:  "abcdef",
+			a:    `[1, -3, 2]`,
+			b:    `[2, -3, 1]`,
+			want: "af",
+		},
+		{
+			name: "insert inside a deleted range",
+			doc:  "abcdef",
+			a:    `[1, -4, 1]`,
+			b:    `[3, "X", 3]`,
+			want: "aXf",
+		},
+		{
+			name: "characters outside the BMP",
+			doc:  "a😀b",
+			a:    `[3, "c", 1]`,
+			b:    `[1, -2, 1]`,
+			want: "acb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := parseOp(t, tt.a), parseOp(t, tt.b)
+			aPrime, bPrime, err := Transform(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ab := apply(t, bPrime, apply(t, a, tt.doc))
+			ba := apply(t, aPrime, apply(t, b, tt.doc))
+			if ab != tt.want || ba != tt.want {
+				t.Errorf("got %q and %q, want %q", ab, ba, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformLengthMismatch(t *testing.T) {
+	if _, _, err := Transform(parseOp(t, `[3]`), parseOp(t, `[4]`)); err != ErrLengthMismatch {
+		t.Errorf("got %v, want ErrLengthMismatch", err)
+	}
+}
+
+func TestTransformIndex(t *testing.T) {
+	op := parseOp(t, `[2, "xy", 2, -2, 1]`)
+	tests := []struct {
+		index int
+		own   bool
+		want  int
+	}{
+		{index: 1, want: 1},
+		{index: 2, want: 2},
+		{index: 2, own: true, want: 4},
+		{index: 3, want: 5},
+		{index: 5, want: 6},
+		{index: 7, want: 7},
+	}
+	for _, tt := range tests {
+		if got := op.TransformIndex(tt.index, tt.own); got != tt.want {
+			t.Errorf("TransformIndex(%d, %v) = %d, want %d", tt.index, tt.own, got, tt.want)
+		}
+	}
+}
+
+func TestOperationJSON(t *testing.T) {
+	const in = `[3,"abc",-2,1]`
+	out, err := json.Marshal(parseOp(t, in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{name: "same", a: "hello", b: "hello"},
+		{name: "insert", a: "hello", b: "hello world"},
+		{name: "delete", a: "hello world", b: "world"},
+		{name: "replace", a: "let x = 1\nlet y = 2\n", b: "const x = 1\nlet y = 3\n"},
+		{name: "from empty", a: "", b: "package main"},
+		{name: "to empty", a: "package main", b: ""},
+		{name: "characters outside the BMP", a: "a😀b", b: "a😀c😃"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := diff(tt.a, tt.b)
+			if got := apply(t, op, tt.a); got != tt.b {
+				t.Errorf("got %q, want %q", got, tt.b)
+			}
+			if op.IsNoop() != (tt.a == tt.b) {
+				t.Errorf("IsNoop() = %v for %q to %q", op.IsNoop(), tt.a, tt.b)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// Package command runs non-interactive commands in the workspace, like a CI
+// step or an AI tool would: stdout and stderr are streamed apart and the exit
+// code is reported, unlike in a terminal.
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"runner/pkg/fs"
+)
+
+const (
+	// DefaultTimeout applies to requests without one
+	DefaultTimeout = 5 * time.Minute
+	// MaxTimeout bounds the timeout of a request
+	MaxTimeout = time.Hour
+	// killDelay is how long a cancelled command has between SIGTERM and
+	// SIGKILL
+	killDelay = 2 * time.Second
+)
+
+// Streams of output
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+var (
+	ErrNoCommand      = errors.New("either command or shell must be set")
+	ErrNotFound       = errors.New("command not found")
+	ErrAlreadyRunning = errors.New("a command is already running under this id")
+)
+
+// Request describes a command to run
+type Request struct {
+	// Command is the argv of the command, run without a shell
+	Command []string `json:"command"`
+	// Shell is a command line run with /bin/bash -c, when Command is empty
+	Shell string `json:"shell"`
+	// Cwd is relative to the workspace root
+	Cwd string `json:"cwd"`
+	// Env is added to the runner's environment
+	Env       map[string]string `json:"env"`
+	TimeoutMs int64             `json:"timeoutMs"`
+	Stdin     string            `json:"stdin"`
+}
+
+// Result of a command. ExitCode is -1 when it did not exit by itself (killed
+// on timeout or cancellation, or failed to start).
+type Result struct {
+	ExitCode   int   `json:"exitCode"`
+	TimedOut   bool  `json:"timedOut,omitempty"`
+	Canceled   bool  `json:"canceled,omitempty"`
+	DurationMs int64 `json:"durationMs"`
+}
+
+// OutputFunc gets the output of a command as it comes, from one goroutine per
+// stream
+type OutputFunc func(stream string, data []byte)
+
+// Manager runs commands in a workspace and keeps track of them, so that they
+// can be cancelled by id
+type Manager struct {
+	workspace *fs.Workspace
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewManager(workspace *fs.Workspace) *Manager {
+	return &Manager{workspace: workspace, running: make(map[string]context.CancelFunc)}
+}
+
+// Run runs req under id until it exits, times out, or ctx is done or Cancel
+// is called with id. An error is returned when it could not be started.
+func (m *Manager) Run(ctx context.Context, id string, req Request, onOutput OutputFunc) (Result, error) {
+	cmd, err := m.command(req)
+	if err != nil {
+		return Result{ExitCode: -1}, err
+	}
+
+	timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	timeout = min(timeout, MaxTimeout)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	m.mu.Lock()
+	if _, exists := m.running[id]; exists {
+		m.mu.Unlock()
+		return Result{ExitCode: -1}, fmt.Errorf("%w: %s", ErrAlreadyRunning, id)
+	}
+	m.running[id] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, id)
+		m.mu.Unlock()
+	}()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Result{ExitCode: -1}, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return Result{ExitCode: -1}, err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return Result{ExitCode: -1}, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go pipe(&wg, stdout, Stdout, onOutput)
+	go pipe(&wg, stderr, Stderr, onOutput)
+
+	// Stop the whole process group, the shell and what it started
+	waitDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+			select {
+			case <-waitDone:
+			case <-time.After(killDelay):
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			}
+		case <-waitDone:
+		}
+	}()
+
+	// Output is drained before Wait closes the pipes
+	wg.Wait()
+	waitErr := cmd.Wait()
+	close(waitDone)
+
+	result := Result{ExitCode: cmd.ProcessState.ExitCode(), DurationMs: time.Since(start).Milliseconds()}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+	case ctx.Err() != nil:
+		result.Canceled = true
+	}
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return result, waitErr
+	}
+	return result, nil
+}
+
+// NewId returns a random id to run a command under
+func NewId() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// Cancel stops the command running under id
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cancel, ok := m.running[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// command builds the process of req, in its own process group
+func (m *Manager) command(req Request) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	switch {
+	case len(req.Command) > 0:
+		cmd = exec.Command(req.Command[0], req.Command[1:]...)
+	case strings.TrimSpace(req.Shell) != "":
+		cmd = exec.Command("/bin/bash", "-c", req.Shell)
+	default:
+		return nil, ErrNoCommand
+	}
+	// The lookup of the program in PATH failed
+	if cmd.Err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, cmd.Err)
+	}
+
+	dir, err := m.workspace.Resolve(req.Cwd)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = dir
+
+	cmd.Env = os.Environ()
+	for key, value := range req.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	if req.Stdin != "" {
+		cmd.Stdin = strings.NewReader(req.Stdin)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, nil
+}
+
+func pipe(wg *sync.WaitGroup, r io.Reader, stream string, onOutput OutputFunc) {
+	defer wg.Done()
+
+	buffer := make([]byte, 4096)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 && onOutput != nil {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+			onOutput(stream, data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
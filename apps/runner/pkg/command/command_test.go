@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"runner/pkg/fs"
+)
+
+func newManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return NewManager(fs.NewWorkspace(dir)), dir
+}
+
+func TestRun(t *testing.T) {
+	m, dir := newManager(t)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		req        Request
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "argv",
+			req:        Request{Command: []string{"echo", "hello"}},
+			wantStdout: "hello\n",
+		},
+		{
+			name:       "shell with separate streams and exit code",
+			req:        Request{Shell: "echo out; echo err >&2; exit 3"},
+			wantCode:   3,
+			wantStdout: "out\n",
+			wantStderr: "err\n",
+		},
+		{
+			name:       "cwd, env and stdin",
+			req:        Request{Shell: `basename "$PWD"; echo "$GREETING"; cat`, Cwd: "sub", Env: map[string]string{"GREETING": "hi"}, Stdin: "input"},
+			wantStdout: "sub\nhi\ninput",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output Collector
+			result, err := m.Run(context.Background(), tt.name, tt.req, output.Write)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := output.Output()
+			if result.ExitCode != tt.wantCode || got.Stdout != tt.wantStdout || got.Stderr != tt.wantStderr {
+				t.Errorf("got code %d, stdout %q, stderr %q; want %d, %q, %q",
+					result.ExitCode, got.Stdout, got.Stderr, tt.wantCode, tt.wantStdout, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	m, _ := newManager(t)
+	result, err := m.Run(context.Background(), "sleep", Request{Shell: "sleep 10", TimeoutMs: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("got %+v, want a timed out command", result)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m, _ := newManager(t)
+	started := make(chan struct{})
+	done := make(chan Result)
+	go func() {
+		result, _ := m.Run(context.Background(), "long", Request{Shell: "echo started; sleep 10"}, func(stream string, data []byte) {
+			if strings.Contains(string(data), "started") {
+				close(started)
+			}
+		})
+		done <- result
+	}()
+
+	<-started
+	if _, err := m.Run(context.Background(), "long", Request{Shell: "true"}, nil); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("got %v, want ErrAlreadyRunning", err)
+	}
+	if !m.Cancel("long") {
+		t.Fatal("command is not running")
+	}
+	if result := <-done; !result.Canceled {
+		t.Errorf("got %+v, want a canceled command", result)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	m, _ := newManager(t)
+
+	if _, err := m.Run(context.Background(), "none", Request{}, nil); !errors.Is(err, ErrNoCommand) {
+		t.Errorf("got %v, want ErrNoCommand", err)
+	}
+	if _, err := m.Run(context.Background(), "missing", Request{Command: []string{"no-such-command-here"}}, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if _, err := m.Run(context.Background(), "outside", Request{Shell: "true", Cwd: "../.."}, nil); !errors.Is(err, fs.ErrOutsideWorkspace) {
+		t.Errorf("got %v, want ErrOutsideWorkspace", err)
+	}
+}
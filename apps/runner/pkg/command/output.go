@@ -0,0 +1,44 @@
+package command
+
+import "sync"
+
+// maxCollected bounds the output kept per stream by a Collector
+const maxCollected = 1 << 20
+
+// Output is the whole output of a command, for callers not streaming it
+type Output struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// Truncated is set when a stream went over 1MiB, the rest was dropped
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Collector keeps the output of a command
+type Collector struct {
+	mu        sync.Mutex
+	stdout    []byte
+	stderr    []byte
+	truncated bool
+}
+
+// Write is an OutputFunc
+func (c *Collector) Write(stream string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf := &c.stdout
+	if stream == Stderr {
+		buf = &c.stderr
+	}
+	if room := maxCollected - len(*buf); len(data) > room {
+		data = data[:room]
+		c.truncated = true
+	}
+	*buf = append(*buf, data...)
+}
+
+func (c *Collector) Output() Output {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Output{Stdout: string(c.stdout), Stderr: string(c.stderr), Truncated: c.truncated}
+}
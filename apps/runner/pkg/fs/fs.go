@@ -6,13 +6,19 @@ import (
 	"os"
 	"path/filepath"
 
+	"runner/pkg/dotenv"
+
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
+// WORKSPACE_DIR is where the repl's files live inside the runner
+var WORKSPACE_DIR = dotenv.EnvString("WORKSPACE_DIR", "/workspaces")
+
 var clipboard *Clipboard
 
-func FetchDir(basePath, relativePath string) ([]DirEntry, error) {
-	fullPath := filepath.Join(basePath, relativePath)
+// The helpers below take absolute paths already resolved by a Workspace
+
+func fetchDir(fullPath string) ([]DirEntry, error) {
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
 		return nil, err
@@ -27,38 +33,33 @@ func FetchDir(basePath, relativePath string) ([]DirEntry, error) {
 	return result, nil
 }
 
-func FetchFileContent(fullPath string) (string, error) {
+func fetchFileContent(fullPath string) (string, error) {
 	bytes, err := os.ReadFile(fullPath)
 	return string(bytes), err
 }
 
-func SaveFileDiffs(fullPath, patch string) error {
-	currentBytes, err := os.ReadFile(fullPath)
-	if err != nil {
-		return err
-	}
-	currentText := string(currentBytes)
-
+// applyPatch applies a diff-match-patch patch to text, every hunk must apply
+func applyPatch(text, patch string) (string, error) {
 	dmp := diffmatchpatch.New()
 	patches, err := dmp.PatchFromText(patch)
 	if err != nil {
-		return fmt.Errorf("invalid patch format: %v", err)
+		return "", fmt.Errorf("invalid patch format: %v", err)
 	}
 
-	newText, results := dmp.PatchApply(patches, currentText)
+	newText, results := dmp.PatchApply(patches, text)
 
 	// Check if any patches failed
 	for i, result := range results {
 		if !result {
-			return fmt.Errorf("patch %d failed to apply", i)
+			return "", fmt.Errorf("patch %d failed to apply", i)
 		}
 	}
 
-	return os.WriteFile(fullPath, []byte(newText), 0644)
+	return newText, nil
 }
 
-// CreateFile creates a new file at the specified path
-func CreateFile(path string) error {
+// createFile creates a new file at the specified path
+func createFile(path string) error {
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -75,62 +76,37 @@ func CreateFile(path string) error {
 	return nil
 }
 
-// CreateFolder creates a new folder at the specified path
-func CreateFolder(path string) error {
-	return os.MkdirAll(path, 0755)
-}
-
-// Delete removes a file or folder at the specified path
-func Delete(path string) error {
-	return os.RemoveAll(path)
-}
-
-// Rename renames a file or folder from oldPath to newPath
-func Rename(oldPath, newPath string) error {
-	return os.Rename(oldPath, newPath)
-}
-
-// Copy copies a file or folder from sourcePath to targetPath
-func Copy(sourcePath, targetPath string) error {
-	sourceInfo, err := os.Stat(sourcePath)
+// copyPath copies a file or folder from sourcePath to targetPath
+func copyPath(sourcePath, targetPath string) error {
+	sourceInfo, err := os.Lstat(sourcePath)
 	if err != nil {
 		return err
 	}
 
-	if sourceInfo.IsDir() {
+	switch {
+	case sourceInfo.IsDir():
 		return copyDir(sourcePath, targetPath)
+	case sourceInfo.Mode()&os.ModeSymlink != 0:
+		return copySymlink(sourcePath, targetPath)
+	default:
+		return copyFile(sourcePath, targetPath)
 	}
-	return copyFile(sourcePath, targetPath)
 }
 
-// Cut sets up a cut operation (stores the source path in clipboard)
-func Cut(sourcePath string) error {
-	// Verify the source exists
-	if _, err := os.Stat(sourcePath); err != nil {
-		return err
-	}
-
-	clipboard = &Clipboard{
-		SourcePath: sourcePath,
-		Operation:  "cut",
-	}
-	return nil
-}
-
-// Paste completes a cut or copy operation by moving/copying to targetPath
-func Paste(targetPath string) error {
+// paste completes a cut or copy operation by moving/copying to targetPath
+func paste(targetPath string) error {
 	if clipboard == nil {
 		return fmt.Errorf("nothing to paste")
 	}
 
 	var err error
 	if clipboard.Operation == "copy" {
-		err = Copy(clipboard.SourcePath, targetPath)
+		err = copyPath(clipboard.SourcePath, targetPath)
 	} else if clipboard.Operation == "cut" {
 		// For cut operation, we copy first then delete the source
-		err = Copy(clipboard.SourcePath, targetPath)
+		err = copyPath(clipboard.SourcePath, targetPath)
 		if err == nil {
-			err = Delete(clipboard.SourcePath)
+			err = os.RemoveAll(clipboard.SourcePath)
 		}
 	}
 
@@ -172,6 +148,20 @@ func copyFile(src, dst string) error {
 	return os.Chmod(dst, sourceInfo.Mode())
 }
 
+// copySymlink recreates the link src at dst, pointing at the same target.
+// The target is not copied: it is resolved (and checked) whenever the link
+// is used.
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
+
 // copyDir recursively copies a directory from src to dst
 func copyDir(src, dst string) error {
 	sourceInfo, err := os.Stat(src)
@@ -193,14 +183,9 @@ func copyDir(src, dst string) error {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
-		if entry.IsDir() {
-			if err := copyDir(srcPath, dstPath); err != nil {
-				return err
-			}
-		} else {
-			if err := copyFile(srcPath, dstPath); err != nil {
-				return err
-			}
+		// DirEntry does not follow symlinks, copyPath keeps them as links
+		if err := copyPath(srcPath, dstPath); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,135 @@
+package fs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// hunk replaces base lines [start, end) with lines
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// merge3 merges the changes made to base in mine and in theirs, line by line.
+// It fails when both sides changed the same or adjacent lines differently.
+func merge3(base, mine, theirs string) (string, bool) {
+	if mine == theirs {
+		return mine, true
+	}
+	if base == theirs {
+		return mine, true
+	}
+	if base == mine {
+		return theirs, true
+	}
+
+	baseLines := splitLines(base)
+	sides := [2][]hunk{lineHunks(base, mine), lineHunks(base, theirs)}
+
+	type sideHunk struct {
+		side int
+		hunk
+	}
+	var all []sideHunk
+	for side, hunks := range sides {
+		for _, h := range hunks {
+			all = append(all, sideHunk{side, h})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start < all[j].start })
+
+	var out strings.Builder
+	pos := 0
+	for i := 0; i < len(all); {
+		// Group the hunks touching each other into a region of base
+		start, end := all[i].start, all[i].end
+		regionHunks := [2][]hunk{}
+		for ; i < len(all) && all[i].start <= end; i++ {
+			if all[i].end > end {
+				end = all[i].end
+			}
+			regionHunks[all[i].side] = append(regionHunks[all[i].side], all[i].hunk)
+		}
+
+		out.WriteString(strings.Join(baseLines[pos:start], ""))
+
+		mineText := applyHunks(baseLines, start, end, regionHunks[0])
+		theirsText := applyHunks(baseLines, start, end, regionHunks[1])
+		switch {
+		case len(regionHunks[1]) == 0:
+			out.WriteString(mineText)
+		case len(regionHunks[0]) == 0:
+			out.WriteString(theirsText)
+		case mineText == theirsText:
+			out.WriteString(mineText)
+		default:
+			return "", false
+		}
+		pos = end
+	}
+	out.WriteString(strings.Join(baseLines[pos:], ""))
+
+	return out.String(), true
+}
+
+// lineHunks lists the line changes turning base into other
+func lineHunks(base, other string) []hunk {
+	dmp := diffmatchpatch.New()
+	baseChars, otherChars, lines := dmp.DiffLinesToChars(base, other)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(baseChars, otherChars, false), lines)
+
+	var hunks []hunk
+	var current *hunk
+	pos := 0
+	for _, diff := range diffs {
+		changed := splitLines(diff.Text)
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			pos += len(changed)
+		case diffmatchpatch.DiffDelete:
+			if current == nil {
+				current = &hunk{start: pos, end: pos}
+			}
+			pos += len(changed)
+			current.end = pos
+		case diffmatchpatch.DiffInsert:
+			if current == nil {
+				current = &hunk{start: pos, end: pos}
+			}
+			current.lines = append(current.lines, changed...)
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+	return hunks
+}
+
+// applyHunks returns base lines [start, end) with hunks applied
+func applyHunks(baseLines []string, start, end int, hunks []hunk) string {
+	var out strings.Builder
+	pos := start
+	for _, h := range hunks {
+		out.WriteString(strings.Join(baseLines[pos:h.start], ""))
+		out.WriteString(strings.Join(h.lines, ""))
+		pos = h.end
+	}
+	out.WriteString(strings.Join(baseLines[pos:end], ""))
+	return out.String()
+}
+
+// splitLines splits s after each "\n", keeping it
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
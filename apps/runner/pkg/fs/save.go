@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+)
+
+// SaveResult describes a saved file
+type SaveResult struct {
+	Version string `json:"version"`
+	// Merged is set when the file changed since the base version and the
+	// patch was merged with those changes. Content is the merged file then,
+	// which the client does not have yet.
+	Merged  bool   `json:"merged,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ConflictError is returned when a patch can not be merged with the changes
+// made to a file since its base version
+type ConflictError struct {
+	Path string `json:"path"`
+	// Content and Version are the file as it is now
+	Content string `json:"content"`
+	Version string `json:"version"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s changed since it was loaded and the changes conflict", e.Path)
+}
+
+// SaveFileDiffs applies a diff-match-patch patch made against baseVersion of
+// a file. When the file changed since (another tab, an agent, the terminal)
+// the patch is merged with those changes, or a *ConflictError is returned
+// with the current content. Without baseVersion the patch is applied to
+// whatever is on disk.
+func (w *Workspace) SaveFileDiffs(p, patch, baseVersion string) (SaveResult, error) {
+	full, err := w.Resolve(p)
+	if err != nil {
+		return SaveResult{}, err
+	}
+
+	w.saveMu.Lock()
+	defer w.saveMu.Unlock()
+
+	current, err := fetchFileContent(full)
+	if err != nil {
+		return SaveResult{}, err
+	}
+	currentVersion := ContentVersion(current)
+
+	var result SaveResult
+	var updated string
+	switch base, known := w.versions.get(baseVersion); {
+	case baseVersion == "" || baseVersion == currentVersion:
+		if updated, err = applyPatch(current, patch); err != nil {
+			return SaveResult{}, err
+		}
+	case known:
+		// Three-way merge of the client's edit and the changes on disk
+		mine, err := applyPatch(base, patch)
+		if err != nil {
+			return SaveResult{}, err
+		}
+		merged, ok := merge3(base, mine, current)
+		if !ok {
+			return SaveResult{}, &ConflictError{Path: p, Content: current, Version: w.versions.add(current)}
+		}
+		updated, result.Merged = merged, true
+	default:
+		// The base is gone, patches carry context so they may still apply
+		if updated, err = applyPatch(current, patch); err != nil {
+			return SaveResult{}, &ConflictError{Path: p, Content: current, Version: w.versions.add(current)}
+		}
+		result.Merged = true
+	}
+
+	if err := os.WriteFile(full, []byte(updated), 0644); err != nil {
+		return SaveResult{}, err
+	}
+
+	result.Version = w.versions.add(updated)
+	if result.Merged {
+		result.Content = updated
+	}
+	return result, nil
+}
+
+// WriteFile replaces the content of a file, e.g. with a shared document
+// edited by several clients. The saves of other clients are merged against
+// it like any change made on disk.
+func (w *Workspace) WriteFile(p, content string) error {
+	full, err := w.Resolve(p)
+	if err != nil {
+		return err
+	}
+
+	w.saveMu.Lock()
+	defer w.saveMu.Unlock()
+
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		return err
+	}
+	w.versions.add(content)
+	return nil
+}
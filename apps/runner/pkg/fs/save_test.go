@@ -0,0 +1,142 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// makePatch is what the editor sends for an edit from before to after
+func makePatch(before, after string) string {
+	dmp := diffmatchpatch.New()
+	return dmp.PatchToText(dmp.PatchMake(before, after))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const original = "line 1\nline 2\nline 3\nline 4\nline 5\n"
+
+func TestSaveFileDiffs(t *testing.T) {
+	tests := []struct {
+		name string
+		// onDisk replaces the file after it was fetched, "" keeps it
+		onDisk string
+		edit   string
+		want   string
+		merged bool
+		// conflict is set when the save must fail with a ConflictError
+		conflict bool
+	}{
+		{
+			name: "unchanged file",
+			edit: "line 1\nline two\nline 3\nline 4\nline 5\n",
+			want: "line 1\nline two\nline 3\nline 4\nline 5\n",
+		},
+		{
+			name:   "changes elsewhere are merged",
+			onDisk: "line 1\nline 2\nline 3\nline 4\nline five\n",
+			edit:   "line one\nline 2\nline 3\nline 4\nline 5\n",
+			want:   "line one\nline 2\nline 3\nline 4\nline five\n",
+			merged: true,
+		},
+		{
+			name:   "same change on both sides",
+			onDisk: "line 1\nline 2\nline three\nline 4\nline 5\n",
+			edit:   "line 1\nline 2\nline three\nline 4\nline 5\n",
+			want:   "line 1\nline 2\nline three\nline 4\nline 5\n",
+			merged: true,
+		},
+		{
+			name:     "same line changed differently",
+			onDisk:   "line 1\nline 2\nline III\nline 4\nline 5\n",
+			edit:     "line 1\nline 2\nline three\nline 4\nline 5\n",
+			conflict: true,
+		},
+		{
+			name:     "adjacent lines changed",
+			onDisk:   "line 1\nline 2\nline III\nline 4\nline 5\n",
+			edit:     "line 1\nline 2\nline 3\nline four\nline 5\n",
+			conflict: true,
+		},
+		{
+			name:   "lines inserted on both sides",
+			onDisk: "line 0\nline 1\nline 2\nline 3\nline 4\nline 5\n",
+			edit:   "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\n",
+			want:   "line 0\nline 1\nline 2\nline 3\nline 4\nline 5\nline 6\n",
+			merged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "file.txt")
+			writeFile(t, path, original)
+			ws := NewWorkspace(root)
+
+			content, version, err := ws.FetchFileContent("file.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.onDisk != "" {
+				writeFile(t, path, tt.onDisk)
+			}
+
+			result, err := ws.SaveFileDiffs("file.txt", makePatch(content, tt.edit), version)
+			if tt.conflict {
+				var conflict *ConflictError
+				if !errors.As(err, &conflict) {
+					t.Fatalf("error = %v, want a conflict", err)
+				}
+				if conflict.Content != tt.onDisk || conflict.Version != ContentVersion(tt.onDisk) {
+					t.Fatalf("conflict = %+v, want the file on disk", conflict)
+				}
+				if got := readFile(t, path); got != tt.onDisk {
+					t.Fatalf("file = %q, want it untouched", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFile(t, path); got != tt.want {
+				t.Fatalf("file = %q, want %q", got, tt.want)
+			}
+			if result.Version != ContentVersion(tt.want) || result.Merged != tt.merged {
+				t.Fatalf("result = %+v, want version of %q and merged %v", result, tt.want, tt.merged)
+			}
+			if tt.merged && result.Content != tt.want {
+				t.Fatalf("merged content = %q, want %q", result.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveFileDiffsUnknownBase(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "file.txt")
+	writeFile(t, path, original)
+
+	// Another runner process served the base, only the patch context is left
+	patch := makePatch(original, "line 1\nline 2\nline 3\nline 4\nline five\n")
+	writeFile(t, path, "line one\nline 2\nline 3\nline 4\nline 5\n")
+
+	result, err := NewWorkspace(root).SaveFileDiffs("file.txt", patch, ContentVersion(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "line one\nline 2\nline 3\nline 4\nline five\n"; !result.Merged || readFile(t, path) != want {
+		t.Fatalf("result = %+v, file = %q, want %q merged", result, readFile(t, path), want)
+	}
+}
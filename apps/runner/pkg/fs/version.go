@@ -0,0 +1,66 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+const (
+	// maxVersions is how many file contents are kept to merge edits made
+	// on top of an older version
+	maxVersions = 128
+	// maxVersionSize bounds the contents kept, larger files conflict
+	// instead of merging
+	maxVersionSize = 1 << 20
+)
+
+// ContentVersion identifies the content of a file. It is derived from the
+// content only, so that changes made outside of the runner (terminal, git)
+// change it too.
+func ContentVersion(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// versionCache keeps recent file contents by version, the bases of three-way
+// merges
+type versionCache struct {
+	mu       sync.Mutex
+	contents map[string]string
+	// order is oldest first
+	order []string
+}
+
+func newVersionCache() *versionCache {
+	return &versionCache{contents: make(map[string]string)}
+}
+
+// add remembers content and returns its version
+func (c *versionCache) add(content string) string {
+	version := ContentVersion(content)
+	if len(content) > maxVersionSize {
+		return version
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.contents[version]; ok {
+		return version
+	}
+	if len(c.order) >= maxVersions {
+		delete(c.contents, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.contents[version] = content
+	c.order = append(c.order, version)
+	return version
+}
+
+func (c *versionCache) get(version string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.contents[version]
+	return content, ok
+}
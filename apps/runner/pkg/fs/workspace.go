@@ -0,0 +1,260 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxSymlinks bounds the links followed while resolving a path, like the
+// kernel's ELOOP limit
+const maxSymlinks = 40
+
+var (
+	ErrOutsideWorkspace = errors.New("path is outside of the workspace")
+	ErrWorkspaceRoot    = errors.New("operation not allowed on the workspace root")
+	errTooManySymlinks  = errors.New("too many levels of symbolic links")
+)
+
+// Workspace is the filesystem of a repl. Paths given to it are relative to
+// its root (a leading "/" is the root too) and are resolved inside of it,
+// symlinks included: a path or link leading out of the root is rejected
+// with ErrOutsideWorkspace instead of being followed.
+type Workspace struct {
+	dir string
+
+	// saveMu serializes the read-merge-write of saves
+	saveMu   sync.Mutex
+	versions *versionCache
+}
+
+func NewWorkspace(dir string) *Workspace {
+	return &Workspace{dir: dir, versions: newVersionCache()}
+}
+
+// Dir is the root of the workspace as given to NewWorkspace
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Resolve returns the absolute path of p, following every symlink
+func (w *Workspace) Resolve(p string) (string, error) {
+	return w.resolve(p, true)
+}
+
+// Rel resolves p like Resolve and returns it relative to the root with
+// forward slashes, for tools running in the root (git). A symlink in the
+// last element is kept, those tools act on the link itself.
+func (w *Workspace) Rel(p string) (string, error) {
+	full, err := w.resolve(p, false)
+	if err != nil {
+		return "", err
+	}
+	root, err := w.Resolve(".")
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, full)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// resolve is Resolve, leaving a symlink in the last element of p alone when
+// followLast is false, for operations acting on the link itself (delete,
+// rename)
+func (w *Workspace) resolve(p string, followLast bool) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", ErrOutsideWorkspace
+	}
+
+	root, err := filepath.EvalSymlinks(w.dir)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	rel := filepath.Clean(strings.TrimLeft(filepath.FromSlash(p), string(filepath.Separator)))
+	if !filepath.IsLocal(rel) && rel != "." {
+		return "", ErrOutsideWorkspace
+	}
+
+	// Walk p one element at a time from the root. current never contains a
+	// symlink, so ".." in link targets can be resolved lexically against it.
+	current := root
+	pending := splitPath(rel)
+	links := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		next := filepath.Join(current, name)
+		info, err := os.Lstat(next)
+		if errors.Is(err, os.ErrNotExist) {
+			// Nothing below a missing element can be a link, the rest is
+			// taken as is (e.g. the file about to be created)
+			return filepath.Join(append([]string{next}, pending...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 || (len(pending) == 0 && !followLast) {
+			current = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", errTooManySymlinks
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(current, target)
+		}
+
+		// Start over from the root with what the link points to
+		targetRel, err := filepath.Rel(root, filepath.Clean(target))
+		if err != nil || !(filepath.IsLocal(targetRel) || targetRel == ".") {
+			return "", ErrOutsideWorkspace
+		}
+		current = root
+		pending = append(splitPath(targetRel), pending...)
+	}
+
+	return current, nil
+}
+
+func splitPath(rel string) []string {
+	if rel == "." {
+		return nil
+	}
+	return strings.Split(rel, string(filepath.Separator))
+}
+
+// resolveEntry resolves a path that must not be the root itself
+func (w *Workspace) resolveEntry(p string, followLast bool) (string, error) {
+	full, err := w.resolve(p, followLast)
+	if err != nil {
+		return "", err
+	}
+	root, err := w.Resolve(".")
+	if err != nil {
+		return "", err
+	}
+	if full == root {
+		return "", ErrWorkspaceRoot
+	}
+	return full, nil
+}
+
+func (w *Workspace) FetchDir(p string) ([]DirEntry, error) {
+	full, err := w.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return fetchDir(full)
+}
+
+// FetchFileContent returns the content of a file and its version, the base
+// for SaveFileDiffs
+func (w *Workspace) FetchFileContent(p string) (content, version string, err error) {
+	full, err := w.Resolve(p)
+	if err != nil {
+		return "", "", err
+	}
+	content, err = fetchFileContent(full)
+	if err != nil {
+		return "", "", err
+	}
+	return content, w.versions.add(content), nil
+}
+
+// CreateFile creates an empty file, and its parent folders
+func (w *Workspace) CreateFile(p string) error {
+	full, err := w.resolveEntry(p, true)
+	if err != nil {
+		return err
+	}
+	return createFile(full)
+}
+
+// CreateFolder creates a folder, and its parent folders
+func (w *Workspace) CreateFolder(p string) error {
+	full, err := w.Resolve(p)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(full, 0755)
+}
+
+// Delete removes a file or folder. A symlink is removed, not its target.
+func (w *Workspace) Delete(p string) error {
+	full, err := w.resolveEntry(p, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(full)
+}
+
+// Rename moves a file or folder, a symlink is moved as a link
+func (w *Workspace) Rename(oldPath, newPath string) error {
+	oldFull, err := w.resolveEntry(oldPath, false)
+	if err != nil {
+		return err
+	}
+	newFull, err := w.resolveEntry(newPath, false)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldFull, newFull)
+}
+
+// Copy copies a file or folder. Symlinks inside a copied folder are copied
+// as links, so a link leading out of the workspace never copies its target.
+func (w *Workspace) Copy(sourcePath, targetPath string) error {
+	sourceFull, err := w.Resolve(sourcePath)
+	if err != nil {
+		return err
+	}
+	targetFull, err := w.resolveEntry(targetPath, true)
+	if err != nil {
+		return err
+	}
+	return copyPath(sourceFull, targetFull)
+}
+
+// Cut sets up a cut operation (stores the source path in clipboard)
+func (w *Workspace) Cut(sourcePath string) error {
+	sourceFull, err := w.resolveEntry(sourcePath, false)
+	if err != nil {
+		return err
+	}
+
+	// Verify the source exists
+	if _, err := os.Lstat(sourceFull); err != nil {
+		return err
+	}
+
+	clipboard = &Clipboard{
+		SourcePath: sourceFull,
+		Operation:  "cut",
+	}
+	return nil
+}
+
+// Paste completes a cut or copy operation by moving/copying to targetPath
+func (w *Workspace) Paste(targetPath string) error {
+	targetFull, err := w.resolveEntry(targetPath, true)
+	if err != nil {
+		return err
+	}
+	return paste(targetFull)
+}
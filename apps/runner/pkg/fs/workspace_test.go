@@ -0,0 +1,288 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestWorkspace returns a workspace next to an "outside" folder holding a
+// secret file, both in a fresh temp dir
+func newTestWorkspace(t *testing.T) (ws *Workspace, root, outside string) {
+	t.Helper()
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.Join(base, "workspace")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, filepath.Join(root, "src", "pkg")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(outside, "secret"), "secret")
+	writeFile(t, filepath.Join(root, "src", "main.go"), "package main")
+
+	return NewWorkspace(root), root, outside
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ws, root, outside := newTestWorkspace(t)
+
+	symlink(t, outside, filepath.Join(root, "abs-out"))
+	symlink(t, "../outside", filepath.Join(root, "rel-out"))
+	symlink(t, "../../outside/secret", filepath.Join(root, "src", "deep-out"))
+	symlink(t, "src/main.go", filepath.Join(root, "in"))
+	symlink(t, filepath.Join(root, "src"), filepath.Join(root, "abs-in"))
+	symlink(t, "../main.go", filepath.Join(root, "src", "pkg", "up-in"))
+	symlink(t, "in", filepath.Join(root, "chain-in"))
+	symlink(t, "rel-out", filepath.Join(root, "chain-out"))
+	symlink(t, "../missing", filepath.Join(root, "dangling-out"))
+	symlink(t, "missing", filepath.Join(root, "dangling-in"))
+	symlink(t, "loop-b", filepath.Join(root, "loop-a"))
+	symlink(t, "loop-a", filepath.Join(root, "loop-b"))
+	symlink(t, ".", filepath.Join(root, "self"))
+	symlink(t, "..", filepath.Join(root, "parent"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+		err  error
+	}{
+		{"root", "", root, nil},
+		{"dot", ".", root, nil},
+		{"file", "src/main.go", filepath.Join(root, "src", "main.go"), nil},
+		{"leading slash is the root", "/src/main.go", filepath.Join(root, "src", "main.go"), nil},
+		{"dot dot inside", "src/pkg/../main.go", filepath.Join(root, "src", "main.go"), nil},
+		{"missing file", "src/new.go", filepath.Join(root, "src", "new.go"), nil},
+		{"missing folders", "a/b/c.go", filepath.Join(root, "a", "b", "c.go"), nil},
+
+		{"dot dot", "..", "", ErrOutsideWorkspace},
+		{"dot dot file", "../outside/secret", "", ErrOutsideWorkspace},
+		{"etc passwd", "../../etc/passwd", "", ErrOutsideWorkspace},
+		{"dot dot after folder", "src/../../outside/secret", "", ErrOutsideWorkspace},
+		{"dot dot with slash", "/../outside/secret", "", ErrOutsideWorkspace},
+		{"nul byte", "src/main.go\x00.txt", "", ErrOutsideWorkspace},
+
+		{"absolute link out", "abs-out/secret", "", ErrOutsideWorkspace},
+		{"relative link out", "rel-out/secret", "", ErrOutsideWorkspace},
+		{"link out as last element", "rel-out", "", ErrOutsideWorkspace},
+		{"nested link out", "src/deep-out", "", ErrOutsideWorkspace},
+		{"chained link out", "chain-out/secret", "", ErrOutsideWorkspace},
+		{"dangling link out", "dangling-out", "", ErrOutsideWorkspace},
+		{"link to parent", "parent/outside/secret", "", ErrOutsideWorkspace},
+		{"link loop", "loop-a", "", errTooManySymlinks},
+
+		{"relative link in", "in", filepath.Join(root, "src", "main.go"), nil},
+		{"absolute link in", "abs-in/main.go", filepath.Join(root, "src", "main.go"), nil},
+		{"link with dot dot in", "src/pkg/up-in", filepath.Join(root, "src", "main.go"), nil},
+		{"chained link in", "chain-in", filepath.Join(root, "src", "main.go"), nil},
+		{"dangling link in", "dangling-in", filepath.Join(root, "missing"), nil},
+		{"link to self", "self/self/src", filepath.Join(root, "src"), nil},
+		// ".." is applied to the path before links are followed
+		{"dot dot after link to self", "self/../outside/secret", filepath.Join(root, "outside", "secret"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ws.Resolve(tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Resolve(%q) error = %v, want %v", tt.path, err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSymlinkedRoot(t *testing.T) {
+	_, root, _ := newTestWorkspace(t)
+
+	link := filepath.Join(filepath.Dir(root), "root-link")
+	symlink(t, root, link)
+
+	got, err := NewWorkspace(link).Resolve("src/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "src", "main.go"); got != want {
+		t.Fatalf("Resolve = %q, want %q", got, want)
+	}
+}
+
+func TestRel(t *testing.T) {
+	ws, root, outside := newTestWorkspace(t)
+	symlink(t, outside, filepath.Join(root, "out"))
+	symlink(t, "src", filepath.Join(root, "src-link"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+		err  error
+	}{
+		{"root", "", ".", nil},
+		{"file", "src/main.go", "src/main.go", nil},
+		{"leading slash is the root", "/src/main.go", "src/main.go", nil},
+		{"dot dot inside", "src/pkg/../main.go", "src/main.go", nil},
+		{"missing file", "src/new.go", "src/new.go", nil},
+		{"link is kept as last element", "out", "out", nil},
+		{"link is followed before the last element", "src-link/main.go", "src/main.go", nil},
+
+		{"dot dot", "..", "", ErrOutsideWorkspace},
+		{"dot dot file", "../outside/secret", "", ErrOutsideWorkspace},
+		{"dot dot after folder", "src/../../outside/secret", "", ErrOutsideWorkspace},
+		{"through link out", "out/secret", "", ErrOutsideWorkspace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ws.Rel(tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Rel(%q) error = %v, want %v", tt.path, err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("Rel(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationsStayInside(t *testing.T) {
+	ws, root, outside := newTestWorkspace(t)
+	secret := filepath.Join(outside, "secret")
+	symlink(t, outside, filepath.Join(root, "out"))
+	symlink(t, secret, filepath.Join(root, "secret-link"))
+
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"fetch dir", func() error { _, err := ws.FetchDir("out"); return err }},
+		{"fetch dir dot dot", func() error { _, err := ws.FetchDir(".."); return err }},
+		{"fetch content", func() error { _, _, err := ws.FetchFileContent("../outside/secret"); return err }},
+		{"fetch content link", func() error { _, _, err := ws.FetchFileContent("secret-link"); return err }},
+		{"save diffs", func() error { _, err := ws.SaveFileDiffs("secret-link", "", ""); return err }},
+		{"create file", func() error { return ws.CreateFile("../outside/new") }},
+		{"create file through link", func() error { return ws.CreateFile("secret-link") }},
+		{"create folder", func() error { return ws.CreateFolder("out/new") }},
+		{"delete", func() error { return ws.Delete("../outside/secret") }},
+		{"delete through link", func() error { return ws.Delete("out/secret") }},
+		{"rename source", func() error { return ws.Rename("../outside/secret", "stolen") }},
+		{"rename target", func() error { return ws.Rename("src/main.go", "../outside/main.go") }},
+		{"rename target through link", func() error { return ws.Rename("src/main.go", "out/main.go") }},
+		{"copy source", func() error { return ws.Copy("out/secret", "stolen") }},
+		{"copy target", func() error { return ws.Copy("src/main.go", "out/main.go") }},
+		{"cut", func() error { return ws.Cut("../outside/secret") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, ErrOutsideWorkspace) {
+				t.Fatalf("error = %v, want %v", err, ErrOutsideWorkspace)
+			}
+		})
+	}
+
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "secret" {
+		t.Fatalf("secret changed: %q, %v", data, err)
+	}
+	entries, err := os.ReadDir(outside)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("outside folder changed: %v, %v", entries, err)
+	}
+}
+
+func TestRootIsProtected(t *testing.T) {
+	ws, root, _ := newTestWorkspace(t)
+
+	for _, p := range []string{"", ".", "/", "src/.."} {
+		if err := ws.Delete(p); !errors.Is(err, ErrWorkspaceRoot) {
+			t.Fatalf("Delete(%q) error = %v, want %v", p, err, ErrWorkspaceRoot)
+		}
+		if err := ws.Rename(p, "moved"); !errors.Is(err, ErrWorkspaceRoot) {
+			t.Fatalf("Rename(%q) error = %v, want %v", p, err, ErrWorkspaceRoot)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "src", "main.go")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteRemovesLinkNotTarget(t *testing.T) {
+	ws, root, _ := newTestWorkspace(t)
+	symlink(t, "src", filepath.Join(root, "src-link"))
+
+	if err := ws.Delete("src-link"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "src-link")); !os.IsNotExist(err) {
+		t.Fatalf("link still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src", "main.go")); err != nil {
+		t.Fatalf("link target removed: %v", err)
+	}
+}
+
+func TestCopyKeepsSymlinks(t *testing.T) {
+	ws, root, outside := newTestWorkspace(t)
+	symlink(t, filepath.Join(outside, "secret"), filepath.Join(root, "src", "secret-link"))
+
+	if err := ws.Copy("src", "copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := filepath.Join(root, "copy", "secret-link")
+	info, err := os.Lstat(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s is not a symlink, the target was copied", copied)
+	}
+	if _, _, err := ws.FetchFileContent("copy/secret-link"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Fatalf("error = %v, want %v", err, ErrOutsideWorkspace)
+	}
+	if content, _, err := ws.FetchFileContent("copy/main.go"); err != nil || content != "package main" {
+		t.Fatalf("copy/main.go = %q, %v", content, err)
+	}
+}
+
+func TestCutAndPaste(t *testing.T) {
+	ws, root, _ := newTestWorkspace(t)
+
+	if err := ws.Cut("src/main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Paste("../main.go"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Fatalf("error = %v, want %v", err, ErrOutsideWorkspace)
+	}
+	if err := ws.Paste("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src", "main.go")); !os.IsNotExist(err) {
+		t.Fatalf("cut source still there: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+type Branch struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current,omitempty"`
+	// Remote branches are named <remote>/<branch>
+	Remote   bool   `json:"remote,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+	Hash     string `json:"hash"`
+}
+
+var errInvalidName = errors.New("invalid branch or remote name")
+
+// validName keeps user supplied names from being read as options
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "-")
+}
+
+// Branches lists the local and remote tracking branches
+func (r *Repo) Branches(ctx context.Context) ([]Branch, error) {
+	out, err := r.local(ctx, "for-each-ref",
+		"--format=%(refname)%1f%(refname:short)%1f%(HEAD)%1f%(upstream:short)%1f%(objectname)",
+		"refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []Branch{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 || strings.HasSuffix(fields[0], "/HEAD") {
+			continue
+		}
+		branches = append(branches, Branch{
+			Name:     fields[1],
+			Current:  fields[2] == "*",
+			Remote:   strings.HasPrefix(fields[0], "refs/remotes/"),
+			Upstream: fields[3],
+			Hash:     fields[4],
+		})
+	}
+	return branches, nil
+}
+
+// CreateBranch creates name at startPoint (HEAD when empty) and switches to
+// it when checkout is set
+func (r *Repo) CreateBranch(ctx context.Context, name, startPoint string, checkout bool) error {
+	if !validName(name) || strings.HasPrefix(startPoint, "-") {
+		return errInvalidName
+	}
+	if _, err := r.local(ctx, "check-ref-format", "--branch", name); err != nil {
+		return errInvalidName
+	}
+
+	args := []string{"branch", name}
+	if checkout {
+		args = []string{"switch", "--create", name}
+	}
+	if startPoint != "" {
+		args = append(args, startPoint)
+	}
+	_, err := r.local(ctx, args...)
+	return err
+}
+
+// Checkout switches to a branch. A remote branch without a local one gets a
+// local tracking branch.
+func (r *Repo) Checkout(ctx context.Context, name string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	_, err := r.local(ctx, "switch", name)
+	return err
+}
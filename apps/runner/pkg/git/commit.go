@@ -0,0 +1,110 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Commit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"shortHash"`
+	Parents   []string  `json:"parents"`
+	Author    Author    `json:"author"`
+	Date      time.Time `json:"date"`
+	Subject   string    `json:"subject"`
+}
+
+var ErrNothingToCommit = errors.New("nothing to commit")
+
+// Commit records the staged changes. author falls back to the git config of
+// the workspace, and to a placeholder when there is none.
+func (r *Repo) Commit(ctx context.Context, message string, author Author) (Commit, error) {
+	if strings.TrimSpace(message) == "" {
+		return Commit{}, errors.New("commit message is empty")
+	}
+
+	if author.Name == "" {
+		author.Name, _ = r.local(ctx, "config", "user.name")
+		author.Name = strings.TrimSpace(author.Name)
+	}
+	if author.Email == "" {
+		author.Email, _ = r.local(ctx, "config", "user.email")
+		author.Email = strings.TrimSpace(author.Email)
+	}
+	if author.Name == "" {
+		author.Name = defaultAuthorName
+	}
+	if author.Email == "" {
+		author.Email = defaultAuthorEmail
+	}
+
+	// Staged nothing: git would fail with its whole status as message
+	if _, err := r.local(ctx, "diff", "--cached", "--quiet"); err == nil && r.hasCommits(ctx) {
+		return Commit{}, ErrNothingToCommit
+	}
+
+	if _, err := r.local(ctx, "-c", "user.name="+author.Name, "-c", "user.email="+author.Email,
+		"commit", "--quiet", "--cleanup=strip", "--message="+message); err != nil {
+		return Commit{}, err
+	}
+
+	commits, err := r.Log(ctx, 1, "")
+	if err != nil || len(commits) == 0 {
+		return Commit{}, err
+	}
+	return commits[0], nil
+}
+
+// Log lists the latest commits of HEAD, touching path when it is set
+func (r *Repo) Log(ctx context.Context, limit int, path string) ([]Commit, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if path != "" {
+		rel, err := r.path(path)
+		if err != nil {
+			return nil, err
+		}
+		path = rel
+	}
+	if !r.hasCommits(ctx) {
+		return []Commit{}, nil
+	}
+
+	args := []string{"log", "-z", "--max-count=" + strconv.Itoa(limit),
+		"--format=%H%x1f%h%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%s"}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+
+	out, err := r.local(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []Commit{}
+	for _, record := range strings.Split(out, "\x00") {
+		fields := strings.Split(strings.TrimPrefix(record, "\n"), "\x1f")
+		if len(fields) != 7 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[5])
+		commits = append(commits, Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Parents:   strings.Fields(fields[2]),
+			Author:    Author{Name: fields[3], Email: fields[4]},
+			Date:      date,
+			Subject:   fields[6],
+		})
+	}
+	return commits, nil
+}
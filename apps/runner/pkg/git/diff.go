@@ -0,0 +1,141 @@
+package git
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxDiffContent bounds the file contents sent along with a diff
+const maxDiffContent = 1 << 20
+
+// Diff of a single file, as a patch and as both sides for a side by side view
+type Diff struct {
+	Path   string `json:"path"`
+	Staged bool   `json:"staged"`
+	Patch  string `json:"patch"`
+	// Original and Modified are left empty for binary and large files
+	Original string `json:"original"`
+	Modified string `json:"modified"`
+	Binary   bool   `json:"binary,omitempty"`
+}
+
+// Diff compares a file with the index (unstaged) or the index with HEAD
+// (staged). Untracked files diff against an empty file.
+func (r *Repo) Diff(ctx context.Context, path string, staged bool) (Diff, error) {
+	diff := Diff{Path: path, Staged: staged}
+	path, err := r.path(path)
+	if err != nil {
+		return diff, err
+	}
+
+	args := []string{"diff", "--no-color"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	patch, err := r.local(ctx, append(args, "--", path)...)
+	if err != nil {
+		return diff, err
+	}
+
+	if patch == "" && !staged && !r.tracked(ctx, path) {
+		// --no-index exits with 1 when the files differ, keep what it printed
+		patch, err = r.local(ctx, "diff", "--no-color", "--no-index", "--", os.DevNull, path)
+		if err != nil && patch == "" {
+			return diff, err
+		}
+	}
+	diff.Patch = patch
+	diff.Binary = strings.Contains(patch, "\nBinary files ") || strings.HasPrefix(patch, "Binary files ")
+	if diff.Binary {
+		return diff, nil
+	}
+
+	if staged {
+		diff.Original = r.show(ctx, "HEAD:"+path)
+		diff.Modified = r.show(ctx, ":"+path)
+	} else {
+		diff.Original = r.show(ctx, ":"+path)
+		diff.Modified = r.readFile(path)
+	}
+	return diff, nil
+}
+
+// tracked reports whether path is in the index
+func (r *Repo) tracked(ctx context.Context, path string) bool {
+	_, err := r.local(ctx, "ls-files", "--error-unmatch", "--", path)
+	return err == nil
+}
+
+// show returns an object's content, "" when it does not exist or is too large
+func (r *Repo) show(ctx context.Context, object string) string {
+	out, err := r.local(ctx, "cat-file", "-p", object)
+	if err != nil || len(out) > maxDiffContent {
+		return ""
+	}
+	return out
+}
+
+// readFile returns the content of a file of the workspace, "" when it does
+// not exist, is too large or is a link leading out of the workspace
+func (r *Repo) readFile(path string) string {
+	full, err := r.workspace.Resolve(path)
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxDiffContent+1))
+	if err != nil || len(data) > maxDiffContent {
+		return ""
+	}
+	return string(data)
+}
+
+// Stage adds the given paths (everything when empty) to the index, including
+// deletions
+func (r *Repo) Stage(ctx context.Context, paths []string) error {
+	paths, err := r.paths(paths)
+	if err != nil {
+		return err
+	}
+	_, err = r.local(ctx, append([]string{"add", "--all", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes the given paths (everything when empty) from the index,
+// keeping the files as they are
+func (r *Repo) Unstage(ctx context.Context, paths []string) error {
+	paths, err := r.paths(paths)
+	if err != nil {
+		return err
+	}
+	if !r.hasCommits(ctx) {
+		// Nothing to reset to before the first commit
+		_, err = r.local(ctx, append([]string{"rm", "--cached", "-r", "-q", "--"}, paths...)...)
+		return err
+	}
+	_, err = r.local(ctx, append([]string{"reset", "-q", "--"}, paths...)...)
+	return err
+}
+
+// paths resolves the paths of a request, everything when there are none
+func (r *Repo) paths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{"."}, nil
+	}
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		rel, err := r.path(p)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = rel
+	}
+	return resolved, nil
+}
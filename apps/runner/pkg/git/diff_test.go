@@ -0,0 +1,50 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"runner/pkg/fs"
+)
+
+func TestPathsStayInside(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	dir := filepath.Join(base, "workspace")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(base, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := New(fs.NewWorkspace(dir))
+	if err := repo.Init(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"diff", func() error { _, err := repo.Diff(ctx, "../secret", false); return err }},
+		{"diff through link", func() error { _, err := repo.Diff(ctx, "out/secret", false); return err }},
+		{"stage", func() error { return repo.Stage(ctx, []string{"main.go", "../secret"}) }},
+		{"unstage", func() error { return repo.Unstage(ctx, []string{"out/secret"}) }},
+		{"log", func() error { _, err := repo.Log(ctx, 10, "../secret"); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, fs.ErrOutsideWorkspace) {
+				t.Fatalf("error = %v, want %v", err, fs.ErrOutsideWorkspace)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package git drives the git binary in a workspace and turns its porcelain
+// output into structures the editor can render as a source control panel.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"runner/pkg/fs"
+)
+
+const (
+	// commandTimeout bounds local commands (status, diff, commit, ...)
+	commandTimeout = 30 * time.Second
+	// remoteTimeout bounds commands talking to a remote (push, pull)
+	remoteTimeout = 2 * time.Minute
+)
+
+// Committer used when neither the request nor the git config has one
+const (
+	defaultAuthorName  = "DevEx"
+	defaultAuthorEmail = "devex@localhost"
+)
+
+var ErrNotRepository = errors.New("workspace is not a git repository")
+
+// Repo is the git repository of a workspace
+type Repo struct {
+	dir       string
+	workspace *fs.Workspace
+}
+
+func New(workspace *fs.Workspace) *Repo {
+	return &Repo{dir: workspace.Dir(), workspace: workspace}
+}
+
+// path resolves a path given by a client to one git can take, rejecting the
+// ones leading out of the workspace with fs.ErrOutsideWorkspace
+func (r *Repo) path(p string) (string, error) {
+	return r.workspace.Rel(p)
+}
+
+// Credentials for push/pull over HTTPS. They are handed to git through the
+// environment, never on the command line.
+type Credentials struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// credentialHelper answers git's credential requests from the environment
+const credentialHelper = `!f() { echo "username=${DEVEX_GIT_USERNAME}"; echo "password=${DEVEX_GIT_TOKEN}"; }; f`
+
+// run executes git in the workspace and returns its stdout. Failures carry
+// git's stderr, which is what users need to see (conflicts, rejected pushes).
+func (r *Repo) run(ctx context.Context, env []string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if strings.Contains(message, "not a git repository") {
+			return "", ErrNotRepository
+		}
+		if message == "" {
+			message = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+func (r *Repo) local(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	return r.run(ctx, nil, args...)
+}
+
+func (r *Repo) remote(ctx context.Context, creds Credentials, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+
+	var env []string
+	if creds.Token != "" {
+		username := creds.Username
+		if username == "" {
+			// GitHub ignores the username for tokens but git wants one
+			username = "x-access-token"
+		}
+		env = []string{"DEVEX_GIT_USERNAME=" + username, "DEVEX_GIT_TOKEN=" + creds.Token}
+		args = append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}, args...)
+	}
+	return r.run(ctx, env, args...)
+}
+
+// Init turns the workspace into a git repository
+func (r *Repo) Init(ctx context.Context, branch string) error {
+	if branch == "" {
+		branch = "main"
+	}
+	_, err := r.local(ctx, "init", "--initial-branch="+branch)
+	return err
+}
+
+// hasCommits reports whether HEAD points at a commit yet
+func (r *Repo) hasCommits(ctx context.Context) bool {
+	_, err := r.local(ctx, "rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
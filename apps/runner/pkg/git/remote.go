@@ -0,0 +1,54 @@
+package git
+
+import (
+	"context"
+)
+
+// Push sends branch (the current one when empty) to remote ("origin" when
+// empty), setting the upstream of branches that have none yet.
+func (r *Repo) Push(ctx context.Context, remote, branch string, creds Credentials) error {
+	remote, branch, err := remoteAndBranch(remote, branch)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"push", "--porcelain"}
+	if _, err := r.local(ctx, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err != nil {
+		args = append(args, "--set-upstream")
+	}
+	_, err = r.remote(ctx, creds, append(args, remote, branch)...)
+	return err
+}
+
+// Pull fetches and merges (or rebases onto) the upstream of the current
+// branch, or branch of remote when given
+func (r *Repo) Pull(ctx context.Context, remote, branch string, rebase bool, creds Credentials) error {
+	args := []string{"pull", "--no-edit", "--no-rebase"}
+	if rebase {
+		args[2] = "--rebase"
+	}
+
+	if remote != "" || branch != "" {
+		remote, branch, err := remoteAndBranch(remote, branch)
+		if err != nil {
+			return err
+		}
+		args = append(args, remote, branch)
+	}
+
+	_, err := r.remote(ctx, creds, args...)
+	return err
+}
+
+func remoteAndBranch(remote, branch string) (string, string, error) {
+	if remote == "" {
+		remote = "origin"
+	}
+	if branch == "" {
+		branch = "HEAD"
+	}
+	if !validName(remote) || !validName(branch) {
+		return "", "", errInvalidName
+	}
+	return remote, branch, nil
+}
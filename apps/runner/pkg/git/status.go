@@ -0,0 +1,140 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Change kinds of a file, for the staged (index) and unstaged (worktree) side
+const (
+	ChangeNone       = ""
+	ChangeModified   = "modified"
+	ChangeAdded      = "added"
+	ChangeDeleted    = "deleted"
+	ChangeRenamed    = "renamed"
+	ChangeCopied     = "copied"
+	ChangeTypeChange = "typechange"
+	ChangeUntracked  = "untracked"
+	ChangeConflicted = "conflicted"
+)
+
+var changeCodes = map[byte]string{
+	'.': ChangeNone,
+	'M': ChangeModified,
+	'A': ChangeAdded,
+	'D': ChangeDeleted,
+	'R': ChangeRenamed,
+	'C': ChangeCopied,
+	'T': ChangeTypeChange,
+}
+
+type FileStatus struct {
+	Path string `json:"path"`
+	// OrigPath is the path before a rename or copy
+	OrigPath string `json:"origPath,omitempty"`
+	Staged   string `json:"staged"`
+	Unstaged string `json:"unstaged"`
+}
+
+type Status struct {
+	// Initialized is false when the workspace is not a git repository
+	Initialized bool   `json:"initialized"`
+	Branch      string `json:"branch,omitempty"`
+	Upstream    string `json:"upstream,omitempty"`
+	Ahead       int    `json:"ahead"`
+	Behind      int    `json:"behind"`
+	// Detached is set when HEAD is not on a branch
+	Detached bool         `json:"detached,omitempty"`
+	Files    []FileStatus `json:"files"`
+}
+
+// Status lists the changed files of the workspace
+func (r *Repo) Status(ctx context.Context) (Status, error) {
+	out, err := r.local(ctx, "status", "--porcelain=v2", "--branch", "--untracked-files=all", "-z")
+	if errors.Is(err, ErrNotRepository) {
+		return Status{Files: []FileStatus{}}, nil
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	return parseStatus(out), nil
+}
+
+// parseStatus reads `git status --porcelain=v2 --branch -z`
+func parseStatus(out string) Status {
+	status := Status{Initialized: true, Files: []FileStatus{}}
+
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 2 {
+			continue
+		}
+
+		switch entry[0] {
+		case '#':
+			parseBranchHeader(&status, entry)
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			if fields := strings.SplitN(entry, " ", 9); len(fields) == 9 {
+				status.Files = append(status.Files, fileStatus(fields[1], fields[8]))
+			}
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path, followed by the original path
+			if fields := strings.SplitN(entry, " ", 10); len(fields) == 10 {
+				file := fileStatus(fields[1], fields[9])
+				if i+1 < len(entries) {
+					i++
+					file.OrigPath = entries[i]
+				}
+				status.Files = append(status.Files, file)
+			}
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if fields := strings.SplitN(entry, " ", 11); len(fields) == 11 {
+				status.Files = append(status.Files, FileStatus{
+					Path:     fields[10],
+					Staged:   ChangeConflicted,
+					Unstaged: ChangeConflicted,
+				})
+			}
+		case '?':
+			status.Files = append(status.Files, FileStatus{Path: entry[2:], Unstaged: ChangeUntracked})
+		}
+	}
+
+	return status
+}
+
+func parseBranchHeader(status *Status, header string) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return
+	}
+
+	switch fields[1] {
+	case "branch.head":
+		if fields[2] == "(detached)" {
+			status.Detached = true
+		} else {
+			status.Branch = fields[2]
+		}
+	case "branch.upstream":
+		status.Upstream = fields[2]
+	case "branch.ab":
+		if len(fields) == 4 {
+			status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+		}
+	}
+}
+
+func fileStatus(xy, path string) FileStatus {
+	return FileStatus{
+		Path:     path,
+		Staged:   changeCodes[xy[0]],
+		Unstaged: changeCodes[xy[1]],
+	}
+}
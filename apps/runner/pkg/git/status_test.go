@@ -0,0 +1,140 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	oid  = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	oid2 = "5716ca5987cbf97d6bb54920bea6adde242d87e6"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    Status
+	}{
+		{
+			name: "clean",
+			entries: []string{
+				"# branch.oid " + oid,
+				"# branch.head main",
+			},
+			want: Status{Initialized: true, Branch: "main", Files: []FileStatus{}},
+		},
+		{
+			name: "no commits yet",
+			entries: []string{
+				"# branch.oid (initial)",
+				"# branch.head main",
+				"? main.go",
+			},
+			want: Status{Initialized: true, Branch: "main", Files: []FileStatus{
+				{Path: "main.go", Unstaged: ChangeUntracked},
+			}},
+		},
+		{
+			name: "changed files",
+			entries: []string{
+				"# branch.oid " + oid,
+				"# branch.head main",
+				"1 .M N... 100644 100644 100644 " + oid + " " + oid + " main.go",
+				"1 A. N... 000000 100644 100644 " + oid + " " + oid + " src/app.js",
+				"1 MD N... 100644 100644 000000 " + oid + " " + oid2 + " go.mod",
+				"1 .T N... 100644 100644 120000 " + oid + " " + oid + " link",
+				"? notes/to do.txt",
+			},
+			want: Status{Initialized: true, Branch: "main", Files: []FileStatus{
+				{Path: "main.go", Unstaged: ChangeModified},
+				{Path: "src/app.js", Staged: ChangeAdded},
+				{Path: "go.mod", Staged: ChangeModified, Unstaged: ChangeDeleted},
+				{Path: "link", Unstaged: ChangeTypeChange},
+				{Path: "notes/to do.txt", Unstaged: ChangeUntracked},
+			}},
+		},
+		{
+			name: "paths with spaces",
+			entries: []string{
+				"1 M. N... 100644 100644 100644 " + oid + " " + oid2 + " my notes/read me.md",
+			},
+			want: Status{Initialized: true, Files: []FileStatus{
+				{Path: "my notes/read me.md", Staged: ChangeModified},
+			}},
+		},
+		{
+			name: "renamed and copied",
+			entries: []string{
+				"2 R. N... 100644 100644 100644 " + oid + " " + oid + " R100 lib/util.go",
+				"util.go",
+				"2 CM N... 100644 100644 100644 " + oid + " " + oid + " C75 copy of main.go",
+				"main.go",
+			},
+			want: Status{Initialized: true, Files: []FileStatus{
+				{Path: "lib/util.go", OrigPath: "util.go", Staged: ChangeRenamed},
+				{Path: "copy of main.go", OrigPath: "main.go", Staged: ChangeCopied, Unstaged: ChangeModified},
+			}},
+		},
+		{
+			name: "conflicts",
+			entries: []string{
+				"# branch.head feature",
+				"u UU N... 100644 100644 100644 100644 " + oid + " " + oid2 + " " + oid + " main.go",
+			},
+			want: Status{Initialized: true, Branch: "feature", Files: []FileStatus{
+				{Path: "main.go", Staged: ChangeConflicted, Unstaged: ChangeConflicted},
+			}},
+		},
+		{
+			name: "malformed entries are skipped",
+			entries: []string{
+				"1 .M N... main.go",
+				"2 R. N... 100644 100644 100644 " + oid + " " + oid + " R100",
+				"u UU N... main.go",
+				"!",
+				"",
+			},
+			want: Status{Initialized: true, Files: []FileStatus{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Entries are NUL terminated, including the last one
+			out := strings.Join(tt.entries, "\x00") + "\x00"
+			if got := parseStatus(out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBranchHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Status
+	}{
+		{header: "# branch.head main", want: Status{Branch: "main"}},
+		{header: "# branch.head feature/login", want: Status{Branch: "feature/login"}},
+		{header: "# branch.head (detached)", want: Status{Detached: true}},
+		{header: "# branch.upstream origin/main", want: Status{Upstream: "origin/main"}},
+		{header: "# branch.ab +3 -1", want: Status{Ahead: 3, Behind: 1}},
+		{header: "# branch.ab +0 -0", want: Status{}},
+		{header: "# branch.ab +2", want: Status{}},
+		{header: "# branch.oid " + oid, want: Status{}},
+		{header: "# branch.head", want: Status{}},
+		{header: "# stash 2", want: Status{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			var got Status
+			parseBranchHeader(&got, tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
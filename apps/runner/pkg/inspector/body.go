@@ -0,0 +1,71 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"unicode/utf8"
+)
+
+// Encodings of a captured body
+const (
+	EncodingText   = "text"
+	EncodingBase64 = "base64"
+)
+
+// Body is a captured request or response body, cut at maxBodySize
+type Body struct {
+	// Data is the body as text, or base64 when it is not valid UTF-8
+	Data      string `json:"data"`
+	Encoding  string `json:"encoding"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+func newBody(data []byte, size int64) *Body {
+	body := &Body{Size: size, Truncated: size > int64(len(data))}
+	if utf8.Valid(data) {
+		body.Data, body.Encoding = string(data), EncodingText
+	} else {
+		body.Data, body.Encoding = base64.StdEncoding.EncodeToString(data), EncodingBase64
+	}
+	return body
+}
+
+// Bytes decodes the captured data
+func (b *Body) Bytes() ([]byte, error) {
+	if b.Encoding == EncodingBase64 {
+		return base64.StdEncoding.DecodeString(b.Data)
+	}
+	return []byte(b.Data), nil
+}
+
+// capture keeps the first maxBodySize bytes written to it and counts the rest
+type capture struct {
+	buffer bytes.Buffer
+	size   int64
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	if room := maxBodySize - c.buffer.Len(); room > 0 {
+		c.buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (c *capture) body() *Body {
+	return newBody(c.buffer.Bytes(), c.size)
+}
+
+// teeBody captures a request body as the proxy reads it
+type teeBody struct {
+	io.ReadCloser
+	capture *capture
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.capture.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,267 @@
+// Package inspector captures the requests made to the user's app through the
+// runner's proxy, like a tunnel inspector, to debug webhooks and APIs, and
+// replays them.
+package inspector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxExchanges is how many requests are kept, the oldest go first
+	maxExchanges = 200
+	// maxBodySize bounds each captured body
+	maxBodySize = 64 << 10
+	// replayTimeout bounds a replayed request
+	replayTimeout = 30 * time.Second
+)
+
+var (
+	ErrNotFound  = errors.New("request not found")
+	ErrTruncated = errors.New("request body was truncated, it can not be replayed")
+)
+
+// Request as the app received it, Url is its path and query
+type Request struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Host    string      `json:"host"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    *Body       `json:"body,omitempty"`
+}
+
+// Response of the app, Status is 0 when it could not be reached
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    *Body       `json:"body,omitempty"`
+}
+
+// Exchange is a captured request and the response of the app
+type Exchange struct {
+	// Id numbers the exchanges since the runner started
+	Id         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Port       int       `json:"port"`
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+	DurationMs int64     `json:"durationMs"`
+	// ReplayOf is the id of the exchange this one replayed
+	ReplayOf int64  `json:"replayOf,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Summary is the exchange without headers and bodies, to list them
+func (e Exchange) Summary() Exchange {
+	e.Request.Headers, e.Request.Body = nil, nil
+	e.Response.Headers, e.Response.Body = nil, nil
+	return e
+}
+
+// Inspector keeps the last maxExchanges exchanges while it is enabled
+type Inspector struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	enabled   bool
+	exchanges []Exchange
+	lastId    int64
+
+	subscribers map[int]func(Exchange)
+	nextId      int
+}
+
+func New(enabled bool) *Inspector {
+	return &Inspector{
+		// Redirects are the app's answer, not followed
+		client: &http.Client{
+			Timeout: replayTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		enabled:     enabled,
+		subscribers: make(map[int]func(Exchange)),
+	}
+}
+
+// Subscribe calls fn with every captured exchange until unsubscribe is
+// called. fn must not block.
+func (i *Inspector) Subscribe(fn func(Exchange)) (unsubscribe func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	id := i.nextId
+	i.nextId++
+	i.subscribers[id] = fn
+
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.subscribers, id)
+	}
+}
+
+func (i *Inspector) Enabled() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.enabled
+}
+
+// SetEnabled starts or stops capturing, captured exchanges are kept
+func (i *Inspector) SetEnabled(enabled bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.enabled = enabled
+}
+
+// add numbers and keeps an exchange
+func (i *Inspector) add(e Exchange) Exchange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.lastId++
+	e.Id = i.lastId
+	i.exchanges = append(i.exchanges, e)
+	if len(i.exchanges) > maxExchanges {
+		i.exchanges = i.exchanges[len(i.exchanges)-maxExchanges:]
+	}
+	for _, fn := range i.subscribers {
+		fn(e)
+	}
+	return e
+}
+
+// List returns the summaries of the exchanges after id, oldest first
+func (i *Inspector) List(since int64) []Exchange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	exchanges := []Exchange{}
+	for _, e := range i.exchanges {
+		if e.Id > since {
+			exchanges = append(exchanges, e.Summary())
+		}
+	}
+	return exchanges
+}
+
+// Get returns an exchange with its headers and bodies
+func (i *Inspector) Get(id int64) (Exchange, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, e := range i.exchanges {
+		if e.Id == id {
+			return e, nil
+		}
+	}
+	return Exchange{}, ErrNotFound
+}
+
+// Clear drops the captured exchanges
+func (i *Inspector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.exchanges = nil
+}
+
+// Serve runs next, the proxy to the app on port, capturing the exchange when
+// the inspector is enabled. WebSockets are not captured.
+func (i *Inspector) Serve(w http.ResponseWriter, r *http.Request, port int, next http.Handler) {
+	if !i.Enabled() || strings.EqualFold(r.Header.Get("Connection"), "upgrade") || r.Header.Get("Upgrade") != "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	exchange := Exchange{
+		Time: time.Now(),
+		Port: port,
+		Request: Request{
+			Method:  r.Method,
+			Url:     r.URL.RequestURI(),
+			Host:    r.Host,
+			Headers: r.Header.Clone(),
+		},
+	}
+
+	var requestBody capture
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &teeBody{ReadCloser: r.Body, capture: &requestBody}
+	}
+	recorder := &responseRecorder{ResponseWriter: w}
+
+	next.ServeHTTP(recorder, r)
+
+	exchange.DurationMs = time.Since(exchange.Time).Milliseconds()
+	exchange.Request.Body = requestBody.body()
+	exchange.Response = Response{
+		Status:  recorder.status,
+		Headers: w.Header().Clone(),
+		Body:    recorder.capture.body(),
+	}
+	i.add(exchange)
+}
+
+// Replay sends a captured request to the app again and captures it as a new
+// exchange
+func (i *Inspector) Replay(ctx context.Context, id int64) (Exchange, error) {
+	original, err := i.Get(id)
+	if err != nil {
+		return Exchange{}, err
+	}
+	var body []byte
+	if original.Request.Body != nil {
+		if original.Request.Body.Truncated {
+			return Exchange{}, ErrTruncated
+		}
+		if body, err = original.Request.Body.Bytes(); err != nil {
+			return Exchange{}, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+	url := fmt.Sprintf("http://localhost:%d%s", original.Port, original.Request.Url)
+	req, err := http.NewRequestWithContext(ctx, original.Request.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return Exchange{}, err
+	}
+	req.Header = original.Request.Headers.Clone()
+	req.Header.Del("Content-Length")
+
+	exchange := Exchange{
+		Time:     time.Now(),
+		Port:     original.Port,
+		Request:  original.Request,
+		ReplayOf: original.Id,
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		exchange.DurationMs = time.Since(exchange.Time).Milliseconds()
+		exchange.Error = err.Error()
+		return i.add(exchange), nil
+	}
+	defer resp.Body.Close()
+
+	var responseBody capture
+	_, err = io.Copy(&responseBody, resp.Body)
+	exchange.DurationMs = time.Since(exchange.Time).Milliseconds()
+	exchange.Response = Response{
+		Status:  resp.StatusCode,
+		Headers: resp.Header.Clone(),
+		Body:    responseBody.body(),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	return i.add(exchange), nil
+}
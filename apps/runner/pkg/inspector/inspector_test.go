@@ -0,0 +1,133 @@
+package inspector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newApp serves an app echoing the requests it gets, behind an inspector
+func newApp(t *testing.T, i *Inspector) (*httptest.Server, int) {
+	t.Helper()
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	t.Cleanup(app.Close)
+	u, _ := url.Parse(app.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	// Stands in for the proxy
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i.Serve(w, r, port, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, _ := http.NewRequestWithContext(r.Context(), r.Method, app.URL+r.URL.RequestURI(), r.Body)
+			req.Header = r.Header
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			for key, values := range resp.Header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+		}))
+	}))
+	t.Cleanup(front.Close)
+	return front, port
+}
+
+func post(t *testing.T, url, body string) {
+	t.Helper()
+	resp, err := http.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestCapture(t *testing.T) {
+	i := New(true)
+	front, port := newApp(t, i)
+
+	var pushed []Exchange
+	i.Subscribe(func(e Exchange) { pushed = append(pushed, e) })
+
+	post(t, front.URL+"/webhook?source=github", `{"action":"opened"}`)
+	post(t, front.URL+"/upload", strings.Repeat("x", maxBodySize+10))
+
+	list := i.List(0)
+	if len(list) != 2 || len(pushed) != 2 {
+		t.Fatalf("got %d listed and %d pushed, want 2", len(list), len(pushed))
+	}
+	if list[0].Request.Body != nil || list[0].Response.Headers != nil {
+		t.Errorf("got %+v, want a summary", list[0])
+	}
+
+	e, err := i.Get(list[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Port != port || e.Request.Method != http.MethodPost || e.Request.Url != "/webhook?source=github" ||
+		e.Request.Body.Data != `{"action":"opened"}` || e.Response.Status != http.StatusCreated ||
+		e.Response.Body.Data != `{"action":"opened"}` || e.Response.Headers.Get("X-Method") != http.MethodPost {
+		t.Errorf("got %+v", e)
+	}
+
+	large, _ := i.Get(list[1].Id)
+	if body := large.Request.Body; !body.Truncated || body.Size != maxBodySize+10 || len(body.Data) != maxBodySize {
+		t.Errorf("got size %d, truncated %v, %d bytes kept; want a truncated body", body.Size, body.Truncated, len(body.Data))
+	}
+
+	if got := i.List(list[0].Id); len(got) != 1 || got[0].Id != list[1].Id {
+		t.Errorf("got %+v after %d, want the last request", got, list[0].Id)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	i := New(false)
+	front, _ := newApp(t, i)
+
+	post(t, front.URL+"/", "ignored")
+	if got := i.List(0); len(got) != 0 {
+		t.Errorf("got %+v, want nothing captured", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	i := New(true)
+	front, _ := newApp(t, i)
+
+	post(t, front.URL+"/webhook", "payload")
+	post(t, front.URL+"/upload", strings.Repeat("x", maxBodySize+10))
+	list := i.List(0)
+
+	replayed, err := i.Replay(context.Background(), list[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ReplayOf != list[0].Id || replayed.Response.Status != http.StatusCreated || replayed.Response.Body.Data != "payload" {
+		t.Errorf("got %+v, want the replay of %d", replayed, list[0].Id)
+	}
+	if got := i.List(list[1].Id); len(got) != 1 || got[0].Id != replayed.Id {
+		t.Errorf("got %+v, want the replay captured", got)
+	}
+
+	if _, err := i.Replay(context.Background(), list[1].Id); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, want ErrTruncated", err)
+	}
+	if _, err := i.Replay(context.Background(), 1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
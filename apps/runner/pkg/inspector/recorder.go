@@ -0,0 +1,33 @@
+package inspector
+
+import "net/http"
+
+// responseRecorder captures the status and body of a response as it is
+// written to the client
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	capture capture
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	// Informational responses (103 Early Hints) come before the final one
+	if r.status == 0 && status >= http.StatusOK {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.capture.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses (e.g.
+// server-sent events) through the recorder
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
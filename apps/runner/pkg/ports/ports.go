@@ -0,0 +1,216 @@
+// Package ports notices the TCP ports the user's processes listen on, e.g. a
+// dev server started from a terminal, so that editors can show a preview of
+// them right away.
+package ports
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MinPort is the lowest port reported, the proxy does not serve the
+// privileged ones
+const MinPort = 1024
+
+// Kinds of event
+const (
+	EventOpened  = "opened"
+	EventClosed  = "closed"
+	EventUpdated = "updated"
+)
+
+var ErrNotOpen = errors.New("no process listens on this port")
+
+// Port is a port a process started by the runner listens on
+type Port struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`
+	Pid     int    `json:"pid"`
+	Process string `json:"process"`
+	// Url previews the port through the runner's proxy
+	Url      string    `json:"url"`
+	OpenedAt time.Time `json:"openedAt"`
+	Settings
+}
+
+// Settings of a port set by the user, kept when the port closes so that a
+// restarted server gets them back
+type Settings struct {
+	Label string `json:"label,omitempty"`
+	// Public serves the port to anyone, the others take the access token of
+	// the repl
+	Public bool `json:"public"`
+}
+
+// Event is sent to subscribers when a port opens, closes or is updated
+type Event struct {
+	Type string `json:"type"`
+	Port Port   `json:"port"`
+}
+
+// Monitor polls the listening sockets of the processes started by the runner
+// (terminals, commands, the app), the runner's own ports are not reported
+type Monitor struct {
+	procDir string
+	self    int
+	// previewURL is where a port is reachable for users
+	previewURL func(port int) string
+
+	mu          sync.Mutex
+	open        map[int]Port
+	settings    map[int]Settings
+	subscribers map[int]func(Event)
+	nextId      int
+}
+
+func NewMonitor(previewURL func(port int) string) *Monitor {
+	return &Monitor{
+		procDir:     "/proc",
+		self:        os.Getpid(),
+		previewURL:  previewURL,
+		open:        make(map[int]Port),
+		settings:    make(map[int]Settings),
+		subscribers: make(map[int]func(Event)),
+	}
+}
+
+// Subscribe calls fn with every event until unsubscribe is called. fn must
+// not block.
+func (m *Monitor) Subscribe(fn func(Event)) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextId
+	m.nextId++
+	m.subscribers[id] = fn
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// notify sends event, m.mu must be held
+func (m *Monitor) notify(event Event) {
+	for _, fn := range m.subscribers {
+		fn(event)
+	}
+}
+
+// Run polls the ports every interval until ctx is done
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Scan(); err != nil {
+			log.Printf("Failed to scan listening ports: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan looks for ports opened or closed since the last scan
+func (m *Monitor) Scan() error {
+	sockets, err := readSockets(m.procDir)
+	if err != nil {
+		return err
+	}
+	processes, err := descendants(m.procDir, m.self)
+	if err != nil {
+		return err
+	}
+
+	// Only the processes with a listening socket are worth looking into
+	listening := make(map[string]socket)
+	for _, s := range sockets {
+		if s.Port >= MinPort {
+			listening[s.Inode] = s
+		}
+	}
+	found := make(map[int]Port)
+	if len(listening) > 0 {
+		for pid, p := range processes {
+			for _, inode := range socketInodes(m.procDir, pid) {
+				s, ok := listening[inode]
+				if !ok {
+					continue
+				}
+				// Servers often listen on IPv4 and IPv6, or in several
+				// workers, the first one found is reported
+				if _, seen := found[s.Port]; !seen {
+					found[s.Port] = Port{Port: s.Port, Address: s.Address, Pid: pid, Process: p.Name}
+				}
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for port, p := range m.open {
+		if _, ok := found[port]; !ok {
+			delete(m.open, port)
+			m.notify(Event{Type: EventClosed, Port: p})
+		}
+	}
+	for port, p := range found {
+		if _, ok := m.open[port]; ok {
+			continue
+		}
+		p.Url = m.previewURL(port)
+		p.OpenedAt = time.Now()
+		p.Settings = m.settings[port]
+		m.open[port] = p
+		m.notify(Event{Type: EventOpened, Port: p})
+	}
+	return nil
+}
+
+// List returns the open ports, by number
+func (m *Monitor) List() []Port {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ports := make([]Port, 0, len(m.open))
+	for _, p := range m.open {
+		ports = append(ports, p)
+	}
+	slices.SortFunc(ports, func(a, b Port) int { return a.Port - b.Port })
+	return ports
+}
+
+// Get returns an open port
+func (m *Monitor) Get(port int) (Port, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.open[port]
+	return p, ok
+}
+
+// Update changes the settings of an open port
+func (m *Monitor) Update(port int, settings Settings) (Port, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.open[port]
+	if !ok {
+		return Port{}, ErrNotOpen
+	}
+	p.Settings = settings
+	m.open[port] = p
+	m.settings[port] = settings
+	m.notify(Event{Type: EventUpdated, Port: p})
+	return p, nil
+}
@@ -0,0 +1,173 @@
+package ports
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// socket is a listening socket read from /proc/net/tcp or /proc/net/tcp6
+type socket struct {
+	Address string
+	Port    int
+	Inode   string
+}
+
+// parseSockets reads the listening sockets of a /proc/net/tcp{,6} table
+func parseSockets(r io.Reader) ([]socket, error) {
+	var sockets []socket
+	scanner := bufio.NewScanner(r)
+	// Header: sl local_address rem_address st ... inode
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		host, port, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid local address %q", fields[1])
+		}
+		portNumber, err := strconv.ParseUint(port, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", port, err)
+		}
+		address, err := parseAddress(host)
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, socket{Address: address, Port: int(portNumber), Inode: fields[9]})
+	}
+	return sockets, scanner.Err()
+}
+
+// parseAddress decodes an IPv4 or IPv6 address of /proc/net/tcp{,6}, stored
+// as 32-bit words in host (little-endian) order
+func parseAddress(host string) (string, error) {
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", fmt.Errorf("invalid address %q", host)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String(), nil
+}
+
+// readSockets lists the listening TCP sockets of procDir, IPv4 and IPv6
+func readSockets(procDir string) ([]socket, error) {
+	var sockets []socket
+	for _, table := range []string{"net/tcp", "net/tcp6"} {
+		file, err := os.Open(filepath.Join(procDir, table))
+		if os.IsNotExist(err) {
+			// No IPv6 in the kernel
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseSockets(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", table, err)
+		}
+		sockets = append(sockets, parsed...)
+	}
+	return sockets, nil
+}
+
+// process is a process owning sockets
+type process struct {
+	Pid  int
+	Name string
+}
+
+// descendants returns the processes started by root, at any depth
+func descendants(procDir string, root int) (map[int]process, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]process)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// The process may be gone already
+		stat, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		name, ppid, ok := parseStat(string(stat))
+		if !ok {
+			continue
+		}
+		children[ppid] = append(children[ppid], process{Pid: pid, Name: name})
+	}
+
+	found := make(map[int]process)
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if _, seen := found[child.Pid]; !seen {
+				found[child.Pid] = child
+				queue = append(queue, child.Pid)
+			}
+		}
+	}
+	return found, nil
+}
+
+// parseStat returns the name and parent of a /proc/<pid>/stat, the name is
+// in parentheses and may hold spaces and parentheses itself
+func parseStat(stat string) (name string, ppid int, ok bool) {
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return "", 0, false
+	}
+	// After the name: state ppid ...
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return "", 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return stat[open+1 : end], ppid, true
+}
+
+// socketInodes returns the inodes of the sockets a process has open
+func socketInodes(procDir string, pid int) []string {
+	fdDir := filepath.Join(procDir, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []string
+	for _, entry := range entries {
+		link, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+			inodes = append(inodes, strings.TrimSuffix(inode, "]"))
+		}
+	}
+	return inodes
+}
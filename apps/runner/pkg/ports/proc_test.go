@@ -0,0 +1,71 @@
+package ports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSockets(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  []socket
+	}{
+		{
+			name: "tcp",
+			table: `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1388 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31337 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F91 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31338 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:1388 0100007F:D2F0 01 00000000:00000000 00:00000000 00000000     0        0 31339 1 0000000000000000 20 4 30 10 -1
+`,
+			want: []socket{
+				{Address: "0.0.0.0", Port: 5000, Inode: "31337"},
+				{Address: "127.0.0.1", Port: 8081, Inode: "31338"},
+			},
+		},
+		{
+			name: "tcp6",
+			table: `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0BB8 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4242 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:1389 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4243 1 0000000000000000 100 0 0 10 0
+`,
+			want: []socket{
+				{Address: "::", Port: 3000, Inode: "4242"},
+				{Address: "::1", Port: 5001, Inode: "4243"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSockets(strings.NewReader(tt.table))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		stat     string
+		wantName string
+		wantPpid int
+		wantOk   bool
+	}{
+		{stat: "4242 (node) S 4200 4242 4200 0 -1", wantName: "node", wantPpid: 4200, wantOk: true},
+		{stat: "4243 (vite (dev) x) R 1 4243 4243 0 -1", wantName: "vite (dev) x", wantPpid: 1, wantOk: true},
+		{stat: "4244 node S", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		name, ppid, ok := parseStat(tt.stat)
+		if name != tt.wantName || ppid != tt.wantPpid || ok != tt.wantOk {
+			t.Errorf("parseStat(%q) = %q, %d, %v; want %q, %d, %v", tt.stat, name, ppid, ok, tt.wantName, tt.wantPpid, tt.wantOk)
+		}
+	}
+}
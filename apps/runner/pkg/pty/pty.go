@@ -13,6 +13,8 @@ import (
 	"time"
 	"unsafe"
 
+	"runner/pkg/fs"
+
 	"github.com/creack/pty"
 )
 
@@ -20,6 +22,11 @@ import (
 type PTYManager struct {
 	sessions map[string]*PTYSession
 	mutex    sync.RWMutex
+	// gracePeriod is how long a session with no client attached keeps
+	// running, so that a client can reattach after a dropped connection
+	gracePeriod time.Duration
+	// scrollbackSize is how many bytes of output a session keeps to replay
+	scrollbackSize int
 }
 
 // PTYSession represents a single PTY session
@@ -33,6 +40,30 @@ type PTYSession struct {
 	onClose   func()       // Callback when session closes
 	closeOnce sync.Once
 	isClosed  atomic.Bool
+	CreatedAt time.Time
+
+	// outputMu guards the output kept and the attached clients, so that a
+	// client attaching gets the scrollback and then every byte after it
+	outputMu    sync.Mutex
+	scrollback  *scrollback
+	listeners   map[string]Listener
+	gracePeriod time.Duration
+	graceTimer  *time.Timer
+}
+
+// Listener gets the output of a session a client is attached to
+type Listener struct {
+	OnData  func([]byte)
+	OnClose func()
+}
+
+// SessionInfo describes a session for clients choosing one to attach to
+type SessionInfo struct {
+	SessionId string    `json:"sessionId"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Attached is the number of clients attached
+	Attached int  `json:"attached"`
+	Active   bool `json:"active"`
 }
 
 // PTYConfig holds configuration for PTY creation
@@ -44,10 +75,14 @@ type PTYConfig struct {
 	Rows        int               // Initial terminal rows
 }
 
-// NewPTYManager creates a new PTY manager
-func NewPTYManager() *PTYManager {
+// NewPTYManager creates a new PTY manager. Sessions left without a client
+// are closed after gracePeriod, right away when it is 0, and keep the last
+// scrollbackSize bytes of their output.
+func NewPTYManager(gracePeriod time.Duration, scrollbackSize int) *PTYManager {
 	return &PTYManager{
-		sessions: make(map[string]*PTYSession),
+		sessions:       make(map[string]*PTYSession),
+		gracePeriod:    gracePeriod,
+		scrollbackSize: scrollbackSize,
 	}
 }
 
@@ -82,7 +117,7 @@ func (pm *PTYManager) CreateSession(sessionID string, config *PTYConfig) (*PTYSe
 	if config.WorkingDir != "" {
 		cmd.Dir = config.WorkingDir
 	} else {
-		cmd.Dir = "/workspaces"
+		cmd.Dir = fs.WORKSPACE_DIR
 	}
 
 	// Set environment
@@ -107,10 +142,14 @@ func (pm *PTYManager) CreateSession(sessionID string, config *PTYConfig) (*PTYSe
 	}
 
 	session := &PTYSession{
-		ID:   sessionID,
-		PTY:  ptyFile,
-		CMD:  cmd,
-		done: make(chan struct{}),
+		ID:          sessionID,
+		PTY:         ptyFile,
+		CMD:         cmd,
+		done:        make(chan struct{}),
+		CreatedAt:   time.Now(),
+		scrollback:  newScrollback(pm.scrollbackSize),
+		listeners:   make(map[string]Listener),
+		gracePeriod: pm.gracePeriod,
 	}
 	session.isClosed.Store(false)
 
@@ -152,6 +191,32 @@ func (pm *PTYManager) ListSessions() []string {
 	return sessions
 }
 
+// List describes every session
+func (pm *PTYManager) List() []SessionInfo {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(pm.sessions))
+	for _, session := range pm.sessions {
+		infos = append(infos, session.Info())
+	}
+	return infos
+}
+
+// DetachAll detaches clientId from every session, when it disconnects
+func (pm *PTYManager) DetachAll(clientId string) {
+	pm.mutex.RLock()
+	sessions := make([]*PTYSession, 0, len(pm.sessions))
+	for _, session := range pm.sessions {
+		sessions = append(sessions, session)
+	}
+	pm.mutex.RUnlock()
+
+	for _, session := range sessions {
+		session.Detach(clientId)
+	}
+}
+
 // GetSessionStatus returns status information for a session
 func (pm *PTYManager) GetSessionStatus(sessionID string) (map[string]any, error) {
 	session, exists := pm.GetSession(sessionID)
@@ -215,6 +280,13 @@ func (s *PTYSession) readFromPTY() {
 					s.onData(data)
 				}
 				s.mutex.RUnlock()
+
+				s.outputMu.Lock()
+				s.scrollback.Write(data)
+				for _, listener := range s.listeners {
+					listener.OnData(data)
+				}
+				s.outputMu.Unlock()
 			}
 		}
 	}
@@ -279,6 +351,62 @@ func getSize(pty *os.File, size *pty.Winsize) error {
 	return nil
 }
 
+// Attach sends the output of the session to clientId from now on, and
+// returns the scrollback to replay before it. Listener callbacks must not
+// block.
+func (s *PTYSession) Attach(clientId string, listener Listener) ([]byte, error) {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+
+	if s.isClosed.Load() {
+		return nil, fmt.Errorf("PTY is closed")
+	}
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+	s.listeners[clientId] = listener
+	return s.scrollback.Bytes(), nil
+}
+
+// Detach stops sending output to clientId. Once no client is attached the
+// session is closed after its grace period, unless one attaches again.
+func (s *PTYSession) Detach(clientId string) {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+
+	if _, ok := s.listeners[clientId]; !ok {
+		return
+	}
+	delete(s.listeners, clientId)
+	if len(s.listeners) > 0 || s.isClosed.Load() {
+		return
+	}
+
+	if s.gracePeriod <= 0 {
+		go s.Close()
+		return
+	}
+	log.Printf("No client attached to session %s, closing it in %s", s.ID, s.gracePeriod)
+	s.graceTimer = time.AfterFunc(s.gracePeriod, func() {
+		log.Printf("Closing detached session %s", s.ID)
+		s.Close()
+	})
+}
+
+// Info describes the session
+func (s *PTYSession) Info() SessionInfo {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+
+	return SessionInfo{
+		SessionId: s.ID,
+		CreatedAt: s.CreatedAt,
+		Attached:  len(s.listeners),
+		Active:    !s.isClosed.Load(),
+	}
+}
+
 // SetOnDataCallback sets the callback function for output data
 func (s *PTYSession) SetOnDataCallback(callback func([]byte)) {
 	s.mutex.Lock()
@@ -365,6 +493,18 @@ func (s *PTYSession) cleanup() {
 		go s.onClose()
 	}
 
+	s.outputMu.Lock()
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+	}
+	for _, listener := range s.listeners {
+		if listener.OnClose != nil {
+			go listener.OnClose()
+		}
+	}
+	s.listeners = make(map[string]Listener)
+	s.outputMu.Unlock()
+
 	if s.PTY != nil {
 		s.PTY.Close()
 		s.PTY = nil
@@ -0,0 +1,43 @@
+package pty
+
+// scrollback keeps the last size bytes written to it, the output replayed
+// to a client attaching to a running session
+type scrollback struct {
+	buf  []byte
+	size int
+	// start is where the oldest byte is once buf is full
+	start int
+}
+
+func newScrollback(size int) *scrollback {
+	return &scrollback{buf: make([]byte, 0, size), size: size}
+}
+
+func (s *scrollback) Write(p []byte) {
+	if s.size <= 0 {
+		return
+	}
+	if len(p) >= s.size {
+		s.buf = append(s.buf[:0], p[len(p)-s.size:]...)
+		s.start = 0
+		return
+	}
+
+	// Fill up, then overwrite the oldest bytes
+	if n := min(s.size-len(s.buf), len(p)); n > 0 {
+		s.buf = append(s.buf, p[:n]...)
+		p = p[n:]
+	}
+	for len(p) > 0 {
+		n := copy(s.buf[s.start:], p)
+		p = p[n:]
+		s.start = (s.start + n) % s.size
+	}
+}
+
+// Bytes returns a copy of the kept output, oldest first
+func (s *scrollback) Bytes() []byte {
+	out := make([]byte, 0, len(s.buf))
+	out = append(out, s.buf[s.start:]...)
+	return append(out, s.buf[:s.start]...)
+}
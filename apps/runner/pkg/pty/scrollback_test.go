@@ -0,0 +1,30 @@
+package pty
+
+import "testing"
+
+func TestScrollback(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes []string
+		want   string
+	}{
+		{name: "not full", size: 8, writes: []string{"abc", "de"}, want: "abcde"},
+		{name: "wraps", size: 5, writes: []string{"abc", "def", "g"}, want: "cdefg"},
+		{name: "write larger than size", size: 4, writes: []string{"ab", "cdefgh"}, want: "efgh"},
+		{name: "wraps several times", size: 3, writes: []string{"ab", "cd", "ef", "g"}, want: "efg"},
+		{name: "disabled", size: 0, writes: []string{"abc"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScrollback(tt.size)
+			for _, w := range tt.writes {
+				s.Write([]byte(w))
+			}
+			if got := string(s.Bytes()); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,8 +19,13 @@ type ShutdownManager struct {
 	isShutdown       bool
 	ctx              context.Context
 	cancel           context.CancelFunc
-	connectionActive bool
+	// connections is the number of open WebSocket connections, the timer
+	// only runs while it is 0
+	connections      int
 	inactivityPeriod time.Duration
+	// generation numbers the armed timers, a timer that fires after it was
+	// replaced or stopped does nothing
+	generation int
 }
 
 // NewShutdownManager creates a new shutdown manager instance
@@ -33,7 +38,6 @@ func NewShutdownManager(replId string, callback ShutdownCallback) *ShutdownManag
 		ctx:              ctx,
 		cancel:           cancel,
 		inactivityPeriod: 4 * time.Minute,
-		connectionActive: false,
 	}
 
 	// Start the initial shutdown timer
@@ -47,24 +51,38 @@ func NewShutdownManager(replId string, callback ShutdownCallback) *ShutdownManag
 func (sm *ShutdownManager) startShutdownTimer() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.startShutdownTimerLocked()
+}
 
+// startShutdownTimerLocked starts or restarts the shutdown timer, sm.mu must
+// be held
+func (sm *ShutdownManager) startShutdownTimerLocked() {
 	if sm.isShutdown {
 		return
 	}
 
 	// Stop existing timer if any
-	if sm.timer != nil {
-		sm.timer.Stop()
-	}
+	sm.stopShutdownTimerLocked()
 
+	generation := sm.generation
 	sm.timer = time.AfterFunc(sm.inactivityPeriod, func() {
-		sm.executeShutdown()
+		sm.executeShutdown(generation)
 	})
 
 	log.Printf("Shutdown timer started/restarted for repl: %s (%.0f minutes)",
 		sm.replId, sm.inactivityPeriod.Minutes())
 }
 
+// stopShutdownTimerLocked stops the shutdown timer, even one that already
+// fired and waits for sm.mu, sm.mu must be held
+func (sm *ShutdownManager) stopShutdownTimerLocked() {
+	sm.generation++
+	if sm.timer != nil {
+		sm.timer.Stop()
+		sm.timer = nil
+	}
+}
+
 // OnConnectionEstablished should be called when a WebSocket connection is established
 func (sm *ShutdownManager) OnConnectionEstablished() {
 	sm.mu.Lock()
@@ -74,15 +92,12 @@ func (sm *ShutdownManager) OnConnectionEstablished() {
 		return
 	}
 
-	sm.connectionActive = true
+	sm.connections++
 
 	// Stop the shutdown timer since we have an active connection
-	if sm.timer != nil {
-		sm.timer.Stop()
-		sm.timer = nil
-	}
+	sm.stopShutdownTimerLocked()
 
-	log.Printf("Connection established for repl: %s - shutdown timer stopped", sm.replId)
+	log.Printf("Connection established for repl: %s (%d open) - shutdown timer stopped", sm.replId, sm.connections)
 }
 
 // OnConnectionClosed should be called when a WebSocket connection is closed
@@ -94,20 +109,27 @@ func (sm *ShutdownManager) OnConnectionClosed() {
 		return
 	}
 
-	sm.connectionActive = false
+	if sm.connections > 0 {
+		sm.connections--
+	}
+	if sm.connections > 0 {
+		log.Printf("Connection closed for repl: %s (%d still open)", sm.replId, sm.connections)
+		return
+	}
 
-	// Restart the shutdown timer since connection is closed
-	go sm.startShutdownTimer()
+	// Restart the shutdown timer since the last connection is closed
+	sm.startShutdownTimerLocked()
 
 	log.Printf("Connection closed for repl: %s - shutdown timer restarted", sm.replId)
 }
 
-// executeShutdown performs the actual shutdown
-func (sm *ShutdownManager) executeShutdown() {
+// executeShutdown performs the actual shutdown, unless the timer of
+// generation was replaced or a client connected in the meantime
+func (sm *ShutdownManager) executeShutdown(generation int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.isShutdown {
+	if sm.isShutdown || generation != sm.generation || sm.connections > 0 {
 		return
 	}
 
@@ -141,7 +163,14 @@ func (sm *ShutdownManager) IsShutdown() bool {
 func (sm *ShutdownManager) HasActiveConnection() bool {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
-	return sm.connectionActive
+	return sm.connections > 0
+}
+
+// ActiveConnections returns the number of open connections
+func (sm *ShutdownManager) ActiveConnections() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.connections
 }
 
 // Context returns the manager's context (cancelled on shutdown)
@@ -158,9 +187,7 @@ func (sm *ShutdownManager) Close() {
 		return
 	}
 
-	if sm.timer != nil {
-		sm.timer.Stop()
-	}
+	sm.stopShutdownTimerLocked()
 
 	sm.isShutdown = true
 	sm.cancel()
@@ -176,7 +203,7 @@ func (sm *ShutdownManager) SetInactivityPeriod(duration time.Duration) {
 	sm.inactivityPeriod = duration
 
 	// If there's no active connection, restart timer with new duration
-	if !sm.connectionActive && !sm.isShutdown {
-		sm.startShutdownTimer()
+	if sm.connections == 0 && !sm.isShutdown {
+		sm.startShutdownTimerLocked()
 	}
 }
@@ -0,0 +1,66 @@
+package shutdown
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const period = 50 * time.Millisecond
+
+func newManager(t *testing.T) (*ShutdownManager, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	sm := NewShutdownManager("repl", func(string) error {
+		calls.Add(1)
+		return nil
+	})
+	t.Cleanup(sm.Close)
+
+	done := make(chan struct{})
+	go func() {
+		sm.SetInactivityPeriod(period)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SetInactivityPeriod deadlocked")
+	}
+	return sm, &calls
+}
+
+func TestShutdownAfterInactivity(t *testing.T) {
+	sm, _ := newManager(t)
+
+	sm.OnConnectionEstablished()
+	sm.OnConnectionClosed()
+
+	select {
+	case <-sm.Context().Done():
+	case <-time.After(10 * period):
+		t.Fatal("no shutdown after the inactivity period")
+	}
+}
+
+func TestNoShutdownWhileConnected(t *testing.T) {
+	sm, calls := newManager(t)
+
+	// A tab closes while another one reconnects
+	sm.OnConnectionEstablished()
+	sm.OnConnectionClosed()
+	sm.OnConnectionEstablished()
+
+	time.Sleep(3 * period)
+	if sm.IsShutdown() || calls.Load() != 0 {
+		t.Fatal("shut down with a connection open")
+	}
+
+	// Tabs closing one after the other
+	sm.OnConnectionEstablished()
+	sm.OnConnectionClosed()
+	time.Sleep(3 * period)
+	if sm.IsShutdown() {
+		t.Fatal("shut down with a connection open")
+	}
+}
@@ -0,0 +1,75 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"runner/pkg/fs"
+)
+
+// ConfigPath is where a workspace configures how its app runs, templates
+// ship one that users can edit
+const ConfigPath = ".devex/run.json"
+
+// Restart policies
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+var ErrNoConfig = errors.New("no run configuration for this repl")
+
+// Config is how the user's app is run
+type Config struct {
+	// Command is a command line run with /bin/bash -c
+	Command string `json:"command"`
+	// Cwd is relative to the workspace root
+	Cwd string            `json:"cwd,omitempty"`
+	Env map[string]string `json:"env,omitempty"`
+	// Restart is RestartNever (the default), RestartOnFailure or
+	// RestartAlways
+	Restart string `json:"restart,omitempty"`
+	// MaxRestarts bounds the restarts in a row, 0 means 5
+	MaxRestarts int `json:"maxRestarts,omitempty"`
+}
+
+// defaultConfigs are used for workspaces without a ConfigPath, e.g. imported
+// repositories, by template
+var defaultConfigs = map[string]Config{
+	"node":   {Command: "npm install && npm run dev"},
+	"python": {Command: "python run.py"},
+	"go":     {Command: "go run ."},
+}
+
+// LoadConfig reads the run configuration of the workspace, falling back to
+// the default of template
+func LoadConfig(workspace *fs.Workspace, template string) (Config, error) {
+	var config Config
+	content, _, err := workspace.FetchFileContent(ConfigPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		config, ok := defaultConfigs[template]
+		if !ok {
+			return Config{}, ErrNoConfig
+		}
+		return config, nil
+	case err != nil:
+		return Config{}, err
+	}
+
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return Config{}, fmt.Errorf("invalid %s: %w", ConfigPath, err)
+	}
+	if config.Command == "" {
+		return Config{}, fmt.Errorf("invalid %s: command is required", ConfigPath)
+	}
+	switch config.Restart {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return Config{}, fmt.Errorf("invalid %s: unknown restart policy %q", ConfigPath, config.Restart)
+	}
+	return config, nil
+}
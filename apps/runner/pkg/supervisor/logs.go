@@ -0,0 +1,62 @@
+package supervisor
+
+import (
+	"sync"
+	"time"
+)
+
+// maxLogLines is how many lines of output the app keeps
+const maxLogLines = 2000
+
+// LogLine is a line of output of the app
+type LogLine struct {
+	// Seq numbers the lines since the runner started, to fetch the ones
+	// after a line already seen
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+// logRing keeps the last maxLogLines lines
+type logRing struct {
+	mu    sync.Mutex
+	lines []LogLine
+	// start is where the oldest line is once lines is full
+	start int
+	seq   int64
+}
+
+func (r *logRing) add(stream, text string) LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	line := LogLine{Seq: r.seq, Time: time.Now(), Stream: stream, Text: text}
+	if len(r.lines) < maxLogLines {
+		r.lines = append(r.lines, line)
+	} else {
+		r.lines[r.start] = line
+		r.start = (r.start + 1) % maxLogLines
+	}
+	return line
+}
+
+// since returns the lines after seq, at most limit of the last ones when
+// limit is positive
+func (r *logRing) since(seq int64, limit int) []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []LogLine
+	for i := range r.lines {
+		line := r.lines[(r.start+i)%len(r.lines)]
+		if line.Seq > seq {
+			out = append(out, line)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
@@ -0,0 +1,330 @@
+// Package supervisor runs the user's app (the dev server of the template),
+// restarts it by its run configuration and keeps its output, so that the
+// editor can offer a Run/Stop button instead of a terminal.
+package supervisor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"runner/pkg/fs"
+)
+
+// States of the app
+const (
+	StateStopped    = "stopped"
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	// StateExited is an app that exited by itself and is not restarted
+	StateExited = "exited"
+)
+
+const (
+	// defaultMaxRestarts applies to configurations without MaxRestarts
+	defaultMaxRestarts = 5
+	// restartDelay is the wait before the first restart, it doubles with
+	// every restart in a row up to maxRestartDelay
+	restartDelay    = time.Second
+	maxRestartDelay = 30 * time.Second
+	// stableAfter is how long the app must run for the restarts to count
+	// from zero again
+	stableAfter = time.Minute
+	// killDelay is how long the app has between SIGTERM and SIGKILL
+	killDelay = 5 * time.Second
+	// maxLineSize bounds a line of output, longer ones are split
+	maxLineSize = 64 << 10
+)
+
+var ErrAlreadyRunning = errors.New("app is already running")
+
+// Status of the app
+type Status struct {
+	State   string `json:"state"`
+	Command string `json:"command,omitempty"`
+	Pid     int    `json:"pid,omitempty"`
+	// StartedAt is when the current or last run started
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// ExitCode of the last run, -1 when it was killed by a signal
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Restarts in a row by the restart policy
+	Restarts int    `json:"restarts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Kinds of event
+const (
+	EventLog    = "log"
+	EventStatus = "status"
+)
+
+// Event is sent to subscribers for every line of output and status change
+type Event struct {
+	Type   string   `json:"type"`
+	Log    *LogLine `json:"log,omitempty"`
+	Status *Status  `json:"status,omitempty"`
+}
+
+// Supervisor runs a single app in the workspace
+type Supervisor struct {
+	workspace *fs.Workspace
+	template  string
+	logs      logRing
+
+	mu     sync.Mutex
+	status Status
+	config Config
+	cmd    *exec.Cmd
+	// done is closed when the current run exited
+	done chan struct{}
+	// stopping is set while Stop waits for the app, so it is not restarted
+	stopping     bool
+	restartTimer *time.Timer
+
+	subMu       sync.Mutex
+	subscribers map[int]func(Event)
+	nextId      int
+}
+
+// New supervises the app of workspace, template picks the run configuration
+// when the workspace has none
+func New(workspace *fs.Workspace, template string) *Supervisor {
+	return &Supervisor{
+		workspace:   workspace,
+		template:    template,
+		status:      Status{State: StateStopped},
+		subscribers: make(map[int]func(Event)),
+	}
+}
+
+// Subscribe calls fn with every event until unsubscribe is called. fn must
+// not block.
+func (s *Supervisor) Subscribe(fn func(Event)) (unsubscribe func()) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	id := s.nextId
+	s.nextId++
+	s.subscribers[id] = fn
+
+	return func() {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+func (s *Supervisor) notify(event Event) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for _, fn := range s.subscribers {
+		fn(event)
+	}
+}
+
+// notifyStatus sends the status, s.mu must be held
+func (s *Supervisor) notifyStatus() {
+	status := s.status
+	s.notify(Event{Type: EventStatus, Status: &status})
+}
+
+// Status returns the status of the app
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Logs returns the lines of output after seq, at most the last limit when
+// limit is positive
+func (s *Supervisor) Logs(seq int64, limit int) []LogLine {
+	return s.logs.since(seq, limit)
+}
+
+// Start runs the app with the run configuration of the workspace
+func (s *Supervisor) Start() error {
+	config, err := LoadConfig(s.workspace, s.template)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.State == StateRunning || s.status.State == StateRestarting {
+		return ErrAlreadyRunning
+	}
+	s.config = config
+	s.status.Restarts = 0
+	return s.start()
+}
+
+// start runs s.config, s.mu must be held
+func (s *Supervisor) start() error {
+	cmd := exec.Command("/bin/bash", "-c", s.config.Command)
+	dir, err := s.workspace.Resolve(s.config.Cwd)
+	if err != nil {
+		return s.fail(err)
+	}
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for key, value := range s.config.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return s.fail(err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return s.fail(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return s.fail(err)
+	}
+
+	now := time.Now()
+	s.cmd = cmd
+	s.done = make(chan struct{})
+	s.status = Status{
+		State:     StateRunning,
+		Command:   s.config.Command,
+		Pid:       cmd.Process.Pid,
+		StartedAt: &now,
+		Restarts:  s.status.Restarts,
+	}
+	s.notifyStatus()
+	log.Printf("Started app (pid %d): %s", cmd.Process.Pid, s.config.Command)
+
+	go s.wait(cmd, s.done, stdout, stderr)
+	return nil
+}
+
+// fail records an app that could not start, s.mu must be held
+func (s *Supervisor) fail(err error) error {
+	s.status = Status{State: StateExited, Command: s.config.Command, Error: err.Error()}
+	s.notifyStatus()
+	return err
+}
+
+// wait collects the output of a run until it exits, then restarts it if
+// its policy says so
+func (s *Supervisor) wait(cmd *exec.Cmd, done chan struct{}, stdout, stderr io.Reader) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go s.readLines(&wg, stdout, "stdout")
+	go s.readLines(&wg, stderr, "stderr")
+	wg.Wait()
+
+	cmd.Wait()
+	exitCode := cmd.ProcessState.ExitCode()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer close(done)
+
+	s.cmd = nil
+	s.status.Pid = 0
+	s.status.ExitCode = &exitCode
+	log.Printf("App exited with code %d", exitCode)
+
+	if s.stopping {
+		s.status.State = StateStopped
+		s.notifyStatus()
+		return
+	}
+
+	// A run that lasted is not part of a crash loop
+	if s.status.StartedAt != nil && time.Since(*s.status.StartedAt) > stableAfter {
+		s.status.Restarts = 0
+	}
+
+	maxRestarts := s.config.MaxRestarts
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMaxRestarts
+	}
+	restart := s.config.Restart == RestartAlways || (s.config.Restart == RestartOnFailure && exitCode != 0)
+	if !restart || s.status.Restarts >= maxRestarts {
+		s.status.State = StateExited
+		s.notifyStatus()
+		return
+	}
+
+	delay := min(restartDelay<<s.status.Restarts, maxRestartDelay)
+	s.status.State = StateRestarting
+	s.notifyStatus()
+	s.restartTimer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status.State != StateRestarting {
+			return
+		}
+		s.status.Restarts++
+		s.start()
+	})
+}
+
+func (s *Supervisor) readLines(wg *sync.WaitGroup, r io.Reader, stream string) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	for scanner.Scan() {
+		line := s.logs.add(stream, scanner.Text())
+		s.notify(Event{Type: EventLog, Log: &line})
+	}
+	// Keep draining a line too long for the scanner, the app would block
+	io.Copy(io.Discard, r)
+}
+
+// Stop stops the app, and its restarts, and waits for it to exit
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	if s.status.State == StateRestarting {
+		s.restartTimer.Stop()
+		s.status.State = StateStopped
+		s.notifyStatus()
+		s.mu.Unlock()
+		return nil
+	}
+	if s.cmd == nil {
+		s.mu.Unlock()
+		return nil
+	}
+
+	pid, done := s.cmd.Process.Pid, s.done
+	s.stopping = true
+	s.mu.Unlock()
+
+	// Stop the whole process group, the shell and what it started
+	syscall.Kill(-pid, syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(killDelay):
+		syscall.Kill(-pid, syscall.SIGKILL)
+		<-done
+	}
+
+	s.mu.Lock()
+	s.stopping = false
+	s.mu.Unlock()
+	return nil
+}
+
+// Restart stops the app if it runs and starts it again, with the run
+// configuration as it is now
+func (s *Supervisor) Restart() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	return s.Start()
+}
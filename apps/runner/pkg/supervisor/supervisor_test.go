@@ -0,0 +1,131 @@
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"runner/pkg/fs"
+)
+
+func newSupervisor(t *testing.T, config string) *Supervisor {
+	t.Helper()
+	dir := t.TempDir()
+	if config != "" {
+		if err := os.MkdirAll(filepath.Join(dir, ".devex"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ConfigPath), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(fs.NewWorkspace(dir), "")
+}
+
+// waitFor returns the first status in state, sent after subscribing
+func waitFor(t *testing.T, s *Supervisor, state string, start func() error) Status {
+	t.Helper()
+	statuses := make(chan Status, 16)
+	unsubscribe := s.Subscribe(func(event Event) {
+		if event.Type == EventStatus && event.Status.State == state {
+			statuses <- *event.Status
+		}
+	})
+	defer unsubscribe()
+
+	if err := start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case status := <-statuses:
+		return status
+	case <-time.After(10 * time.Second):
+		t.Fatalf("app did not reach %s, status %+v", state, s.Status())
+		return Status{}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no configuration", wantErr: true},
+		{name: "command", config: `{"command": "npm start", "restart": "always"}`, want: "npm start"},
+		{name: "no command", config: `{"cwd": "app"}`, wantErr: true},
+		{name: "unknown policy", config: `{"command": "npm start", "restart": "sometimes"}`, wantErr: true},
+		{name: "invalid json", config: `{"command": `, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSupervisor(t, tt.config)
+			config, err := LoadConfig(s.workspace, "")
+			if (err != nil) != tt.wantErr || config.Command != tt.want {
+				t.Errorf("got %+v, %v; want command %q, error %v", config, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	s := newSupervisor(t, "")
+	if _, err := LoadConfig(s.workspace, ""); !errors.Is(err, ErrNoConfig) {
+		t.Errorf("got %v, want ErrNoConfig", err)
+	}
+	if config, err := LoadConfig(s.workspace, "go"); err != nil || config.Command != "go run ." {
+		t.Errorf("got %+v, %v; want the go default", config, err)
+	}
+}
+
+func TestRunExits(t *testing.T) {
+	s := newSupervisor(t, `{"command": "echo \"$GREETING\"; echo oops >&2; exit 3", "env": {"GREETING": "hello"}}`)
+
+	status := waitFor(t, s, StateExited, s.Start)
+	if status.ExitCode == nil || *status.ExitCode != 3 || status.Restarts != 0 {
+		t.Errorf("got %+v, want exit code 3 without restarts", status)
+	}
+
+	lines := s.Logs(0, 0)
+	if len(lines) != 2 {
+		t.Fatalf("got %+v, want 2 lines", lines)
+	}
+	for _, want := range []LogLine{{Stream: "stdout", Text: "hello"}, {Stream: "stderr", Text: "oops"}} {
+		found := false
+		for _, line := range lines {
+			found = found || (line.Stream == want.Stream && line.Text == want.Text)
+		}
+		if !found {
+			t.Errorf("got %+v, want %s line %q", lines, want.Stream, want.Text)
+		}
+	}
+	if after := s.Logs(lines[0].Seq, 0); len(after) != 1 || after[0].Seq != lines[1].Seq {
+		t.Errorf("got %+v after seq %d, want the last line", after, lines[0].Seq)
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	s := newSupervisor(t, `{"command": "exit 1", "restart": "on-failure", "maxRestarts": 1}`)
+
+	status := waitFor(t, s, StateExited, s.Start)
+	if status.Restarts != 1 {
+		t.Errorf("got %+v, want 1 restart", status)
+	}
+}
+
+func TestStop(t *testing.T) {
+	s := newSupervisor(t, `{"command": "sleep 30", "restart": "always"}`)
+
+	waitFor(t, s, StateRunning, s.Start)
+	if err := s.Start(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("got %v, want ErrAlreadyRunning", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.Status(); status.State != StateStopped {
+		t.Errorf("got %+v, want a stopped app", status)
+	}
+}
@@ -0,0 +1,193 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"packages/checkpoint"
+)
+
+var (
+	errNoCheckpoints = errors.New("checkpoints are not configured")
+	// errChanged is returned by putBlob for files edited since they were hashed
+	errChanged = errors.New("file changed")
+)
+
+// Checkpoint syncs the workspace and records its content as a new checkpoint.
+// When the workspace did not change since the latest checkpoint, that one is
+// returned with created set to false.
+func (s *Syncer) Checkpoint(ctx context.Context) (info checkpoint.Info, created bool, err error) {
+	if s.checkpoints == "" {
+		return info, false, errNoCheckpoints
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Files that failed to sync are left out, the next checkpoint has them
+	if err := s.upload(ctx); err != nil {
+		log.Printf("⚠️ Workspace sync before checkpoint failed: %v", err)
+	}
+
+	if s.blobs == nil {
+		if err := s.loadCheckpoints(ctx); err != nil {
+			return info, false, err
+		}
+	}
+
+	now := time.Now().UTC()
+	m := checkpoint.Manifest{
+		Id:        checkpoint.NewId(now),
+		CreatedAt: now,
+		Files:     make(map[string]checkpoint.File, len(s.manifest)),
+	}
+	for rel, e := range s.manifest {
+		m.Files[rel] = checkpoint.File{Hash: e.Hash, Size: e.Size}
+	}
+
+	if m.Digest() == s.lastDigest {
+		return s.latest, false, nil
+	}
+
+	for rel, file := range m.Files {
+		if s.blobs[file.Hash] {
+			continue
+		}
+		if err := s.putBlob(ctx, rel, file); err != nil {
+			if errors.Is(err, errChanged) {
+				log.Printf("⚠️ %s changed while taking checkpoint, leaving it out", rel)
+				delete(m.Files, rel)
+				continue
+			}
+			return info, false, err
+		}
+		s.blobs[file.Hash] = true
+	}
+
+	if err := checkpoint.Save(ctx, s.store, s.checkpoints, m); err != nil {
+		return info, false, err
+	}
+	s.lastDigest = m.Digest()
+	s.latest = m.Info()
+
+	info = s.latest
+	log.Printf("📸 Checkpoint %s: %d files, %d bytes", info.Id, info.Files, info.Size)
+	return info, true, nil
+}
+
+// Restore brings the workspace (or the path p in it) back to a checkpoint and
+// syncs the result right away.
+func (s *Syncer) Restore(ctx context.Context, id, p string) error {
+	if s.checkpoints == "" {
+		return errNoCheckpoints
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := checkpoint.Load(ctx, s.store, s.checkpoints, id)
+	if err != nil {
+		return err
+	}
+	if err := checkpoint.RestoreToDir(ctx, s.store, s.checkpoints, m, p, s.dir); err != nil {
+		return err
+	}
+	log.Printf("⏪ Restored %q to checkpoint %s", "/"+checkpoint.CleanPath(p), id)
+
+	return s.upload(ctx)
+}
+
+// RunCheckpoints takes a checkpoint right away and then every interval until
+// ctx is done, handing each new one to report.
+func (s *Syncer) RunCheckpoints(ctx context.Context, interval time.Duration, report func(context.Context, checkpoint.Info) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		info, created, err := s.Checkpoint(ctx)
+		switch {
+		case err != nil:
+			log.Printf("⚠️ Checkpoint failed: %v", err)
+		case created:
+			if err := report(ctx, info); err != nil {
+				log.Printf("⚠️ Failed to report checkpoint %s: %v", info.Id, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadCheckpoints learns which blobs are in storage already and what the
+// latest checkpoint holds, so a restarted runner does not repeat it.
+func (s *Syncer) loadCheckpoints(ctx context.Context) error {
+	objects, err := s.store.List(ctx, checkpoint.BlobsPrefix(s.checkpoints))
+	if err != nil {
+		return err
+	}
+	blobs := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		blobs[path.Base(obj.Key)] = true
+	}
+
+	manifests, err := s.store.List(ctx, checkpoint.ManifestsPrefix(s.checkpoints))
+	if err != nil {
+		return err
+	}
+	// Manifest ids sort in creation order
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Key < manifests[j].Key })
+	if len(manifests) > 0 {
+		id := strings.TrimSuffix(path.Base(manifests[len(manifests)-1].Key), ".json")
+		latest, err := checkpoint.Load(ctx, s.store, s.checkpoints, id)
+		if err != nil {
+			return err
+		}
+		s.lastDigest = latest.Digest()
+		s.latest = latest.Info()
+	}
+
+	s.blobs = blobs
+	return nil
+}
+
+// putBlob uploads a workspace file as the blob of file, checking on the way
+// that its content still is what the manifest says.
+func (s *Syncer) putBlob(ctx context.Context, rel string, file checkpoint.File) error {
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(rel)))
+	if errors.Is(err, os.ErrNotExist) {
+		return errChanged
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	key := checkpoint.BlobKey(s.checkpoints, file.Hash)
+	h := sha256.New()
+	if err := s.store.Put(ctx, key, io.TeeReader(f, h), file.Size); err != nil {
+		return fmt.Errorf("failed to upload blob of %s: %w", rel, err)
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != file.Hash {
+		if err := s.store.Delete(ctx, key); err != nil {
+			return err
+		}
+		return errChanged
+	}
+	return nil
+}
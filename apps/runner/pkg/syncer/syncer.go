@@ -0,0 +1,205 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"packages/checkpoint"
+	"packages/storage"
+)
+
+// entry is what the manifest remembers of a synced file. Size and mtime are
+// checked first; the file is only hashed when one of them changed.
+type entry struct {
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// Syncer keeps a workspace directory and its storage prefix in sync. The
+// workspace is downloaded once on boot, after that the runner is the only
+// writer and uploads whatever changed since the last sync.
+//
+// With a checkpoint prefix it also takes checkpoints of the workspace, see
+// package checkpoint, reusing the hashes it keeps for syncing.
+type Syncer struct {
+	store       storage.WorkspaceStorage
+	dir         string
+	prefix      string
+	checkpoints string
+
+	mu       sync.Mutex
+	manifest map[string]entry
+	// blobs already in storage, loaded on the first checkpoint
+	blobs map[string]bool
+	// latest checkpoint and the digest of its content
+	latest     checkpoint.Info
+	lastDigest string
+}
+
+func NewSyncer(store storage.WorkspaceStorage, dir, prefix, checkpoints string) *Syncer {
+	return &Syncer{
+		store:       store,
+		dir:         dir,
+		prefix:      prefix,
+		checkpoints: checkpoints,
+		manifest:    make(map[string]entry),
+	}
+}
+
+// Download fetches every object of the workspace and records it in the manifest
+func (s *Syncer) Download(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log.Printf("📥 Downloading workspace from %s", s.prefix)
+	if err := storage.DownloadPrefix(ctx, s.store, s.prefix, s.dir); err != nil {
+		return err
+	}
+
+	files, err := s.scan()
+	if err != nil {
+		return err
+	}
+	for rel, info := range files {
+		hash, err := checkpoint.HashFile(filepath.Join(s.dir, rel))
+		if err != nil {
+			return err
+		}
+		s.manifest[rel] = entry{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+	}
+
+	log.Printf("✅ Downloaded %d files to %s", len(s.manifest), s.dir)
+	return nil
+}
+
+// Upload pushes the files that changed since the last sync and deletes the
+// objects of removed files. A failed file stays out of the manifest so that
+// the next sync retries it.
+func (s *Syncer) Upload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.upload(ctx)
+}
+
+func (s *Syncer) upload(ctx context.Context) error {
+	files, err := s.scan()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	uploaded, deleted := 0, 0
+
+	for rel, info := range files {
+		old, known := s.manifest[rel]
+		if known && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		file := filepath.Join(s.dir, rel)
+		hash, err := checkpoint.HashFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		current := entry{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+
+		// Touched but unchanged (e.g. saved without edits)
+		if known && old.Hash == hash {
+			s.manifest[rel] = current
+			continue
+		}
+
+		if err := storage.UploadFile(ctx, s.store, file, s.key(rel)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.manifest[rel] = current
+		uploaded++
+	}
+
+	for rel := range s.manifest {
+		if _, exists := files[rel]; exists {
+			continue
+		}
+		if err := s.store.Delete(ctx, s.key(rel)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(s.manifest, rel)
+		deleted++
+	}
+
+	if uploaded > 0 || deleted > 0 {
+		log.Printf("📤 Synced workspace to %s: %d uploaded, %d deleted", s.prefix, uploaded, deleted)
+	}
+	return errors.Join(errs...)
+}
+
+// Run uploads changes every interval until ctx is done
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Upload(ctx); err != nil {
+				log.Printf("⚠️ Periodic workspace sync failed: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Syncer) key(rel string) string {
+	return path.Join(s.prefix, rel)
+}
+
+// scan lists the regular files of the workspace by their slash separated
+// path relative to it. Symlinks are not followed nor uploaded.
+func (s *Syncer) scan() (map[string]iofs.FileInfo, error) {
+	files := make(map[string]iofs.FileInfo)
+
+	err := filepath.WalkDir(s.dir, func(file string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			// Files can disappear while walking, e.g. build outputs
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(s.dir, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("file %s is outside of the workspace", file)
+		}
+		files[filepath.ToSlash(rel)] = info
+		return nil
+	})
+
+	return files, err
+}
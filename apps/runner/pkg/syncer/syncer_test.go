@@ -0,0 +1,192 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"packages/storage"
+)
+
+const prefix = "repl/octocat/demo/"
+
+// recorder keeps track of the writes made to the storage it wraps
+type recorder struct {
+	storage.WorkspaceStorage
+	puts, deletes []string
+	// failDelete makes the deletes of these keys fail
+	failDelete map[string]bool
+}
+
+func (r *recorder) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	r.puts = append(r.puts, strings.TrimPrefix(key, prefix))
+	return r.WorkspaceStorage.Put(ctx, key, body, size)
+}
+
+func (r *recorder) Delete(ctx context.Context, key string) error {
+	if r.failDelete[key] {
+		return errors.New("delete failed")
+	}
+	r.deletes = append(r.deletes, strings.TrimPrefix(key, prefix))
+	return r.WorkspaceStorage.Delete(ctx, key)
+}
+
+func (r *recorder) reset() {
+	r.puts, r.deletes = nil, nil
+}
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	file := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedKeys lists the files of the workspace in storage
+func storedKeys(t *testing.T, s storage.WorkspaceStorage) []string {
+	t.Helper()
+	objects, err := s.List(context.Background(), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, obj := range objects {
+		keys = append(keys, strings.TrimPrefix(obj.Key, prefix))
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestUpload(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir string)
+		// failDelete makes the delete of these files fail
+		failDelete  []string
+		wantPuts    []string
+		wantDeletes []string
+		wantErr     bool
+		wantStored  []string
+	}{
+		{
+			name:       "nothing changed",
+			change:     func(t *testing.T, dir string) {},
+			wantStored: []string{"main.go", "src/app.js"},
+		},
+		{
+			name: "new file",
+			change: func(t *testing.T, dir string) {
+				writeFile(t, dir, "src/util.js", "export {}")
+			},
+			wantPuts:   []string{"src/util.js"},
+			wantStored: []string{"main.go", "src/app.js", "src/util.js"},
+		},
+		{
+			name: "edited file",
+			change: func(t *testing.T, dir string) {
+				writeFile(t, dir, "main.go", "package main\n\nfunc main() {}")
+			},
+			wantPuts:   []string{"main.go"},
+			wantStored: []string{"main.go", "src/app.js"},
+		},
+		{
+			name: "saved without edits",
+			change: func(t *testing.T, dir string) {
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(filepath.Join(dir, "main.go"), later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStored: []string{"main.go", "src/app.js"},
+		},
+		{
+			name: "deleted file",
+			change: func(t *testing.T, dir string) {
+				os.Remove(filepath.Join(dir, "main.go"))
+			},
+			wantDeletes: []string{"main.go"},
+			wantStored:  []string{"src/app.js"},
+		},
+		{
+			name: "deleted folder",
+			change: func(t *testing.T, dir string) {
+				os.RemoveAll(filepath.Join(dir, "src"))
+			},
+			wantDeletes: []string{"src/app.js"},
+			wantStored:  []string{"main.go"},
+		},
+		{
+			name: "failed delete",
+			change: func(t *testing.T, dir string) {
+				os.Remove(filepath.Join(dir, "main.go"))
+			},
+			failDelete: []string{"main.go"},
+			wantErr:    true,
+			wantStored: []string{"main.go", "src/app.js"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			base := t.TempDir()
+			store := &recorder{WorkspaceStorage: storage.NewFS(filepath.Join(base, "storage"))}
+
+			for rel, content := range map[string]string{"main.go": "package main", "src/app.js": "console.log(1)"} {
+				if err := store.Put(ctx, prefix+rel, strings.NewReader(content), int64(len(content))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dir := filepath.Join(base, "workspace")
+			s := NewSyncer(store, dir, prefix, "")
+			if err := s.Download(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, dir)
+			store.reset()
+			store.failDelete = make(map[string]bool)
+			for _, rel := range tt.failDelete {
+				store.failDelete[prefix+rel] = true
+			}
+
+			err := s.Upload(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			slices.Sort(store.puts)
+			slices.Sort(store.deletes)
+			if !slices.Equal(store.puts, tt.wantPuts) {
+				t.Errorf("uploaded %v, want %v", store.puts, tt.wantPuts)
+			}
+			if !slices.Equal(store.deletes, tt.wantDeletes) {
+				t.Errorf("deleted %v, want %v", store.deletes, tt.wantDeletes)
+			}
+			if got := storedKeys(t, store); !slices.Equal(got, tt.wantStored) {
+				t.Errorf("stored %v, want %v", got, tt.wantStored)
+			}
+
+			// A second sync only retries what failed
+			store.reset()
+			store.failDelete = nil
+			if err := s.Upload(ctx); err != nil {
+				t.Fatalf("second Upload() error = %v", err)
+			}
+			if len(store.puts) != 0 || len(store.deletes) != len(tt.failDelete) {
+				t.Errorf("second Upload() uploaded %v and deleted %v, want only the failed deletes %v",
+					store.puts, store.deletes, tt.failDelete)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package watcher reports changes to the workspace files, e.g. made from the
+// terminal, so that editors can refresh their file tree and open files.
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Kinds of change
+const (
+	Created  = "created"
+	Modified = "modified"
+	Deleted  = "deleted"
+	Renamed  = "renamed"
+)
+
+const (
+	// debounce is how long the workspace must be quiet before changes are
+	// reported
+	debounce = 150 * time.Millisecond
+	// maxDelay bounds how long changes wait while the workspace keeps
+	// changing (e.g. an install)
+	maxDelay = time.Second
+	// maxChanges bounds a batch, larger ones are reported as an overflow and
+	// clients reload the whole tree
+	maxChanges = 500
+)
+
+// Change of a single path, relative to the workspace with "/" separators
+type Change struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// OldPath is set for renames
+	OldPath string `json:"oldPath,omitempty"`
+	IsDir   bool   `json:"isDir"`
+}
+
+// Batch is what subscribers get after the workspace settled
+type Batch struct {
+	Changes []Change `json:"changes"`
+	// Overflow means too many changes to list, Changes is empty then
+	Overflow bool `json:"overflow,omitempty"`
+}
+
+// Watcher watches a workspace recursively, skipping ignored folders
+type Watcher struct {
+	root   string
+	ignore []string
+	fsw    *fsnotify.Watcher
+	// dirs are the watched folders, the kernel drops the watch of a removed
+	// folder before its parent reports it
+	dirs map[string]bool
+
+	mu          sync.Mutex
+	subscribers map[int]func(Batch)
+	nextId      int
+
+	// pending changes by path, in the order they were first seen
+	pending  map[string]*Change
+	order    []string
+	overflow bool
+	// lastRename is the path of a rename waiting for the create of its new
+	// name, inotify reports both halves back to back
+	lastRename string
+}
+
+// New watches root. ignore holds names (or filepath.Match patterns) of files
+// and folders skipped at any depth, like node_modules or .git.
+func New(root string, ignore []string) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:        root,
+		ignore:      ignore,
+		fsw:         fsw,
+		dirs:        make(map[string]bool),
+		subscribers: make(map[int]func(Batch)),
+		pending:     make(map[string]*Change),
+	}
+	if err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Subscribe calls fn with every batch of changes until unsubscribe is called.
+// fn must not block.
+func (w *Watcher) Subscribe(fn func(Batch)) (unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextId
+	w.nextId++
+	w.subscribers[id] = fn
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, id)
+	}
+}
+
+// Run reports changes until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	defer w.fsw.Close()
+
+	// quiet fires once nothing changed for debounce, deadline once the
+	// oldest pending change waited for maxDelay
+	quiet := time.NewTimer(time.Hour)
+	quiet.Stop()
+	var deadline <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !w.record(event) {
+				continue
+			}
+			quiet.Reset(debounce)
+			if deadline == nil {
+				deadline = time.After(maxDelay)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("⚠️ Workspace watcher error: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.mu.Lock()
+				w.overflow = true
+				w.mu.Unlock()
+				quiet.Reset(debounce)
+			}
+		case <-quiet.C:
+			deadline = nil
+			w.flush()
+		case <-deadline:
+			deadline = nil
+			quiet.Stop()
+			w.flush()
+		}
+	}
+}
+
+// record adds an fsnotify event to the pending changes, it returns false for
+// ignored events
+func (w *Watcher) record(event fsnotify.Event) bool {
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || rel == "." || w.ignored(rel) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lastRename := w.lastRename
+	w.lastRename = ""
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Lstat(event.Name)
+		isDir := err == nil && info.IsDir()
+		if isDir {
+			// New folders are not watched yet, and may already have files
+			// (mkdir -p, mv, unpacking)
+			if err := w.addTree(event.Name); err != nil {
+				log.Printf("⚠️ Failed to watch %s: %v", rel, err)
+			}
+		}
+
+		if lastRename != "" {
+			if old, ok := w.pending[lastRename]; ok && old.Type == Deleted {
+				w.remove(lastRename)
+				w.add(Change{Type: Renamed, Path: rel, OldPath: lastRename, IsDir: isDir})
+				return true
+			}
+		}
+		if previous, ok := w.pending[rel]; ok && previous.Type == Deleted {
+			// Replaced, e.g. by an editor saving through a temp file
+			w.add(Change{Type: Modified, Path: rel, IsDir: isDir})
+			return true
+		}
+		w.add(Change{Type: Created, Path: rel, IsDir: isDir})
+	case event.Has(fsnotify.Write), event.Has(fsnotify.Chmod):
+		if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+			// Metadata only (touch, chmod), not worth a reload
+			return false
+		}
+		if _, ok := w.pending[rel]; ok {
+			// created or modified already says it
+			return true
+		}
+		w.add(Change{Type: Modified, Path: rel})
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		if w.renamedFrom(rel) {
+			// A moved folder reports the move on its own watch too
+			return false
+		}
+		isDir := w.dirs[event.Name]
+		if isDir {
+			// Its own watch is gone with it, drop the ones below
+			w.unwatchTree(event.Name)
+		}
+
+		if previous, ok := w.pending[rel]; ok && previous.Type == Created {
+			// Came and went within the batch
+			w.remove(rel)
+			return true
+		}
+		w.add(Change{Type: Deleted, Path: rel, IsDir: isDir})
+		if event.Has(fsnotify.Rename) {
+			w.lastRename = rel
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// add records a change, replacing the pending one for the same path
+func (w *Watcher) add(change Change) {
+	if _, ok := w.pending[change.Path]; !ok {
+		w.order = append(w.order, change.Path)
+	}
+	w.pending[change.Path] = &change
+}
+
+func (w *Watcher) remove(path string) {
+	delete(w.pending, path)
+}
+
+// renamedFrom reports whether a pending rename moved path away
+func (w *Watcher) renamedFrom(path string) bool {
+	for _, change := range w.pending {
+		if change.Type == Renamed && change.OldPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// flush sends the pending changes to the subscribers
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	batch := Batch{Changes: []Change{}, Overflow: w.overflow}
+	if !w.overflow {
+		for _, path := range w.order {
+			if change, ok := w.pending[path]; ok {
+				batch.Changes = append(batch.Changes, *change)
+			}
+		}
+		if len(batch.Changes) > maxChanges {
+			batch = Batch{Changes: []Change{}, Overflow: true}
+		}
+	}
+	w.pending = make(map[string]*Change)
+	w.order = nil
+	w.overflow = false
+	w.lastRename = ""
+
+	subscribers := make([]func(Batch), 0, len(w.subscribers))
+	for _, fn := range w.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	w.mu.Unlock()
+
+	if len(batch.Changes) == 0 && !batch.Overflow {
+		return
+	}
+	for _, fn := range subscribers {
+		fn(batch)
+	}
+}
+
+// addTree watches dir and the folders below it
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Gone or unreadable meanwhile, keep watching the rest
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rel, _ := filepath.Rel(w.root, path); rel != "." && w.ignored(rel) {
+			return filepath.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return err
+		}
+		w.dirs[path] = true
+		return nil
+	})
+}
+
+func (w *Watcher) unwatchTree(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for watched := range w.dirs {
+		if watched == dir || strings.HasPrefix(watched, prefix) {
+			// The kernel may have dropped it already
+			_ = w.fsw.Remove(watched)
+			delete(w.dirs, watched)
+		}
+	}
+}
+
+// ignored reports whether an element of rel matches an ignore pattern
+func (w *Watcher) ignored(rel string) bool {
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		for _, pattern := range w.ignore {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
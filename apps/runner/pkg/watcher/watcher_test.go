@@ -0,0 +1,253 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var testIgnore = []string{"node_modules", ".git", "*.swp"}
+
+func newTestWatcher(t *testing.T) (*Watcher, string) {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(root, testIgnore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.fsw.Close() })
+	return w, root
+}
+
+func TestIgnored(t *testing.T) {
+	w := &Watcher{ignore: testIgnore}
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{rel: "main.go"},
+		{rel: "src/app.js"},
+		{rel: "node_modules", want: true},
+		{rel: "node_modules/react/index.js", want: true},
+		{rel: "packages/web/node_modules/react", want: true},
+		{rel: ".git/HEAD", want: true},
+		{rel: ".gitignore"},
+		{rel: "src/.main.go.swp", want: true},
+		{rel: "src/main.swp.go"},
+		{rel: "my_node_modules/index.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := w.ignored(filepath.FromSlash(tt.rel)); got != tt.want {
+				t.Errorf("ignored(%q) = %v, want %v", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+type event struct {
+	op  fsnotify.Op
+	rel string
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		// dirs are the folders existing when the events are recorded
+		dirs   []string
+		events []event
+		want   []Change
+	}{
+		{
+			name:   "created",
+			events: []event{{fsnotify.Create, "main.go"}, {fsnotify.Write, "main.go"}},
+			want:   []Change{{Type: Created, Path: "main.go"}},
+		},
+		{
+			name:   "modified",
+			events: []event{{fsnotify.Write, "main.go"}, {fsnotify.Write, "main.go"}},
+			want:   []Change{{Type: Modified, Path: "main.go"}},
+		},
+		{
+			name:   "metadata only",
+			events: []event{{fsnotify.Chmod, "main.go"}},
+		},
+		{
+			name:   "deleted",
+			events: []event{{fsnotify.Remove, "main.go"}},
+			want:   []Change{{Type: Deleted, Path: "main.go"}},
+		},
+		{
+			name:   "created then deleted",
+			events: []event{{fsnotify.Create, "tmp.txt"}, {fsnotify.Write, "tmp.txt"}, {fsnotify.Remove, "tmp.txt"}},
+		},
+		{
+			name:   "saved through a temp file",
+			events: []event{{fsnotify.Remove, "main.go"}, {fsnotify.Create, "main.go"}},
+			want:   []Change{{Type: Modified, Path: "main.go"}},
+		},
+		{
+			name:   "renamed",
+			events: []event{{fsnotify.Rename, "old.go"}, {fsnotify.Create, "new.go"}},
+			want:   []Change{{Type: Renamed, Path: "new.go", OldPath: "old.go"}},
+		},
+		{
+			name:   "renamed folder",
+			dirs:   []string{"lib"},
+			events: []event{{fsnotify.Rename, "src"}, {fsnotify.Create, "lib"}, {fsnotify.Rename, "src"}},
+			want:   []Change{{Type: Renamed, Path: "lib", OldPath: "src", IsDir: true}},
+		},
+		{
+			name:   "created folder",
+			dirs:   []string{"src"},
+			events: []event{{fsnotify.Create, "src"}},
+			want:   []Change{{Type: Created, Path: "src", IsDir: true}},
+		},
+		{
+			name: "ignored",
+			events: []event{
+				{fsnotify.Create, "node_modules/react/index.js"},
+				{fsnotify.Write, ".git/index"},
+				{fsnotify.Create, ".main.go.swp"},
+				{fsnotify.Write, "main.go"},
+			},
+			want: []Change{{Type: Modified, Path: "main.go"}},
+		},
+		{
+			name:   "in order of first change",
+			events: []event{{fsnotify.Write, "b.go"}, {fsnotify.Create, "a.go"}, {fsnotify.Write, "b.go"}},
+			want:   []Change{{Type: Modified, Path: "b.go"}, {Type: Created, Path: "a.go"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, root := newTestWatcher(t)
+			for _, dir := range tt.dirs {
+				if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []Batch
+			w.Subscribe(func(batch Batch) { got = append(got, batch) })
+			for _, e := range tt.events {
+				w.record(fsnotify.Event{Name: filepath.Join(root, filepath.FromSlash(e.rel)), Op: e.op})
+			}
+			w.flush()
+
+			// Batches without changes are not sent
+			var want []Batch
+			if len(tt.want) > 0 {
+				want = []Batch{{Changes: tt.want}}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRecordOverflow(t *testing.T) {
+	w, root := newTestWatcher(t)
+
+	var got []Batch
+	w.Subscribe(func(batch Batch) { got = append(got, batch) })
+	for i := 0; i <= maxChanges; i++ {
+		w.record(fsnotify.Event{Name: filepath.Join(root, "file"+strconv.Itoa(i)), Op: fsnotify.Write})
+	}
+	w.flush()
+
+	want := []Batch{{Changes: []Change{}, Overflow: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d batches, want one overflow", len(got))
+	}
+}
+
+// runWatcher runs a watcher of a fresh workspace and returns its batches
+func runWatcher(t *testing.T) (root string, batches <-chan Batch) {
+	t.Helper()
+
+	w, root := newTestWatcher(t)
+	ch := make(chan Batch, 16)
+	w.Subscribe(func(batch Batch) { ch <- batch })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go w.Run(ctx)
+	return root, ch
+}
+
+func TestRunDebounce(t *testing.T) {
+	root, batches := runWatcher(t)
+
+	// A burst of changes is reported once, after the workspace is quiet
+	start := time.Now()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("package main"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Mkdir(filepath.Join(root, "node_modules"), 0755)
+
+	select {
+	case batch := <-batches:
+		if elapsed := time.Since(start); elapsed < debounce {
+			t.Errorf("reported after %v, want at least %v", elapsed, debounce)
+		}
+		want := []Change{{Type: Created, Path: "a.go"}, {Type: Created, Path: "b.go"}, {Type: Created, Path: "c.go"}}
+		if !reflect.DeepEqual(batch.Changes, want) {
+			t.Errorf("got %+v, want %+v", batch.Changes, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no changes reported")
+	}
+
+	select {
+	case batch := <-batches:
+		t.Errorf("got a second batch %+v", batch)
+	case <-time.After(3 * debounce):
+	}
+}
+
+func TestRunMaxDelay(t *testing.T) {
+	root, batches := runWatcher(t)
+
+	// Changes keep coming faster than debounce, they are reported anyway
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		file := filepath.Join(root, "install.log")
+		for i := 0; ctx.Err() == nil; i++ {
+			os.WriteFile(file, []byte(strconv.Itoa(i)), 0644)
+			time.Sleep(debounce / 3)
+		}
+	}()
+
+	start := time.Now()
+	select {
+	case <-batches:
+		if elapsed := time.Since(start); elapsed > maxDelay+time.Second {
+			t.Errorf("reported after %v, want about %v", elapsed, maxDelay)
+		}
+	case <-time.After(5 * maxDelay):
+		t.Fatal("no changes reported while the workspace kept changing")
+	}
+}
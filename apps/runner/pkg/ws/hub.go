@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Identity is who is behind a connection, as told by the client
+type Identity struct {
+	UserId string `json:"userId,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Presence of a connected client
+type Presence struct {
+	ClientId string `json:"clientId"`
+	Identity
+	// Path is the file the client is viewing, if any
+	Path        string    `json:"path,omitempty"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// Kinds of presence event
+const (
+	PresenceJoined  = "joined"
+	PresenceLeft    = "left"
+	PresenceViewing = "viewing"
+)
+
+// PresenceEvent is emitted as "presence" to the other clients when a client
+// connects, disconnects or opens another file
+type PresenceEvent struct {
+	Type   string   `json:"type"`
+	Client Presence `json:"client"`
+}
+
+// Hub tracks every connection of a repl, for broadcasts, targeted sends and
+// presence. Connections leave the hub when they close.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[string]*hubClient
+}
+
+type hubClient struct {
+	conn     *WSHandler
+	presence Presence
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: make(map[string]*hubClient)}
+}
+
+// Add registers an initialized connection under clientId. The client gets
+// its own presence and the others as "presenceState", the others a
+// "presence" joined event.
+func (h *Hub) Add(clientId string, conn *WSHandler, identity Identity) {
+	presence := Presence{ClientId: clientId, Identity: identity, ConnectedAt: time.Now()}
+
+	h.mu.Lock()
+	others := make([]Presence, 0, len(h.clients))
+	for _, c := range h.clients {
+		others = append(others, c.presence)
+	}
+	h.clients[clientId] = &hubClient{conn: conn, presence: presence}
+	h.mu.Unlock()
+
+	conn.Emit("presenceState", map[string]any{"self": presence, "clients": others})
+	h.BroadcastExcept(clientId, "presence", PresenceEvent{Type: PresenceJoined, Client: presence})
+
+	go func() {
+		<-conn.Done()
+		h.remove(clientId)
+	}()
+}
+
+func (h *Hub) remove(clientId string) {
+	h.mu.Lock()
+	c, ok := h.clients[clientId]
+	delete(h.clients, clientId)
+	h.mu.Unlock()
+
+	if ok {
+		h.Broadcast("presence", PresenceEvent{Type: PresenceLeft, Client: c.presence})
+	}
+}
+
+// SetViewing records the file clientId is viewing, "" when none, and lets
+// the other clients know
+func (h *Hub) SetViewing(clientId, path string) {
+	h.mu.Lock()
+	c, ok := h.clients[clientId]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	c.presence.Path = path
+	presence := c.presence
+	h.mu.Unlock()
+
+	h.BroadcastExcept(clientId, "presence", PresenceEvent{Type: PresenceViewing, Client: presence})
+}
+
+// Clients returns the presence of every connected client
+func (h *Hub) Clients() []Presence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]Presence, 0, len(h.clients))
+	for _, c := range h.clients {
+		clients = append(clients, c.presence)
+	}
+	return clients
+}
+
+// Count returns the number of connected clients
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Broadcast sends a message to every client
+func (h *Hub) Broadcast(event string, data any) {
+	h.BroadcastExcept("", event, data)
+}
+
+// BroadcastExcept sends a message to every client but clientId
+func (h *Hub) BroadcastExcept(clientId, event string, data any) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for id, c := range h.clients {
+		if id == clientId {
+			continue
+		}
+		if err := c.conn.Emit(event, data); err != nil {
+			log.Printf("Failed to emit %s to client %s: %v", event, id, err)
+		}
+	}
+}
+
+// SendTo sends a message to a single client
+func (h *Hub) SendTo(clientId, event string, data any) error {
+	h.mu.RLock()
+	c, ok := h.clients[clientId]
+	h.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("client %s is not connected", clientId)
+	}
+	return c.conn.Emit(event, data)
+}
@@ -190,8 +190,7 @@ func (ws *WSHandler) IsConnected() bool {
 	return ws.conn != nil
 }
 
-// Broadcast can be used to send messages to multiple connections (if you extend this)
-// For now, it's the same as Emit but can be extended for multiple connections
-func (ws *WSHandler) Broadcast(event string, data any) error {
-	return ws.Emit(event, data)
+// Done is closed once the connection is closed
+func (ws *WSHandler) Done() <-chan struct{} {
+	return ws.done
 }
@@ -0,0 +1,56 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"packages/replauth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokenInterceptors serve the calls carrying an mcp token of the repl in
+// their authorization metadata, the mcp server is the only client. A nil
+// Verifier serves everything.
+func tokenInterceptors(verifier *replauth.Verifier) []grpc.ServerOption {
+	if verifier == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := authorize(ctx, verifier); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorize(stream.Context(), verifier); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
+
+func authorize(ctx context.Context, verifier *replauth.Verifier) error {
+	var token string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+
+	claims, err := verifier.Verify(strings.TrimSpace(token))
+	switch {
+	case errors.Is(err, replauth.ErrWrongRepl):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case claims.Scope != replauth.ScopeMcp:
+		return status.Error(codes.PermissionDenied, "access token lacks the "+replauth.ScopeMcp+" scope")
+	}
+	return nil
+}
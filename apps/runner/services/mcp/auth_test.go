@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"packages/replauth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorize(t *testing.T) {
+	key := replauth.ReplKey([]byte("test-secret"), "repl-a")
+	verifier := replauth.NewVerifier(key, "repl-a")
+
+	issue := func(key []byte, scope string) string {
+		token, _, err := replauth.Issue(key, "repl-a", "mcp", scope, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{"mcp", "Bearer " + issue(key, replauth.ScopeMcp), codes.OK},
+		{"none", "", codes.Unauthenticated},
+		{"user", "Bearer " + issue(key, replauth.ScopeUser), codes.PermissionDenied},
+		{"other key", "Bearer " + issue(replauth.ReplKey([]byte("other"), "repl-a"), replauth.ScopeMcp), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.token))
+			}
+			if code := status.Code(authorize(ctx, verifier)); code != tt.code {
+				t.Errorf("authorize() = %v, want %v", code, tt.code)
+			}
+		})
+	}
+}
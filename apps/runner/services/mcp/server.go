@@ -2,34 +2,43 @@ package mcp
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net"
+	"os"
 	"packages/pb"
+	"packages/replauth"
+	"runner/pkg/command"
 	"runner/pkg/fs"
+	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type grpcServer struct {
 	pb.UnimplementedReplServiceServer
+	workspace *fs.Workspace
+	commands  *command.Manager
 }
 
-func NewGrpcServer(lis net.Listener) error {
-	server := grpc.NewServer()
-	pb.RegisterReplServiceServer(server, &grpcServer{})
+// NewGrpcServer serves the mcp server of the repl on lis, its calls must
+// carry a token verifier accepts
+func NewGrpcServer(lis net.Listener, workspace *fs.Workspace, commands *command.Manager, verifier *replauth.Verifier) error {
+	server := grpc.NewServer(tokenInterceptors(verifier)...)
+	pb.RegisterReplServiceServer(server, &grpcServer{workspace: workspace, commands: commands})
 
-	log.Println("Starting gRPC server on :50051")
+	log.Println("Starting gRPC server on", lis.Addr())
 	return server.Serve(lis)
 }
 
 func (s *grpcServer) FetchContent(ctx context.Context, in *pb.FetchContentRequest) (*pb.FetchContentResponse, error) {
 
-	fullPath := fmt.Sprintf("/workspaces/%s", in.Path)
-	data, err := fs.FetchFileContent(fullPath)
+	data, _, err := s.workspace.FetchFileContent(in.Path)
 	if err != nil {
 		log.Printf("Error fetching file content: %v", err)
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	return &pb.FetchContentResponse{
@@ -37,3 +46,66 @@ func (s *grpcServer) FetchContent(ctx context.Context, in *pb.FetchContentReques
 	}, nil
 
 }
+
+func (s *grpcServer) Exec(in *pb.ExecRequest, stream pb.ReplService_ExecServer) error {
+	req := command.Request{
+		Command:   in.Argv,
+		Shell:     in.Shell,
+		Cwd:       in.Cwd,
+		Env:       make(map[string]string),
+		TimeoutMs: in.TimeoutMs,
+		Stdin:     string(in.Stdin),
+	}
+	for _, kv := range in.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		req.Env[key] = value
+	}
+
+	// Events are sent one at a time, outputs come from two goroutines
+	events := make(chan *pb.ExecEvent, 64)
+	sendDone := make(chan error, 1)
+	go func() {
+		var sendErr error
+		for event := range events {
+			if sendErr == nil {
+				sendErr = stream.Send(event)
+			}
+		}
+		sendDone <- sendErr
+	}()
+
+	result, err := s.commands.Run(stream.Context(), command.NewId(), req, func(name string, data []byte) {
+		events <- &pb.ExecEvent{Stream: name, Data: data}
+	})
+	switch {
+	case errors.Is(err, command.ErrNoCommand), errors.Is(err, command.ErrNotFound):
+		close(events)
+		<-sendDone
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, fs.ErrOutsideWorkspace):
+		close(events)
+		<-sendDone
+		return statusError(err)
+	}
+
+	exit := &pb.ExecEvent{Exited: true, ExitCode: int32(result.ExitCode), TimedOut: result.TimedOut}
+	if err != nil {
+		log.Printf("Error running command: %v", err)
+		exit.Error = err.Error()
+	}
+	events <- exit
+	close(events)
+	return <-sendDone
+}
+
+// statusError gives workspace errors their gRPC code
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrOutsideWorkspace):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,48 @@
+package repl
+
+import (
+	"log"
+
+	"runner/pkg/supervisor"
+	"runner/pkg/ws"
+)
+
+// registerAppHandlers adds the events of the Run/Stop button. The app is
+// shared by every client, its output and status changes are pushed to all of
+// them as appLog and appStatus.
+func registerAppHandlers(ws *ws.WSHandler, app *supervisor.Supervisor) {
+	ws.On("runApp", func(data any) {
+		if err := app.Start(); err != nil {
+			log.Printf("Error running app: %v", err)
+			ws.Emit("runAppResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("runAppResponse", map[string]any{"success": true, "status": app.Status()})
+	})
+
+	ws.On("stopApp", func(data any) {
+		if err := app.Stop(); err != nil {
+			log.Printf("Error stopping app: %v", err)
+			ws.Emit("stopAppResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("stopAppResponse", map[string]any{"success": true, "status": app.Status()})
+	})
+
+	ws.On("restartApp", func(data any) {
+		if err := app.Restart(); err != nil {
+			log.Printf("Error restarting app: %v", err)
+			ws.Emit("restartAppResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("restartAppResponse", map[string]any{"success": true, "status": app.Status()})
+	})
+
+	OnTyped(ws, "appLogs", func(req AppLogsRequest) {
+		ws.Emit("appLogsResponse", map[string]any{"lines": app.Logs(req.Since, req.Limit)})
+	})
+
+	ws.On("appStatus", func(data any) {
+		ws.Emit("appStatusResponse", map[string]any{"status": app.Status()})
+	})
+}
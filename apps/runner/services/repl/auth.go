@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"packages/replauth"
+	"runner/pkg/ws"
+)
+
+const (
+	// tokenCheckInterval is how often the token of a connection is checked
+	// for expiry and revocation
+	tokenCheckInterval = 15 * time.Second
+	// closeDelay lets tokenExpired reach the client before the connection
+	// is closed
+	closeDelay = time.Second
+)
+
+// registerAuthHandlers keeps the connection open while its token is valid.
+// Clients send refreshToken with a new token of the same user before theirs
+// expires, or get tokenExpired and are disconnected. The returned func stops
+// checking the token.
+func registerAuthHandlers(ws *ws.WSHandler, verifier *replauth.Verifier, claims *replauth.Claims) (stop func()) {
+	if verifier == nil || claims == nil {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	OnTyped(ws, "refreshToken", func(req RefreshTokenRequest) {
+		mu.Lock()
+		user := claims.User()
+		mu.Unlock()
+
+		refreshed, err := verifier.Verify(req.Token)
+		if err == nil && refreshed.User() != user {
+			err = replauth.ErrInvalid
+		}
+		if err != nil {
+			log.Printf("Error refreshing token: %v", err)
+			ws.Emit("refreshTokenResponse", map[string]any{"error": err.Error()})
+			return
+		}
+
+		mu.Lock()
+		claims = refreshed
+		mu.Unlock()
+		ws.Emit("refreshTokenResponse", map[string]any{"success": true, "expiresAt": refreshed.Expiry()})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(tokenCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ws.Done():
+				return
+			case <-ticker.C:
+				mu.Lock()
+				user, err := claims.User(), verifier.Check(claims)
+				mu.Unlock()
+				if err != nil {
+					log.Printf("Closing connection of %s: %v", user, err)
+					ws.Emit("tokenExpired", map[string]any{"error": err.Error()})
+					time.AfterFunc(closeDelay, func() { ws.Close() })
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
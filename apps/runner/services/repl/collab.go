@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"errors"
+	"log"
+
+	"runner/pkg/collab"
+	"runner/pkg/ws"
+)
+
+// registerCollabHandlers adds the shared document events. A client opens a
+// file with openDocument, sends its edits as documentOperation and its cursor
+// as documentSelection, and gets the edits and cursors of the other clients
+// as documentEvent. The returned func closes the client's documents.
+func registerCollabHandlers(ws *ws.WSHandler, docs *collab.Sessions, clientId string) (leave func()) {
+	send := func(event collab.Event) {
+		if err := ws.Emit("documentEvent", event); err != nil {
+			log.Printf("Failed to emit documentEvent: %v", err)
+		}
+	}
+
+	OnTyped(ws, "openDocument", func(req OpenDocumentRequest) {
+		_, snapshot, err := docs.Join(req.Path, clientId, send)
+		if err != nil {
+			log.Printf("Error opening document %s: %v", req.Path, err)
+			ws.Emit("openDocumentResponse", map[string]any{"error": err.Error(), "path": req.Path})
+			return
+		}
+		ws.Emit("openDocumentResponse", map[string]any{"document": snapshot, "clientId": clientId})
+	})
+
+	OnTyped(ws, "closeDocument", func(req OpenDocumentRequest) {
+		docs.Leave(req.Path, clientId)
+	})
+
+	OnTyped(ws, "documentOperation", func(req DocumentOperationRequest) {
+		doc, ok := docs.Get(req.Path)
+		if !ok {
+			ws.Emit("documentOperationResponse", map[string]any{"error": "document is not open", "path": req.Path, "resync": true})
+			return
+		}
+		_, revision, err := doc.Apply(clientId, req.Revision, req.Operation, req.Selection)
+		if err != nil {
+			log.Printf("Error applying operation to %s: %v", req.Path, err)
+			// The client is out of sync and must open the document again
+			ws.Emit("documentOperationResponse", map[string]any{
+				"error":  err.Error(),
+				"path":   req.Path,
+				"resync": errors.Is(err, collab.ErrRevisionTooOld) || errors.Is(err, collab.ErrLengthMismatch),
+			})
+			return
+		}
+		ws.Emit("documentOperationResponse", map[string]any{"success": true, "path": req.Path, "revision": revision})
+	})
+
+	OnTyped(ws, "documentSelection", func(req DocumentSelectionRequest) {
+		doc, ok := docs.Get(req.Path)
+		if !ok {
+			return
+		}
+		if err := doc.Select(clientId, req.Revision, req.Selection); err != nil {
+			log.Printf("Error updating selection in %s: %v", req.Path, err)
+		}
+	})
+
+	return func() {
+		docs.LeaveAll(clientId)
+	}
+}
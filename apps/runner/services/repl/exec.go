@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"runner/pkg/command"
+	"runner/pkg/ws"
+)
+
+// registerCommandHandlers adds the command events. exec runs a command and
+// answers with execStarted, then streams execOutput and ends with execExit
+// carrying the exit code, or with execExit alone when the execId is already
+// running. The returned func cancels the commands still running, when the
+// client disconnects.
+func registerCommandHandlers(ws *ws.WSHandler, commands *command.Manager) (cancel func()) {
+	var mu sync.Mutex
+	running := make(map[string]bool)
+
+	OnTyped(ws, "exec", func(req ExecRequest) {
+		if req.ExecId == "" {
+			req.ExecId = command.NewId()
+		}
+		mu.Lock()
+		if running[req.ExecId] {
+			mu.Unlock()
+			ws.Emit("execExit", map[string]any{
+				"execId": req.ExecId,
+				"result": command.Result{ExitCode: -1},
+				"error":  command.ErrAlreadyRunning.Error(),
+			})
+			return
+		}
+		running[req.ExecId] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(running, req.ExecId)
+			mu.Unlock()
+		}()
+
+		ws.Emit("execStarted", map[string]any{"execId": req.ExecId})
+		result, err := commands.Run(context.Background(), req.ExecId, req.Request, func(stream string, data []byte) {
+			ws.Emit("execOutput", map[string]any{"execId": req.ExecId, "stream": stream, "data": string(data)})
+		})
+
+		exit := map[string]any{"execId": req.ExecId, "result": result}
+		if err != nil {
+			log.Printf("Error running command %s: %v", req.ExecId, err)
+			exit["error"] = err.Error()
+		}
+		ws.Emit("execExit", exit)
+	})
+
+	OnTyped(ws, "cancelExec", func(req ExecCancelRequest) {
+		mu.Lock()
+		ours := running[req.ExecId]
+		mu.Unlock()
+		if ours {
+			commands.Cancel(req.ExecId)
+		}
+	})
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for id := range running {
+			commands.Cancel(id)
+		}
+	}
+}
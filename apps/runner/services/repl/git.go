@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"context"
+	"log"
+
+	"runner/pkg/git"
+	"runner/pkg/ws"
+)
+
+// registerGitHandlers adds the source control events. Every event answers
+// with <event>Response, and the ones changing the repository also emit a
+// fresh gitStatusResponse so the panel stays in sync.
+func registerGitHandlers(ws *ws.WSHandler, repo *git.Repo) {
+	ctx := context.Background()
+
+	emitStatus := func() {
+		status, err := repo.Status(ctx)
+		if err != nil {
+			log.Printf("Error reading git status: %v", err)
+			ws.Emit("gitStatusResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitStatusResponse", map[string]any{"status": status})
+	}
+
+	ws.On("gitStatus", func(data any) {
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitInit", func(req GitInitRequest) {
+		if err := repo.Init(ctx, req.Branch); err != nil {
+			log.Printf("Error initializing git repository: %v", err)
+			ws.Emit("gitInitResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitInitResponse", map[string]any{"success": true})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitDiff", func(req GitDiffRequest) {
+		diff, err := repo.Diff(ctx, req.Path, req.Staged)
+		if err != nil {
+			log.Printf("Error diffing %s: %v", req.Path, err)
+			ws.Emit("gitDiffResponse", map[string]any{"error": err.Error(), "path": req.Path})
+			return
+		}
+		ws.Emit("gitDiffResponse", map[string]any{"diff": diff})
+	})
+
+	OnTyped(ws, "gitStage", func(req GitStageRequest) {
+		if err := repo.Stage(ctx, req.Paths); err != nil {
+			log.Printf("Error staging: %v", err)
+			ws.Emit("gitStageResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitStageResponse", map[string]any{"success": true, "paths": req.Paths})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitUnstage", func(req GitStageRequest) {
+		if err := repo.Unstage(ctx, req.Paths); err != nil {
+			log.Printf("Error unstaging: %v", err)
+			ws.Emit("gitUnstageResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitUnstageResponse", map[string]any{"success": true, "paths": req.Paths})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitCommit", func(req GitCommitRequest) {
+		commit, err := repo.Commit(ctx, req.Message, req.Author)
+		if err != nil {
+			log.Printf("Error committing: %v", err)
+			ws.Emit("gitCommitResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitCommitResponse", map[string]any{"success": true, "commit": commit})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitLog", func(req GitLogRequest) {
+		commits, err := repo.Log(ctx, req.Limit, req.Path)
+		if err != nil {
+			log.Printf("Error reading git log: %v", err)
+			ws.Emit("gitLogResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitLogResponse", map[string]any{"commits": commits, "path": req.Path})
+	})
+
+	OnTyped(ws, "gitBranch", func(req GitBranchRequest) {
+		if req.Name != "" {
+			if err := repo.CreateBranch(ctx, req.Name, req.StartPoint, req.Checkout); err != nil {
+				log.Printf("Error creating branch %s: %v", req.Name, err)
+				ws.Emit("gitBranchResponse", map[string]any{"error": err.Error()})
+				return
+			}
+		}
+
+		branches, err := repo.Branches(ctx)
+		if err != nil {
+			log.Printf("Error listing branches: %v", err)
+			ws.Emit("gitBranchResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitBranchResponse", map[string]any{"branches": branches})
+		if req.Name != "" && req.Checkout {
+			emitStatus()
+		}
+	})
+
+	OnTyped(ws, "gitCheckout", func(req GitCheckoutRequest) {
+		if err := repo.Checkout(ctx, req.Branch); err != nil {
+			log.Printf("Error checking out %s: %v", req.Branch, err)
+			ws.Emit("gitCheckoutResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitCheckoutResponse", map[string]any{"success": true, "branch": req.Branch})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitPush", func(req GitRemoteRequest) {
+		if err := repo.Push(ctx, req.Remote, req.Branch, req.Credentials); err != nil {
+			log.Printf("Error pushing: %v", err)
+			ws.Emit("gitPushResponse", map[string]any{"error": err.Error()})
+			return
+		}
+		ws.Emit("gitPushResponse", map[string]any{"success": true})
+		emitStatus()
+	})
+
+	OnTyped(ws, "gitPull", func(req GitRemoteRequest) {
+		if err := repo.Pull(ctx, req.Remote, req.Branch, req.Rebase, req.Credentials); err != nil {
+			log.Printf("Error pulling: %v", err)
+			ws.Emit("gitPullResponse", map[string]any{"error": err.Error()})
+			// A failed pull may leave conflicts to resolve
+			emitStatus()
+			return
+		}
+		ws.Emit("gitPullResponse", map[string]any{"success": true})
+		emitStatus()
+	})
+}
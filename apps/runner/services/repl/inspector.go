@@ -0,0 +1,47 @@
+package repl
+
+import (
+	"context"
+	"log"
+
+	"runner/pkg/inspector"
+	"runner/pkg/ws"
+)
+
+// registerInspectorHandlers adds the events of the request inspector. Every
+// client is pushed requestCaptured with the summary of each request while
+// capturing is on.
+func registerInspectorHandlers(ws *ws.WSHandler, requests *inspector.Inspector) {
+	OnTyped(ws, "inspectRequests", func(req InspectRequestsRequest) {
+		requests.SetEnabled(req.Enabled)
+		ws.Emit("inspectRequestsResponse", map[string]any{"enabled": requests.Enabled()})
+	})
+
+	OnTyped(ws, "listRequests", func(req ListRequestsRequest) {
+		ws.Emit("listRequestsResponse", map[string]any{"enabled": requests.Enabled(), "requests": requests.List(req.Since)})
+	})
+
+	OnTyped(ws, "getRequest", func(req CapturedRequest) {
+		exchange, err := requests.Get(req.Id)
+		if err != nil {
+			ws.Emit("getRequestResponse", map[string]any{"error": err.Error(), "id": req.Id})
+			return
+		}
+		ws.Emit("getRequestResponse", map[string]any{"request": exchange})
+	})
+
+	OnTyped(ws, "replayRequest", func(req CapturedRequest) {
+		exchange, err := requests.Replay(context.Background(), req.Id)
+		if err != nil {
+			log.Printf("Error replaying request %d: %v", req.Id, err)
+			ws.Emit("replayRequestResponse", map[string]any{"error": err.Error(), "id": req.Id})
+			return
+		}
+		ws.Emit("replayRequestResponse", map[string]any{"request": exchange})
+	})
+
+	ws.On("clearRequests", func(data any) {
+		requests.Clear()
+		ws.Emit("clearRequestsResponse", map[string]any{"success": true})
+	})
+}
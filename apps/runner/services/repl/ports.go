@@ -0,0 +1,27 @@
+package repl
+
+import (
+	"log"
+
+	"runner/pkg/ports"
+	"runner/pkg/ws"
+)
+
+// registerPortHandlers adds the events of the ports to preview. Ports opening
+// and closing are pushed to every client as portOpened and portClosed.
+func registerPortHandlers(ws *ws.WSHandler, monitor *ports.Monitor) {
+	ws.On("listPorts", func(data any) {
+		ws.Emit("listPortsResponse", map[string]any{"ports": monitor.List()})
+	})
+
+	// Every client gets portUpdated as well
+	OnTyped(ws, "updatePort", func(req UpdatePortRequest) {
+		port, err := monitor.Update(req.Port, req.Settings)
+		if err != nil {
+			log.Printf("Error updating port %d: %v", req.Port, err)
+			ws.Emit("updatePortResponse", map[string]any{"error": err.Error(), "port": req.Port})
+			return
+		}
+		ws.Emit("updatePortResponse", map[string]any{"success": true, "port": port})
+	})
+}
@@ -3,39 +3,106 @@ package repl
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strings"
-	"sync"
 
+	"packages/replauth"
+	"runner/pkg/collab"
+	"runner/pkg/command"
 	"runner/pkg/fs"
+	"runner/pkg/git"
+	"runner/pkg/inspector"
+	"runner/pkg/ports"
 	"runner/pkg/pty"
 	"runner/pkg/shutdown"
+	"runner/pkg/supervisor"
+	"runner/pkg/watcher"
 	"runner/pkg/ws"
 )
 
-var (
-	ptyManager *pty.PTYManager
-	once       sync.Once
-)
-
-// Existing request structures (assumed)
-func getPTYManager() *pty.PTYManager {
-	once.Do(func() {
-		ptyManager = pty.NewPTYManager()
-	})
-	return ptyManager
+// Services are shared by every editor connection of the repl
+type Services struct {
+	// Workspace resolves every path sent by clients. It is shared so that
+	// edits from several clients are merged against the versions each of
+	// them loaded.
+	Workspace *fs.Workspace
+	// Watcher may be nil, clients then get no fsChanged events
+	Watcher *watcher.Watcher
+	// Docs are the files opened as shared documents
+	Docs *collab.Sessions
+	// Terminals outlive the connections, clients reattach to them
+	Terminals *pty.PTYManager
+	// Commands run without a terminal
+	Commands *command.Manager
+	// App is the user's app, run with the Run/Stop button
+	App *supervisor.Supervisor
+	// Ports are the ports the user's processes listen on
+	Ports *ports.Monitor
+	// Requests to the user's app, captured while inspecting
+	Requests *inspector.Inspector
+	// Tokens may be nil, connections are then not bound to a token
+	Tokens *replauth.Verifier
 }
 
-func NewHandler(sm *shutdown.ShutdownManager) http.Handler {
+// NewHandler serves the editor WebSocket
+func NewHandler(sm *shutdown.ShutdownManager, services Services) http.Handler {
 	mux := http.NewServeMux()
+	hub := ws.NewHub()
+
+	// Changes made outside of the editor (terminal, git, other clients)
+	if services.Watcher != nil {
+		services.Watcher.Subscribe(func(batch watcher.Batch) {
+			hub.Broadcast("fsChanged", batch)
+			// Shared documents take the changes in, instead of overwriting
+			// them with their next save
+			if batch.Overflow {
+				go services.Docs.Flush()
+				return
+			}
+			var paths []string
+			for _, change := range batch.Changes {
+				if change.Type != watcher.Deleted && !change.IsDir {
+					paths = append(paths, change.Path)
+				}
+			}
+			if len(paths) > 0 {
+				go services.Docs.Reload(paths)
+			}
+		})
+	}
+
+	// Output and status of the user's app
+	services.App.Subscribe(func(event supervisor.Event) {
+		switch event.Type {
+		case supervisor.EventLog:
+			hub.Broadcast("appLog", event.Log)
+		case supervisor.EventStatus:
+			hub.Broadcast("appStatus", event.Status)
+		}
+	})
+
+	// Ports to preview, as soon as a server listens
+	services.Ports.Subscribe(func(event ports.Event) {
+		switch event.Type {
+		case ports.EventOpened:
+			hub.Broadcast("portOpened", event.Port)
+		case ports.EventClosed:
+			hub.Broadcast("portClosed", event.Port)
+		case ports.EventUpdated:
+			hub.Broadcast("portUpdated", event.Port)
+		}
+	})
+
+	// Requests to the user's app, fetched in full with getRequest
+	services.Requests.Subscribe(func(exchange inspector.Exchange) {
+		hub.Broadcast("requestCaptured", exchange.Summary())
+	})
+
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		wsHandler := ws.NewWSHandler(strings.Split(r.Host, ".")[0], sm)
-		ptyManager = getPTYManager()
-		defer ptyManager.Cleanup()
-		handleWs(w, r, wsHandler, ptyManager)
+		handleWs(w, r, wsHandler, hub, services)
 	})
 	return mux
 }
@@ -49,14 +116,43 @@ func generateSessionID() string {
 	return hex.EncodeToString(bytes)
 }
 
-func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManager *pty.PTYManager) {
+// identity is who the client is, shown to the other clients. The user of the
+// access token wins over the userId the client says it is in the query, e.g.
+// ?userId=...&name=...
+func identity(r *http.Request) ws.Identity {
+	identity := ws.Identity{
+		UserId: r.URL.Query().Get("userId"),
+		Name:   r.URL.Query().Get("name"),
+	}
+	if claims := replauth.FromContext(r.Context()); claims != nil {
+		identity.UserId = claims.User()
+	}
+	return identity
+}
+
+func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, hub *ws.Hub, services Services) {
 	if err := ws.Init(w, r); err != nil {
 		log.Printf("Failed to initialize websocket: %v", err)
 		return
 	}
+	workspace := services.Workspace
+
+	clientId := generateSessionID()
+	hub.Add(clientId, ws, identity(r))
+
+	// Presence of the other clients
+	OnTyped(ws, "viewFile", func(req ViewFileRequest) {
+		hub.SetViewing(clientId, req.Path)
+	})
+	ws.On("listClients", func(data any) {
+		ws.Emit("listClientsResponse", map[string]any{"clients": hub.Clients()})
+	})
+
+	// Shared documents, edited live with the other clients
+	leaveDocuments := registerCollabHandlers(ws, services.Docs, clientId)
 
 	ws.On("Connection", func(data any) {
-		rootContents, err := fs.FetchDir("/workspaces", "")
+		rootContents, err := workspace.FetchDir("")
 		if err != nil {
 			ws.Emit("error", map[string]any{"message": "Failed to load directory"})
 			return
@@ -68,7 +164,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 
 	// File Tree Actions
 	OnTyped(ws, "fetchDir", func(req FetchDirRequest) {
-		contents, err := fs.FetchDir("/workspaces", req.Dir)
+		contents, err := workspace.FetchDir(req.Dir)
 		if err != nil {
 			log.Printf("Error fetching directory: %v", err)
 			ws.Emit("fetchDirResponse", map[string]any{"error": err.Error()})
@@ -78,30 +174,39 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "fetchContent", func(req FetchContentRequest) {
-		fullPath := fmt.Sprintf("/workspaces/%s", req.Path)
-		data, err := fs.FetchFileContent(fullPath)
+		data, version, err := workspace.FetchFileContent(req.Path)
 		if err != nil {
 			log.Printf("Error fetching file content: %v", err)
 			ws.Emit("fetchContentResponse", map[string]any{"error": err.Error()})
 			return
 		}
-		ws.Emit("fetchContentResponse", map[string]string{"content": data, "path": req.Path})
+		ws.Emit("fetchContentResponse", map[string]string{"content": data, "path": req.Path, "version": version})
 	})
 
 	OnTyped(ws, "updateContent", func(req UpdateContentRequest) {
-		fullPath := fmt.Sprintf("/workspaces/%s", req.Path)
-		err := fs.SaveFileDiffs(fullPath, req.Patch)
+		result, err := workspace.SaveFileDiffs(req.Path, req.Patch, req.BaseVersion)
+		var conflict *fs.ConflictError
+		if errors.As(err, &conflict) {
+			log.Printf("Conflicting save of %s", req.Path)
+			ws.Emit("updateContentResponse", map[string]any{"error": err.Error(), "path": req.Path, "conflict": conflict})
+			return
+		}
 		if err != nil {
 			log.Printf("Error saving file: %v", err)
-			ws.Emit("updateContentResponse", map[string]any{"error": err.Error()})
+			ws.Emit("updateContentResponse", map[string]any{"error": err.Error(), "path": req.Path})
 			return
 		}
-		ws.Emit("updateContentResponse", map[string]any{"success": true})
+		ws.Emit("updateContentResponse", map[string]any{
+			"success": true,
+			"path":    req.Path,
+			"version": result.Version,
+			"merged":  result.Merged,
+			"content": result.Content,
+		})
 	})
 
 	OnTyped(ws, "createFile", func(req CreateFileRequest) {
-		fullPath := filepath.Join("/workspaces", req.Path)
-		err := fs.CreateFile(fullPath)
+		err := workspace.CreateFile(req.Path)
 		if err != nil {
 			log.Printf("Error creating file: %v", err)
 			ws.Emit("createFileResponse", map[string]any{"error": err.Error()})
@@ -111,8 +216,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "createFolder", func(req CreateFolderRequest) {
-		fullPath := filepath.Join("/workspaces", req.Path)
-		err := fs.CreateFolder(fullPath)
+		err := workspace.CreateFolder(req.Path)
 		if err != nil {
 			log.Printf("Error creating folder: %v", err)
 			ws.Emit("createFolderResponse", map[string]any{"error": err.Error()})
@@ -122,8 +226,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "delete", func(req DeleteRequest) {
-		fullPath := filepath.Join("/workspaces", req.Path)
-		err := fs.Delete(fullPath)
+		err := workspace.Delete(req.Path)
 		if err != nil {
 			log.Printf("Error deleting: %v", err)
 			ws.Emit("deleteResponse", map[string]any{"error": err.Error()})
@@ -133,9 +236,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "rename", func(req RenameRequest) {
-		oldFullPath := filepath.Join("/workspaces", req.OldPath)
-		newFullPath := filepath.Join("/workspaces", req.NewPath)
-		err := fs.Rename(oldFullPath, newFullPath)
+		err := workspace.Rename(req.OldPath, req.NewPath)
 		if err != nil {
 			log.Printf("Error renaming: %v", err)
 			ws.Emit("renameResponse", map[string]any{"error": err.Error()})
@@ -149,9 +250,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "copy", func(req CopyRequest) {
-		sourceFullPath := filepath.Join("/workspaces", req.SourcePath)
-		targetFullPath := filepath.Join("/workspaces", req.TargetPath)
-		err := fs.Copy(sourceFullPath, targetFullPath)
+		err := workspace.Copy(req.SourcePath, req.TargetPath)
 		if err != nil {
 			log.Printf("Error copying: %v", err)
 			ws.Emit("copyResponse", map[string]any{"error": err.Error()})
@@ -165,8 +264,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "cut", func(req CutRequest) {
-		sourceFullPath := filepath.Join("/workspaces", req.SourcePath)
-		err := fs.Cut(sourceFullPath)
+		err := workspace.Cut(req.SourcePath)
 		if err != nil {
 			log.Printf("Error cutting: %v", err)
 			ws.Emit("cutResponse", map[string]any{"error": err.Error()})
@@ -179,8 +277,7 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 	})
 
 	OnTyped(ws, "paste", func(req PasteRequest) {
-		targetFullPath := filepath.Join("/workspaces", req.TargetPath)
-		err := fs.Paste(targetFullPath)
+		err := workspace.Paste(req.TargetPath)
 		if err != nil {
 			log.Printf("Error pasting: %v", err)
 			ws.Emit("pasteResponse", map[string]any{"error": err.Error()})
@@ -192,53 +289,27 @@ func handleWs(w http.ResponseWriter, r *http.Request, ws *ws.WSHandler, ptyManag
 		})
 	})
 
-	// Terminal Actions
-	ws.On("requestTerminal", func(data any) {
-		sessionID := generateSessionID()
-		if sessionID == "" {
-			ws.Emit("terminalError", map[string]string{"error": "Failed to generate session ID"})
-			return
-		}
-
-		session, err := ptyManager.CreateSession(sessionID, nil)
-		if err != nil {
-			ws.Emit("terminalError", map[string]string{"error": "Failed to create terminal session"})
-			return
-		}
-
-		ws.Emit("terminalConnected", map[string]string{"sessionId": sessionID})
+	// Source Control Actions
+	registerGitHandlers(ws, git.New(workspace))
 
-		session.SetOnDataCallback(func(data []byte) {
-			ws.Emit("terminalResponse", string(data))
-		})
+	// Terminal Actions
+	detachTerminals := registerTerminalHandlers(ws, services.Terminals, clientId)
 
-		session.SetOnCloseCallback(func() {
-			ws.Emit("terminalClosed", nil)
-			ptyManager.RemoveSession(sessionID)
-		})
-	})
+	// Command Actions
+	cancelCommands := registerCommandHandlers(ws, services.Commands)
 
-	OnTyped(ws, "closeTerminal", func(req TerminalCloseRequest) {
-		session, exists := ptyManager.GetSession(req.SessionID)
-		if !exists {
-			return
-		}
-		session.Close()
-	})
+	// App Actions
+	registerAppHandlers(ws, services.App)
+	registerPortHandlers(ws, services.Ports)
+	registerInspectorHandlers(ws, services.Requests)
 
-	OnTyped(ws, "terminalInput", func(req TerminalDataRequest) {
-		session, exists := ptyManager.GetSession(req.SessionID)
-		if !exists {
-			return
-		}
-		session.WriteString(req.Data)
-	})
+	// Access token of the connection
+	stopTokenCheck := registerAuthHandlers(ws, services.Tokens, replauth.FromContext(r.Context()))
 
-	OnTyped(ws, "terminalResize", func(req TerminalResizeRequest) {
-		session, exists := ptyManager.GetSession(req.SessionID)
-		if !exists {
-			return
-		}
-		session.Resize(req.Cols, req.Rows)
+	ws.On("disconnect", func(data any) {
+		stopTokenCheck()
+		leaveDocuments()
+		detachTerminals()
+		cancelCommands()
 	})
 }
@@ -0,0 +1,105 @@
+package repl
+
+import (
+	"log"
+
+	"runner/pkg/pty"
+	"runner/pkg/ws"
+)
+
+// registerTerminalHandlers adds the terminal events. Terminals keep running
+// when the connection drops, for the grace period of terminals, and any
+// client can attach to them with attachTerminal to get their scrollback and
+// output. The returned func detaches the client from its terminals.
+func registerTerminalHandlers(ws *ws.WSHandler, terminals *pty.PTYManager, clientId string) (detach func()) {
+	attach := func(session *pty.PTYSession) ([]byte, error) {
+		sessionID := session.ID
+		return session.Attach(clientId, pty.Listener{
+			OnData: func(data []byte) {
+				ws.Emit("terminalResponse", string(data))
+			},
+			OnClose: func() {
+				ws.Emit("terminalClosed", map[string]string{"sessionId": sessionID})
+			},
+		})
+	}
+
+	ws.On("requestTerminal", func(data any) {
+		sessionID := generateSessionID()
+		if sessionID == "" {
+			ws.Emit("terminalError", map[string]string{"error": "Failed to generate session ID"})
+			return
+		}
+
+		session, err := terminals.CreateSession(sessionID, nil)
+		if err != nil {
+			ws.Emit("terminalError", map[string]string{"error": "Failed to create terminal session"})
+			return
+		}
+
+		session.SetOnCloseCallback(func() {
+			terminals.RemoveSession(sessionID)
+		})
+
+		if _, err := attach(session); err != nil {
+			ws.Emit("terminalError", map[string]string{"error": err.Error()})
+			return
+		}
+		ws.Emit("terminalConnected", map[string]string{"sessionId": sessionID})
+	})
+
+	ws.On("listTerminals", func(data any) {
+		ws.Emit("listTerminalsResponse", map[string]any{"terminals": terminals.List()})
+	})
+
+	OnTyped(ws, "attachTerminal", func(req TerminalAttachRequest) {
+		session, exists := terminals.GetSession(req.SessionID)
+		if !exists {
+			ws.Emit("attachTerminalResponse", map[string]any{"error": "terminal not found", "sessionId": req.SessionID})
+			return
+		}
+		scrollback, err := attach(session)
+		if err != nil {
+			log.Printf("Error attaching to terminal %s: %v", req.SessionID, err)
+			ws.Emit("attachTerminalResponse", map[string]any{"error": err.Error(), "sessionId": req.SessionID})
+			return
+		}
+		ws.Emit("attachTerminalResponse", map[string]any{"sessionId": req.SessionID, "scrollback": string(scrollback)})
+	})
+
+	OnTyped(ws, "detachTerminal", func(req TerminalAttachRequest) {
+		session, exists := terminals.GetSession(req.SessionID)
+		if !exists {
+			return
+		}
+		session.Detach(clientId)
+	})
+
+	OnTyped(ws, "closeTerminal", func(req TerminalCloseRequest) {
+		session, exists := terminals.GetSession(req.SessionID)
+		if !exists {
+			return
+		}
+		session.Close()
+	})
+
+	OnTyped(ws, "terminalInput", func(req TerminalDataRequest) {
+		session, exists := terminals.GetSession(req.SessionID)
+		if !exists {
+			return
+		}
+		session.WriteString(req.Data)
+	})
+
+	OnTyped(ws, "terminalResize", func(req TerminalResizeRequest) {
+		session, exists := terminals.GetSession(req.SessionID)
+		if !exists {
+			return
+		}
+		session.Resize(req.Cols, req.Rows)
+	})
+
+	return func() {
+		terminals.DetachAll(clientId)
+	}
+}
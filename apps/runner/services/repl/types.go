@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"log"
 
+	"runner/pkg/collab"
+	"runner/pkg/command"
+	"runner/pkg/git"
+	"runner/pkg/ports"
 	"runner/pkg/ws"
 )
 
@@ -18,6 +22,32 @@ type FetchContentRequest struct {
 type UpdateContentRequest struct {
 	Path  string `json:"path"`
 	Patch string `json:"patch"`
+	// BaseVersion is the version the patch was made against, from
+	// fetchContentResponse or the previous updateContentResponse
+	BaseVersion string `json:"baseVersion"`
+}
+
+type ViewFileRequest struct {
+	// Path is the file the client is viewing, "" when none
+	Path string `json:"path"`
+}
+
+type OpenDocumentRequest struct {
+	Path string `json:"path"`
+}
+
+type DocumentOperationRequest struct {
+	Path string `json:"path"`
+	// Revision is the document revision the operation was made against
+	Revision  int               `json:"revision"`
+	Operation collab.Operation  `json:"operation"`
+	Selection *collab.Selection `json:"selection"`
+}
+
+type DocumentSelectionRequest struct {
+	Path      string           `json:"path"`
+	Revision  int              `json:"revision"`
+	Selection collab.Selection `json:"selection"`
 }
 
 type CreateFolderRequest struct {
@@ -60,12 +90,98 @@ type TerminalCloseRequest struct {
 	SessionID string `json:"sessionId"`
 }
 
+type TerminalAttachRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
 type TerminalResizeRequest struct {
 	Cols      int    `json:"cols"`
 	Rows      int    `json:"rows"`
 	SessionID string `json:"sessionId"`
 }
 
+type ExecRequest struct {
+	// ExecId names the command in its events and to cancel it, one is made
+	// up when empty
+	ExecId string `json:"execId"`
+	command.Request
+}
+
+type ExecCancelRequest struct {
+	ExecId string `json:"execId"`
+}
+
+type GitInitRequest struct {
+	Branch string `json:"branch"`
+}
+
+type GitDiffRequest struct {
+	Path   string `json:"path"`
+	Staged bool   `json:"staged"`
+}
+
+type GitStageRequest struct {
+	// Paths to stage, everything when empty
+	Paths []string `json:"paths"`
+}
+
+type GitCommitRequest struct {
+	Message string     `json:"message"`
+	Author  git.Author `json:"author"`
+}
+
+type GitLogRequest struct {
+	Limit int    `json:"limit"`
+	Path  string `json:"path"`
+}
+
+type GitBranchRequest struct {
+	// Name creates a branch, the branches are only listed when empty
+	Name       string `json:"name"`
+	StartPoint string `json:"startPoint"`
+	Checkout   bool   `json:"checkout"`
+}
+
+type GitCheckoutRequest struct {
+	Branch string `json:"branch"`
+}
+
+type GitRemoteRequest struct {
+	Remote      string          `json:"remote"`
+	Branch      string          `json:"branch"`
+	Rebase      bool            `json:"rebase"`
+	Credentials git.Credentials `json:"credentials"`
+}
+
+type AppLogsRequest struct {
+	// Since is the seq of the last line the client has, 0 for all of them
+	Since int64 `json:"since"`
+	// Limit keeps the last lines only, when positive
+	Limit int `json:"limit"`
+}
+
+type UpdatePortRequest struct {
+	Port int `json:"port"`
+	ports.Settings
+}
+
+type InspectRequestsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ListRequestsRequest struct {
+	// Since is the id of the last request the client has, 0 for all of them
+	Since int64 `json:"since"`
+}
+
+type CapturedRequest struct {
+	Id int64 `json:"id"`
+}
+
+type RefreshTokenRequest struct {
+	Token string `json:"token"`
+}
+
 // OnTyped registers a strongly-typed event handler
 func OnTyped[T any](ws *ws.WSHandler, event string, handler func(T)) {
 	ws.On(event, func(data any) {
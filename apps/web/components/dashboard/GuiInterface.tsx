@@ -61,7 +61,7 @@ const GuiInterface: React.FC<ReplDashboardProps> = ({
       setLoading(true);
       const replList = await getRepls();
       setRepls(
-        replList.sort((a, b) => (b.isActive ? 1 : 0) - (a.isActive ? 1 : 0)),
+        replList.sort((a, b) => ((b.state === "running") ? 1 : 0) - ((a.state === "running") ? 1 : 0)),
       );
     } catch (error) {
       console.error("Error loading repls:", error);
@@ -234,7 +234,7 @@ const GuiInterface: React.FC<ReplDashboardProps> = ({
             <div className="divide-y divide-gray-800">
               {filteredRepls.map((repl) => {
                 const template = getTemplateFromRepl(repl);
-                const isActive = repl.isActive;
+                const isActive = repl.state === "running";
 
                 return (
                   <div
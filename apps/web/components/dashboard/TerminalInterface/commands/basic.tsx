@@ -165,7 +165,7 @@ export const lsCommand: Command = {
 
       // Filter repls based on --active flag
       const repls = options.active
-        ? allRepls.filter((repl) => repl.isActive)
+        ? allRepls.filter((repl) => repl.state === "running")
         : allRepls;
 
       if (repls.length === 0) {
@@ -189,8 +189,8 @@ export const lsCommand: Command = {
 
       // Sort repls: active ones first, then inactive
       const sortedRepls = [...repls].sort((a, b) => {
-        const aActive = a.isActive ? 1 : 0;
-        const bActive = b.isActive ? 1 : 0;
+        const aActive = (a.state === "running") ? 1 : 0;
+        const bActive = (b.state === "running") ? 1 : 0;
         return bActive - aActive;
       });
 
@@ -202,7 +202,7 @@ export const lsCommand: Command = {
 
         const replList = sortedRepls
           .map((repl) => {
-            const isActive = repl.isActive;
+            const isActive = repl.state === "running";
             const statusIcon = isActive ? "🟢" : "⚪";
             const statusText = isActive ? "Running" : "Stopped";
             const actionCommand = isActive
@@ -229,7 +229,7 @@ export const lsCommand: Command = {
 
       const replList = sortedRepls
         .map((repl, i) => {
-          const isActive = repl.isActive;
+          const isActive = repl.state === "running";
           const statusIcon = isActive ? "🟢" : "⚪";
           const link = isActive
             ? ` - <a href="/repl/${repl.id}" class="text-blue-400 underline">Open</a>`
@@ -314,7 +314,7 @@ export const statusCommand: Command = {
   execute: async (args, options, context) => {
     const uptime = Math.floor(Math.random() * 72) + 1;
     const repls = await context.getRepls();
-    const activeRepls = repls.filter((repl) => repl.isActive);
+    const activeRepls = repls.filter((repl) => repl.state === "running");
     return `╭─────────────────────────────────────────╮
 │      📈 SYSTEM VITAL SIGNS 📈       │
 ╰─────────────────────────────────────────╯
@@ -157,7 +157,7 @@ export const replCommand: Command = {
 
           // Filter repls based on --active flag
           const repls = options.active
-            ? allRepls.filter((repl) => repl.isActive)
+            ? allRepls.filter((repl) => repl.state === "running")
             : allRepls;
 
           if (repls.length === 0) {
@@ -169,8 +169,8 @@ export const replCommand: Command = {
 
           // Sort repls: active ones first, then inactive
           const sortedRepls = [...repls].sort((a, b) => {
-            const aActive = a.isActive ? 1 : 0;
-            const bActive = b.isActive ? 1 : 0;
+            const aActive = (a.state === "running") ? 1 : 0;
+            const bActive = (b.state === "running") ? 1 : 0;
             return bActive - aActive;
           });
 
@@ -182,7 +182,7 @@ export const replCommand: Command = {
 
             const replList = sortedRepls
               .map((repl) => {
-                const isActive = repl.isActive;
+                const isActive = repl.state === "running";
                 const statusIcon = isActive ? "🟢" : "⚪";
                 const statusText = isActive ? "Running" : "Stopped";
                 const actionCommand = isActive
@@ -206,7 +206,7 @@ export const replCommand: Command = {
 
           const replList = sortedRepls
             .map((repl, i) => {
-              const isActive = repl.isActive;
+              const isActive = repl.state === "running";
               const statusIcon = isActive ? "🟢" : "⚪";
               const link = isActive
                 ? ` - <a href="/repl/${repl.id}" class="text-blue-400 underline">Open</a>`
@@ -253,15 +253,15 @@ export const replCommand: Command = {
 
           // Sort matches: active ones first
           const sortedMatches = [...matches].sort((a, b) => {
-            const aActive = a.isActive ? 1 : 0;
-            const bActive = b.isActive ? 1 : 0;
+            const aActive = (a.state === "running") ? 1 : 0;
+            const bActive = (b.state === "running") ? 1 : 0;
             return bActive - aActive;
           });
 
           const header = `╭─────────────────────────────────────────╮\n│             SEARCH RESULTS              │\n╰─────────────────────────────────────────╯`;
           const resultList = sortedMatches
             .map((repl, i) => {
-              const isActive = repl.isActive;
+              const isActive = repl.state === "running";
               const statusIcon = isActive ? "🟢" : "⚪";
               return `${i + 1}. ${statusIcon} 📁 ${repl.name} (${repl.id})`;
             })
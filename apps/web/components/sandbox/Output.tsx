@@ -5,6 +5,7 @@ import { Copy, ExternalLink, Play } from "lucide-react";
 import { Button } from "../ui/button";
 import { Input } from "../ui/input";
 import Link from "next/link";
+import { CoreService } from "@/lib/core";
 
 const URLConverter = ({
   className,
@@ -36,8 +37,19 @@ const URLConverter = ({
     }
   };
 
-  const handleOpenInNewTab = () => {
-    window.open(convertedUrl, "_blank");
+  // Ports are private, the preview takes the repl token once and keeps it
+  // in a cookie
+  const handleOpenInNewTab = async () => {
+    const preview = window.open("", "_blank");
+    try {
+      const { token } = await CoreService.getInstance().getReplToken(replId);
+      const url = new URL(convertedUrl);
+      url.searchParams.set("token", token);
+      if (preview) preview.location.href = url.toString();
+    } catch (err) {
+      console.error("Failed to get the repl token:", err);
+      preview?.close();
+    }
   };
 
   return (
@@ -1,4 +1,5 @@
 import { useEffect, useRef, useState } from "react";
+import { CoreService, REPL_TOKEN_REFRESH_MARGIN } from "@/lib/core";
 
 export type Events = {
   Loaded: (data: { rootContents: any }) => void;
@@ -16,6 +17,8 @@ export type Events = {
   createFileResponse: (data: any) => void;
   cutResponse: (data: any) => void;
   pasteResponse: (data: any) => void;
+  refreshTokenResponse: (data: any) => void;
+  tokenExpired: (data: any) => void;
   error: (data: any) => void;
 };
 
@@ -37,9 +40,50 @@ export function useRunnerSocket(replId: string) {
   const listenersRef = useRef<Record<string, EventHandler[]>>({});
 
   useEffect(() => {
+    const core = CoreService.getInstance();
+    let socket: Socket | null = null;
+    let refreshTimer: ReturnType<typeof setTimeout> | undefined;
+    let closed = false;
+
+    // The connection takes a new token before its own expires
+    const scheduleRefresh = (expiresAt: number) => {
+      refreshTimer = setTimeout(
+        async () => {
+          try {
+            const next = await core.getReplToken(replId);
+            socket?.emit?.("refreshToken", { token: next.token });
+            scheduleRefresh(next.expiresAt);
+          } catch (err) {
+            console.error("❌ Failed to refresh the repl token:", err);
+          }
+        },
+        Math.max(expiresAt - Date.now() - REPL_TOKEN_REFRESH_MARGIN, 0),
+      );
+    };
+
+    core
+      .getReplToken(replId)
+      .then(({ token, expiresAt }) => {
+        if (closed) return;
+        socket = connect(token);
+        scheduleRefresh(expiresAt);
+      })
+      .catch((err) => {
+        console.error("❌ Failed to get the repl token:", err);
+      });
+
+    return () => {
+      closed = true;
+      clearTimeout(refreshTimer);
+      socket?.close();
+      socketRef.current = null;
+      listenersRef.current = {};
+    };
+  }, [replId]);
+
+  const connect = (token: string): Socket => {
     const protocol = window.location.protocol === "https:" ? "wss" : "ws";
-    const url = `${protocol}://${process.env.NEXT_PUBLIC_RUNNER_DOMAIN_NAME}/${replId}/api/v1/repl/ws`;
-    const testingUrl = "ws://localhost:8081/api/v1/repl/ws";
+    const url = `${protocol}://${process.env.NEXT_PUBLIC_RUNNER_DOMAIN_NAME}/${replId}/api/v1/repl/ws?token=${encodeURIComponent(token)}`;
 
     const socket: Socket = new WebSocket(url);
 
@@ -88,13 +132,8 @@ export function useRunnerSocket(replId: string) {
     });
 
     socketRef.current = socket;
-
-    return () => {
-      socket.close();
-      socketRef.current = null;
-      listenersRef.current = {};
-    };
-  }, [replId]);
+    return socket;
+  };
 
   // Proxy functions (typed)
   const emit = (event: string, payload?: any) => {
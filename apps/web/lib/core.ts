@@ -1,12 +1,18 @@
 import { AuthStatus, User } from "@/types/auth";
-import { StoredRepl } from "@/types/dashboard";
+import { ReplProgressEvent, StoredRepl } from "@/types/dashboard";
 import axios from "axios";
 
 const API_BASE_URL =
   process.env.NEXT_PUBLIC_CORE_API_URL || "http://localhost:8080";
 
+// Repl tokens are refreshed this long before they expire
+export const REPL_TOKEN_REFRESH_MARGIN = 2 * 60 * 1000;
+
+export type ReplToken = { token: string; expiresAt: number };
+
 export class CoreService {
   private static instance: CoreService;
+  private replTokens = new Map<string, ReplToken>();
 
   private url(route: string) {
     return `${API_BASE_URL}${route}`;
@@ -163,7 +169,7 @@ export class CoreService {
 
   async startRepl(replName: string) {
     try {
-      return (
+      const session = (
         await axios.get(this.url(`/api/repl/session/${replName}`), {
           withCredentials: true,
           headers: {
@@ -171,12 +177,80 @@ export class CoreService {
           },
         })
       ).data;
+
+      if (session.token) {
+        this.replTokens.set(replName, {
+          token: session.token,
+          expiresAt: Date.parse(session.expiresAt),
+        });
+      }
+
+      if (session.progress?.step !== "runnerReady") {
+        await this.waitForRepl(replName, session.operationId);
+      }
+      return session;
     } catch (error) {
       console.log("error:", error);
       throw error;
     }
   }
 
+  // Access token of the runner, app previews and mcp server of a repl, a new
+  // one is issued when it is about to expire
+  async getReplToken(replId: string): Promise<ReplToken> {
+    const cached = this.replTokens.get(replId);
+    if (cached && cached.expiresAt - Date.now() > REPL_TOKEN_REFRESH_MARGIN) {
+      return cached;
+    }
+
+    const { token, expiresAt } = (
+      await axios.post(this.url(`/api/repl/session/${replId}/token`), null, {
+        withCredentials: true,
+        headers: {
+          "Content-Type": "application/json",
+        },
+      })
+    ).data;
+    const replToken = { token, expiresAt: Date.parse(expiresAt) };
+    this.replTokens.set(replId, replToken);
+    return replToken;
+  }
+
+  // Follows the activation progress streamed by core until the runner is ready
+  waitForRepl(
+    replName: string,
+    operationId: string,
+    onProgress?: (event: ReplProgressEvent) => void,
+  ): Promise<ReplProgressEvent> {
+    return new Promise((resolve, reject) => {
+      const source = new EventSource(
+        this.url(
+          `/api/repl/session/${replName}/events?operationId=${operationId}`,
+        ),
+        { withCredentials: true },
+      );
+
+      source.addEventListener("progress", (e) => {
+        onProgress?.(JSON.parse((e as MessageEvent).data));
+      });
+
+      source.addEventListener("done", (e) => {
+        source.close();
+        const event: ReplProgressEvent = JSON.parse((e as MessageEvent).data);
+        if (event.step === "failed") {
+          reject(new Error(event.message));
+        } else {
+          resolve(event);
+        }
+      });
+
+      source.onerror = () => {
+        source.close();
+        reject(new Error("Lost connection while starting the repl"));
+      };
+    });
+  }
+
   async deleteReplSession(replName: string) {
     try {
       await axios.delete(this.url(`/api/repl/session/${replName}`), {
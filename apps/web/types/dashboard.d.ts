@@ -5,14 +5,32 @@ export interface Repl {
   createdAt: string;
 }
 
+export type ReplState = "stopped" | "starting" | "running" | "stopping" | "failed";
+
 export interface StoredRepl {
   id: string;
   name: string;
   user: string;
-  isActive: bool;
+  state: ReplState;
+  lastError?: string;
+  stateTimestamps?: Partial<Record<ReplState, string>>;
   templateKey?: string;
 }
 
+export interface ReplProgressEvent {
+  step:
+    | "queued"
+    | "deploymentCreated"
+    | "podScheduled"
+    | "pullingImage"
+    | "containerStarted"
+    | "runnerReady"
+    | "failed";
+  message?: string;
+  progress: number;
+  time: string;
+}
+
 export interface HistoryEntry {
   type: "command" | "output" | "error" | "success" | "info";
   content: string;
@@ -0,0 +1,159 @@
+// Package checkpoint keeps point-in-time copies of a workspace in storage.
+//
+// File contents are stored once per repl as blobs named after their sha256,
+// and every checkpoint is a manifest mapping paths to blobs. Consecutive
+// checkpoints therefore only cost the files that changed in between.
+//
+//	<prefix>/blobs/<sha256>
+//	<prefix>/manifests/<id>.json
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"packages/storage"
+)
+
+var (
+	ErrNotFound     = errors.New("checkpoint not found")
+	ErrPathNotFound = errors.New("path is not part of the checkpoint")
+)
+
+// File is a workspace file as recorded in a checkpoint
+type File struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// Manifest lists the files of the workspace at the time of a checkpoint, by
+// their slash separated path relative to the workspace.
+type Manifest struct {
+	Id        string          `json:"id"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Files     map[string]File `json:"files"`
+}
+
+// Info summarises a checkpoint, it is what the runner reports to core
+type Info struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+	Files     int       `json:"files"`
+}
+
+// NewId returns a checkpoint id that sorts in creation order
+func NewId(at time.Time) string {
+	return at.UTC().Format("20060102T150405.000Z")
+}
+
+func ManifestKey(prefix, id string) string {
+	return path.Join(prefix, "manifests", id+".json")
+}
+
+func BlobKey(prefix, hash string) string {
+	return path.Join(prefix, "blobs", hash)
+}
+
+// ManifestsPrefix is the folder holding the manifests of the checkpoints under prefix
+func ManifestsPrefix(prefix string) string {
+	return path.Join(prefix, "manifests") + "/"
+}
+
+// BlobsPrefix is the folder holding every blob of the checkpoints under prefix
+func BlobsPrefix(prefix string) string {
+	return path.Join(prefix, "blobs") + "/"
+}
+
+func (m Manifest) Info() Info {
+	var size int64
+	for _, file := range m.Files {
+		size += file.Size
+	}
+	return Info{Id: m.Id, CreatedAt: m.CreatedAt, Size: size, Files: len(m.Files)}
+}
+
+// Digest identifies the content of the workspace, ignoring id and time, so
+// that a checkpoint identical to the previous one can be skipped.
+func (m Manifest) Digest() string {
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", p, m.Files[p].Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Select returns the files at or below p, "" (or "/") selecting everything
+func (m Manifest) Select(p string) (map[string]File, error) {
+	p = CleanPath(p)
+	if p == "" {
+		return m.Files, nil
+	}
+
+	files := make(map[string]File)
+	for rel, file := range m.Files {
+		if inScope(rel, p) {
+			files[rel] = file
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPathNotFound, p)
+	}
+	return files, nil
+}
+
+// CleanPath turns a user supplied workspace path into the relative form used
+// by manifests, "" standing for the whole workspace.
+func CleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// inScope reports whether rel is scope itself or a file below it
+func inScope(rel, scope string) bool {
+	return scope == "" || rel == scope || strings.HasPrefix(rel, scope+"/")
+}
+
+func Save(ctx context.Context, s storage.WorkspaceStorage, prefix string, m Manifest) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, ManifestKey(prefix, m.Id), bytes.NewReader(body), int64(len(body)))
+}
+
+func Load(ctx context.Context, s storage.WorkspaceStorage, prefix, id string) (Manifest, error) {
+	// Ids end up in keys, keep them from walking out of the manifests folder
+	if id == "" || strings.ContainsAny(id, "/\\") || strings.Contains(id, "..") {
+		return Manifest{}, ErrNotFound
+	}
+
+	body, err := s.Get(ctx, ManifestKey(prefix, id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Manifest{}, ErrNotFound
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer body.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(body).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("invalid manifest %s: %w", id, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,181 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"packages/storage"
+)
+
+// RestoreToDir brings the files at or below p in dir back to the state of the
+// checkpoint: changed and deleted files are rewritten and files created since
+// are removed. Files already matching the checkpoint are left untouched.
+func RestoreToDir(ctx context.Context, s storage.WorkspaceStorage, prefix string, m Manifest, p, dir string) error {
+	files, err := m.Select(p)
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Clean(dir)
+	for rel, file := range files {
+		if !restorable(rel, file) {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		if hash, err := HashFile(target); err == nil && hash == file.Hash {
+			continue
+		}
+		if err := restoreFile(ctx, s, BlobKey(prefix, file.Hash), target); err != nil {
+			return err
+		}
+	}
+
+	scope := filepath.Join(root, filepath.FromSlash(CleanPath(p)))
+	return filepath.WalkDir(scope, func(file string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// Symlinks are never part of a checkpoint, leave them alone
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if _, ok := files[filepath.ToSlash(rel)]; ok {
+			return nil
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+}
+
+// RestoreToPrefix does what RestoreToDir does, for a workspace that is only
+// in storage (i.e. its repl is not running).
+func RestoreToPrefix(ctx context.Context, s storage.WorkspaceStorage, prefix string, m Manifest, p, dst string) error {
+	files, err := m.Select(p)
+	if err != nil {
+		return err
+	}
+
+	for rel, file := range files {
+		if !restorable(rel, file) {
+			continue
+		}
+		if err := copyBlob(ctx, s, BlobKey(prefix, file.Hash), path.Join(dst, rel), file.Size); err != nil {
+			return err
+		}
+	}
+
+	objects, err := s.List(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	scope := CleanPath(p)
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, dst)
+		if !inScope(rel, scope) {
+			continue
+		}
+		if _, ok := files[rel]; ok {
+			continue
+		}
+		if err := s.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restorable reports whether a file of a manifest stays in the workspace and
+// names a blob. Manifests are read back from storage, a tampered one must not
+// reach files or keys outside of the repl.
+func restorable(rel string, file File) bool {
+	if path.Clean(rel) != rel || !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return false
+	}
+	hash, err := hex.DecodeString(file.Hash)
+	return err == nil && len(hash) == sha256.Size
+}
+
+// restoreFile writes a blob next to target first and renames it over, so an
+// interrupted restore never leaves a half written file behind.
+func restoreFile(ctx context.Context, s storage.WorkspaceStorage, key, target string) error {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to restore %s: %w", target, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Keep the mode of the file being replaced, e.g. executable scripts
+	mode := iofs.FileMode(0644)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+func copyBlob(ctx context.Context, s storage.WorkspaceStorage, key, dst string, size int64) error {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	defer body.Close()
+
+	return s.Put(ctx, dst, body, size)
+}
+
+// HashFile returns the hex sha256 of a file, the name of its blob
+func HashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
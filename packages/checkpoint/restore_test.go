@@ -0,0 +1,196 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"packages/storage"
+)
+
+const (
+	prefix    = "checkpoints/octocat/demo/"
+	workspace = "repl/octocat/demo/"
+)
+
+// putBlobs stores contents as blobs and returns the manifest of files
+func putBlobs(t *testing.T, s storage.WorkspaceStorage, files map[string]string) Manifest {
+	t.Helper()
+
+	m := Manifest{Id: "1", Files: make(map[string]File)}
+	for rel, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		hash := hex.EncodeToString(sum[:])
+		if err := s.Put(context.Background(), BlobKey(prefix, hash), strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+		m.Files[rel] = File{Hash: hash, Size: int64(len(content))}
+	}
+	return m
+}
+
+// tamper adds entries that point outside of the workspace or the blobs
+func tamper(m Manifest) Manifest {
+	valid := m.Files["main.go"].Hash
+	m.Files["../escape.txt"] = File{Hash: valid}
+	m.Files["src/../../escape.txt"] = File{Hash: valid}
+	m.Files["/etc/escape.txt"] = File{Hash: valid}
+	m.Files["blob.txt"] = File{Hash: "../../../repl/octocat/other/secret.txt"}
+	return m
+}
+
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(file string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, file)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func readPrefix(t *testing.T, s storage.WorkspaceStorage, prefix string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, obj := range objects {
+		body, err := s.Get(ctx, obj.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		files[strings.TrimPrefix(obj.Key, prefix)] = string(data)
+	}
+	return files
+}
+
+var restoreTests = []struct {
+	name string
+	path string
+	// current is the workspace before the restore
+	current map[string]string
+	want    map[string]string
+}{
+	{
+		name:    "whole workspace",
+		current: map[string]string{"main.go": "edited", "new.txt": "created since"},
+		want:    map[string]string{"main.go": "package main", "src/app.js": "console.log(1)"},
+	},
+	{
+		name:    "single file",
+		path:    "/main.go",
+		current: map[string]string{"main.go": "edited", "src/app.js": "edited too"},
+		want:    map[string]string{"main.go": "package main", "src/app.js": "edited too"},
+	},
+	{
+		name:    "folder",
+		path:    "src",
+		current: map[string]string{"main.go": "edited", "src/new.js": "created since"},
+		want:    map[string]string{"main.go": "edited", "src/app.js": "console.log(1)"},
+	},
+}
+
+var checkpointFiles = map[string]string{"main.go": "package main", "src/app.js": "console.log(1)"}
+
+func TestRestoreToDir(t *testing.T) {
+	for _, tt := range restoreTests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			s := storage.NewFS(filepath.Join(base, "storage"))
+			m := tamper(putBlobs(t, s, checkpointFiles))
+
+			dir := filepath.Join(base, "work", "workspace")
+			for rel, content := range tt.current {
+				file := filepath.Join(dir, filepath.FromSlash(rel))
+				os.MkdirAll(filepath.Dir(file), 0755)
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := RestoreToDir(context.Background(), s, prefix, m, tt.path, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readDir(t, dir)
+			if len(got) != len(tt.want) {
+				t.Errorf("workspace = %v, want %v", got, tt.want)
+			}
+			for rel, want := range tt.want {
+				if got[rel] != want {
+					t.Errorf("%s = %q, want %q", rel, got[rel], want)
+				}
+			}
+			if escaped := readDir(t, filepath.Join(base, "work")); len(escaped) != len(got) {
+				t.Errorf("files written outside of the workspace: %v", escaped)
+			}
+		})
+	}
+}
+
+func TestRestoreToPrefix(t *testing.T) {
+	for _, tt := range restoreTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := storage.NewFS(filepath.Join(t.TempDir(), "storage"))
+			m := tamper(putBlobs(t, s, checkpointFiles))
+
+			for rel, content := range tt.current {
+				if err := s.Put(ctx, workspace+rel, strings.NewReader(content), int64(len(content))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := RestoreToPrefix(ctx, s, prefix, m, tt.path, workspace); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readPrefix(t, s, workspace)
+			if len(got) != len(tt.want) {
+				t.Errorf("workspace = %v, want %v", got, tt.want)
+			}
+			for rel, want := range tt.want {
+				if got[rel] != want {
+					t.Errorf("%s = %q, want %q", rel, got[rel], want)
+				}
+			}
+
+			// Only the workspace and the blobs are in storage
+			all := readPrefix(t, s, "")
+			keys := make([]string, 0, len(all))
+			for key := range all {
+				if !strings.HasPrefix(key, workspace) && !strings.HasPrefix(key, BlobsPrefix(prefix)) {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+			if len(keys) > 0 {
+				t.Errorf("objects written outside of the workspace: %v", keys)
+			}
+		})
+	}
+}
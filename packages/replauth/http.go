@@ -0,0 +1,202 @@
+package replauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"packages/utils/json"
+)
+
+const (
+	// CookieName holds the token of the browser, for the requests that can
+	// not carry a header: page loads of the app preview, its assets, etc.
+	CookieName = "devex_token"
+	// QueryParam carries the token of a link or a WebSocket URL
+	QueryParam = "token"
+)
+
+// Source is where the token of a request is taken from, they are combined
+// with |
+type Source int
+
+const (
+	// SourceHeader is an Authorization: Bearer header
+	SourceHeader Source = 1 << iota
+	// SourceQuery is the QueryParam, moved to the cookie when SourceCookie
+	// is accepted too
+	SourceQuery
+	// SourceCookie is the CookieName cookie
+	SourceCookie
+)
+
+const (
+	// APISources are the sources of the endpoints of a repl. A cookie would
+	// be sent along by any page of the same site.
+	APISources = SourceHeader | SourceQuery
+	// ProxySources are the sources of the user's app behind a proxy, its
+	// Authorization header is the app's own
+	ProxySources = SourceQuery | SourceCookie
+)
+
+type claimsKey struct{}
+
+// FromContext returns the claims of the request, nil when it was served
+// without a token
+func FromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
+
+// FromRequest returns the token of r from the first of sources with one, in
+// the order header, query, cookie, and the source it came from
+func FromRequest(r *http.Request, sources Source) (string, Source) {
+	if sources&SourceHeader != 0 {
+		if token, ok := bearer(r); ok {
+			return token, SourceHeader
+		}
+	}
+	if sources&SourceQuery != 0 {
+		if token := r.URL.Query().Get(QueryParam); token != "" {
+			return token, SourceQuery
+		}
+	}
+	if sources&SourceCookie != 0 {
+		if cookie, err := r.Cookie(CookieName); err == nil {
+			return cookie.Value, SourceCookie
+		}
+	}
+	return "", 0
+}
+
+func bearer(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token), ok
+}
+
+// Middleware serves next the requests with a valid token, and the ones
+// public says are open to anyone. sources tells where the token of a
+// request is taken from, APISources when nil. A nil Verifier serves
+// everything.
+func (v *Verifier) Middleware(next http.Handler, public func(*http.Request) bool, sources func(*http.Request) Source) http.Handler {
+	if v == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted := APISources
+		if sources != nil {
+			accepted = sources(r)
+		}
+		if accepted&SourceHeader == 0 {
+			// Keep a token of the repl from reaching the user's app, but
+			// not the app's own tokens
+			if token, ok := bearer(r); ok && v.signed(token) {
+				r.Header.Del("Authorization")
+			}
+		}
+
+		if public != nil && public(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, source := FromRequest(r, accepted)
+		claims, err := v.Verify(token)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims))
+		if source == SourceQuery {
+			// Keep the token out of the logs and of the user's app
+			if accepted&SourceCookie != 0 {
+				http.SetCookie(w, cookie(r, token, claims.Expiry()))
+			}
+			query := r.URL.Query()
+			query.Del(QueryParam)
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cookie scopes token to the prefix the request was served at, e.g. the
+// /<replId> of the ingress, so that the repls of a host do not share it
+func cookie(r *http.Request, token string, expiry time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/") + "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusUnauthorized
+	if errors.Is(err, ErrWrongRepl) {
+		status = http.StatusForbidden
+	}
+	json.WriteError(w, status, err.Error())
+}
+
+// RequireScope serves next the requests whose token has scope, behind
+// Middleware. A nil Verifier serves everything.
+func (v *Verifier) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	if v == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := FromContext(r.Context())
+		if claims == nil || claims.Scope != scope {
+			json.WriteError(w, http.StatusForbidden, "access token lacks the "+scope+" scope")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RevokeRequest revokes a token by its ID until it expires, or every user
+// token issued before a time
+type RevokeRequest struct {
+	TokenId   string    `json:"tokenId,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	Before    time.Time `json:"before,omitempty"`
+}
+
+// RevokeHandler serves the RevokeRequests of core
+func (v *Verifier) RevokeHandler() http.HandlerFunc {
+	return v.RequireScope(ScopeCore, func(w http.ResponseWriter, r *http.Request) {
+		if v == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var req RevokeRequest
+		if err := json.ReadJSON(r, &req); err != nil {
+			json.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.TokenId == "" && req.Before.IsZero() {
+			json.WriteError(w, http.StatusBadRequest, "tokenId or before is required")
+			return
+		}
+		if req.TokenId != "" && req.ExpiresAt.IsZero() {
+			json.WriteError(w, http.StatusBadRequest, "expiresAt is required with tokenId")
+			return
+		}
+
+		if req.TokenId != "" {
+			v.Revoke(req.TokenId, req.ExpiresAt)
+		}
+		if !req.Before.IsZero() {
+			v.RevokeBefore(req.Before)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,226 @@
+package replauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var secret = []byte("test-secret")
+
+func issue(t *testing.T, replId, scope string, ttl time.Duration) (string, *Claims) {
+	t.Helper()
+	token, claims, err := Issue(ReplKey(secret, replId), replId, "octocat", scope, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, claims
+}
+
+func TestVerify(t *testing.T) {
+	v := NewVerifier(ReplKey(secret, "repl-a"), "repl-a")
+
+	valid, _ := issue(t, "repl-a", ScopeUser, time.Minute)
+	expired, _ := issue(t, "repl-a", ScopeUser, -time.Minute)
+	// Signed with the key of repl-b, as its runner could
+	other, _ := issue(t, "repl-b", ScopeUser, time.Minute)
+	// Signed with the key of repl-b but claiming repl-a
+	forged, _, _ := Issue(ReplKey(secret, "repl-b"), "repl-a", "octocat", ScopeUser, time.Minute)
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{ReplId: "repl-a"})
+	unsigned, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"missing", "", ErrNoToken},
+		{"expired", expired, jwt.ErrTokenExpired},
+		{"other repl", other, ErrInvalid},
+		{"forged", forged, ErrInvalid},
+		{"unsigned", unsigned, ErrInvalid},
+		{"garbage", "not.a.token", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (claims.User() != "octocat" || claims.ReplId != "repl-a") {
+				t.Errorf("Verify() = %+v", claims)
+			}
+		})
+	}
+
+	// The right key but the wrong repl
+	wrongRepl := NewVerifier(ReplKey(secret, "repl-a"), "repl-b")
+	if _, err := wrongRepl.Verify(valid); !errors.Is(err, ErrWrongRepl) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrWrongRepl)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	v := NewVerifier(ReplKey(secret, "repl-a"), "repl-a")
+
+	token, claims := issue(t, "repl-a", ScopeUser, time.Minute)
+	kept, _ := issue(t, "repl-a", ScopeUser, time.Minute)
+	v.Revoke(claims.ID, claims.Expiry())
+	if _, err := v.Verify(token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Verify() of a revoked token error = %v, want %v", err, ErrRevoked)
+	}
+	if _, err := v.Verify(kept); err != nil {
+		t.Errorf("Verify() of another token error = %v", err)
+	}
+
+	// Core tokens are only revoked by ID
+	core, _ := issue(t, "repl-a", ScopeCore, time.Minute)
+	v.RevokeBefore(time.Now().Add(time.Second))
+	if _, err := v.Verify(kept); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Verify() of a token issued before error = %v, want %v", err, ErrRevoked)
+	}
+	if _, err := v.Verify(core); err != nil {
+		t.Errorf("Verify() of a core token error = %v", err)
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	now := time.Now()
+	token, claims := issue(t, "repl-a", ScopeUser, time.Minute)
+	kept, _ := issue(t, "repl-a", ScopeUser, time.Minute)
+
+	var r Revocations
+	r = r.Add(RevokeRequest{TokenId: "expired", ExpiresAt: now.Add(-time.Second)}, now)
+	r = r.Add(RevokeRequest{TokenId: "later", ExpiresAt: now.Add(time.Hour)}, now)
+	r = r.Add(RevokeRequest{TokenId: claims.ID, ExpiresAt: claims.Expiry()}, now)
+	if len(r.Tokens) != 2 {
+		t.Errorf("Tokens = %v, want the unexpired ones", r.Tokens)
+	}
+	// Expired revocations are dropped by the next one
+	r = r.Add(RevokeRequest{}, now.Add(2*time.Hour))
+	if len(r.Tokens) != 0 {
+		t.Errorf("Tokens = %v, want none", r.Tokens)
+	}
+
+	r = Revocations{}.Add(RevokeRequest{TokenId: claims.ID, ExpiresAt: claims.Expiry()}, now)
+	// A restarted runner gets them back
+	v := NewVerifier(ReplKey(secret, "repl-a"), "repl-a")
+	v.Apply(r)
+	if _, err := v.Verify(token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Verify() of a revoked token error = %v, want %v", err, ErrRevoked)
+	}
+	if _, err := v.Verify(kept); err != nil {
+		t.Errorf("Verify() of another token error = %v", err)
+	}
+
+	r = r.Add(RevokeRequest{Before: now.Add(time.Second)}, now)
+	if r = r.Add(RevokeRequest{Before: now.Add(-time.Hour)}, now); !r.Before.Equal(now.Add(time.Second)) {
+		t.Errorf("Before = %v, want the latest", r.Before)
+	}
+	v = NewVerifier(ReplKey(secret, "repl-a"), "repl-a")
+	v.Apply(r)
+	if _, err := v.Verify(kept); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Verify() of a token issued before error = %v, want %v", err, ErrRevoked)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := NewVerifier(ReplKey(secret, "repl-a"), "repl-a")
+	token, _ := issue(t, "repl-a", ScopeUser, time.Minute)
+
+	var query, authorization string
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		authorization = r.Header.Get("Authorization")
+		if FromContext(r.Context()) == nil && r.URL.Path != "/public" {
+			t.Error("no claims in the context")
+		}
+	}), func(r *http.Request) bool {
+		return r.URL.Path == "/public"
+	}, func(r *http.Request) Source {
+		if strings.HasPrefix(r.URL.Path, "/app") {
+			return ProxySources
+		}
+		return APISources
+	})
+
+	header := func(value string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", value) }
+	}
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: CookieName, Value: token}) }
+
+	tests := []struct {
+		name   string
+		setup  func(r *http.Request)
+		path   string
+		status int
+		cookie bool
+		// authorization is the header the handler gets
+		authorization string
+	}{
+		{name: "none", path: "/", status: http.StatusUnauthorized},
+		{name: "public", path: "/public", status: http.StatusOK},
+		{name: "header", setup: header("Bearer " + token), path: "/", status: http.StatusOK, authorization: "Bearer " + token},
+		{name: "query", path: "/?a=1&token=" + token, status: http.StatusOK},
+		{name: "invalid", setup: header("Bearer nope"), path: "/", status: http.StatusUnauthorized},
+		// A page of the same site must not reach the API with the cookie
+		{name: "cookie on the API", setup: withCookie, path: "/", status: http.StatusUnauthorized},
+		{name: "cookie on the app", setup: withCookie, path: "/app", status: http.StatusOK},
+		{name: "query on the app", path: "/app?a=1&token=" + token, status: http.StatusOK, cookie: true},
+		// The app's own tokens are its business
+		{name: "header on the app", setup: header("Bearer " + token), path: "/app", status: http.StatusUnauthorized},
+		{
+			name:          "app token on the app",
+			setup:         func(r *http.Request) { withCookie(r); header("Bearer app-token")(r) },
+			path:          "/app",
+			status:        http.StatusOK,
+			authorization: "Bearer app-token",
+		},
+		{
+			name:   "repl token on the app",
+			setup:  func(r *http.Request) { withCookie(r); header("Bearer " + token)(r) },
+			path:   "/app",
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("X-Forwarded-Prefix", "/repl-a")
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+			query, authorization = "", ""
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if authorization != tt.authorization {
+				t.Errorf("Authorization = %q, want %q", authorization, tt.authorization)
+			}
+			if strings.Contains(query, QueryParam+"=") {
+				t.Errorf("query = %q, want the token removed", query)
+			}
+			cookies := w.Result().Cookies()
+			if tt.cookie != (len(cookies) == 1) {
+				t.Fatalf("cookies = %v, want one: %v", cookies, tt.cookie)
+			}
+			if tt.cookie {
+				if c := cookies[0]; c.Value != token || c.Path != "/repl-a/" || !c.HttpOnly {
+					t.Errorf("cookie = %+v", c)
+				}
+			}
+		})
+	}
+}
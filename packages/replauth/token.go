@@ -0,0 +1,93 @@
+// Package replauth signs and verifies the access tokens of a repl's
+// endpoints (runner WebSocket and API, app proxy, mcp server).
+//
+// Core mints short-lived tokens bound to a user and a repl when the repl is
+// activated. They are signed with a key derived from core's secret and the
+// replId, so the runner and mcp server of a repl only ever hold the key of
+// their own repl and can not forge tokens for another one.
+package replauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Scopes of a token
+const (
+	// ScopeUser is the editor of the repl, in the browser
+	ScopeUser = "user"
+	// ScopeCore is core itself, e.g. taking checkpoints or revoking tokens
+	ScopeCore = "core"
+	// ScopeMcp is the mcp server of the repl, calling the runner's gRPC
+	// server
+	ScopeMcp = "mcp"
+	// ScopeRunner is the runner of the repl, calling core back
+	ScopeRunner = "runner"
+)
+
+var (
+	ErrNoToken   = errors.New("access token required")
+	ErrInvalid   = errors.New("invalid access token")
+	ErrWrongRepl = errors.New("access token is for another repl")
+	ErrRevoked   = errors.New("access token was revoked")
+)
+
+// Claims of a token, Subject is the user and ID identifies the token to
+// revoke it
+type Claims struct {
+	ReplId string `json:"replId"`
+	Scope  string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// User the token was issued to
+func (c *Claims) User() string {
+	return c.Subject
+}
+
+// Expiry of the token, the zero time when it has none
+func (c *Claims) Expiry() time.Time {
+	if c.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return c.ExpiresAt.Time
+}
+
+// ReplKey derives the signing key of replId from secret
+func ReplKey(secret []byte, replId string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(replId))
+	return mac.Sum(nil)
+}
+
+// Issue signs a token of replId for user, valid for ttl
+func Issue(key []byte, replId, user, scope string, ttl time.Duration) (string, *Claims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		ReplId: replId,
+		Scope:  scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			Subject:   user,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, claims, nil
+}
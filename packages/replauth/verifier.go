@@ -0,0 +1,155 @@
+package replauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verifier checks the tokens of a single repl, and remembers the ones
+// revoked before they expire
+type Verifier struct {
+	key    []byte
+	replId string
+	parser *jwt.Parser
+
+	mu sync.Mutex
+	// revokedBefore rejects the user tokens issued before it
+	revokedBefore time.Time
+	// revoked holds the expiry of the tokens revoked by ID
+	revoked map[string]time.Time
+}
+
+// NewVerifier checks the tokens of replId, signed with its key
+func NewVerifier(key []byte, replId string) *Verifier {
+	return &Verifier{
+		key:    key,
+		replId: replId,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+		),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Verify returns the claims of a valid token of the repl
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	claims := &Claims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return v.key, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, jwt.ErrTokenExpired
+		}
+		return nil, ErrInvalid
+	}
+	if claims.ReplId != v.replId {
+		return nil, ErrWrongRepl
+	}
+	if err := v.Check(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// signed tells whether token was signed with the key of the repl, valid or
+// not
+func (v *Verifier) signed(token string) bool {
+	_, err := v.Verify(token)
+	return !errors.Is(err, ErrInvalid) && !errors.Is(err, ErrNoToken)
+}
+
+// Check tells whether verified claims are still valid, for connections that
+// outlive the request that opened them
+func (v *Verifier) Check(claims *Claims) error {
+	if !time.Now().Before(claims.Expiry()) {
+		return jwt.ErrTokenExpired
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.revoked[claims.ID]; ok {
+		return ErrRevoked
+	}
+	if claims.Scope == ScopeUser && claims.IssuedAt != nil && claims.IssuedAt.Before(v.revokedBefore) {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// Revoke rejects the token id until it expires at expiry
+func (v *Verifier) Revoke(id string, expiry time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range v.revoked {
+		if !now.Before(expiry) {
+			delete(v.revoked, id)
+		}
+	}
+	if now.Before(expiry) {
+		v.revoked[id] = expiry
+	}
+}
+
+// Apply rejects the tokens of revocations, e.g. the ones core kept while the
+// repl was stopped
+func (v *Verifier) Apply(revocations Revocations) {
+	for id, expiry := range revocations.Tokens {
+		v.Revoke(id, expiry)
+	}
+	if !revocations.Before.IsZero() {
+		v.RevokeBefore(revocations.Before)
+	}
+}
+
+// RevokeBefore rejects the user tokens issued before t. Tokens carry their
+// issue time to the second, the ones issued in the second of t are kept.
+func (v *Verifier) RevokeBefore(t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t = t.Truncate(time.Second)
+	if t.After(v.revokedBefore) {
+		v.revokedBefore = t
+	}
+}
+
+// Revocations are the tokens of a repl revoked before they expire. Core
+// keeps them with the repl and hands them to its runner and mcp server when
+// they start, a Verifier only holds them in memory.
+type Revocations struct {
+	// Before rejects the user tokens issued before it
+	Before time.Time `json:"before,omitempty"`
+	// Tokens holds the expiry of the tokens revoked by ID
+	Tokens map[string]time.Time `json:"tokens,omitempty"`
+}
+
+// Add returns the revocations with the ones of req, without the tokens that
+// expired by now
+func (r Revocations) Add(req RevokeRequest, now time.Time) Revocations {
+	next := Revocations{Before: r.Before, Tokens: make(map[string]time.Time)}
+	for id, expiry := range r.Tokens {
+		if now.Before(expiry) {
+			next.Tokens[id] = expiry
+		}
+	}
+	if req.TokenId != "" && now.Before(req.ExpiresAt) {
+		next.Tokens[req.TokenId] = req.ExpiresAt
+	}
+	if req.Before.After(next.Before) {
+		next.Before = req.Before
+	}
+	return next
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DownloadPrefix writes every object under prefix into dir, keeping the
+// relative layout of the keys.
+func DownloadPrefix(ctx context.Context, s WorkspaceStorage, prefix, dir string) error {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Clean(dir) + string(filepath.Separator)
+	for _, obj := range objects {
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(obj.Key, prefix)))
+		if !strings.HasPrefix(target, root) {
+			log.Printf("⚠️ Skipping object outside of the folder: %s", obj.Key)
+			continue
+		}
+
+		if err := downloadObject(ctx, s, obj.Key, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downloadObject(ctx context.Context, s WorkspaceStorage, key, target string) error {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		return fmt.Errorf("failed to download object %s: %w", key, err)
+	}
+	return nil
+}
+
+// UploadDir stores every regular file under dir below prefix
+func UploadDir(ctx context.Context, s WorkspaceStorage, dir, prefix string) error {
+	return filepath.WalkDir(dir, func(file string, entry iofs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		return UploadFile(ctx, s, file, path.Join(prefix, filepath.ToSlash(rel)))
+	})
+}
+
+// UploadFile stores a single file under key
+func UploadFile(ctx context.Context, s WorkspaceStorage, file, key string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, key, f, info.Size())
+}
+
+func totalSize(objects []Object) int64 {
+	var size int64
+	for _, obj := range objects {
+		size += obj.Size
+	}
+	return size
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var _ WorkspaceStorage = (*FS)(nil)
+
+// FS keeps objects as files under a root directory, for running DevEx on a
+// single machine without any object storage.
+type FS struct {
+	root string
+}
+
+func NewFS(root string) *FS {
+	return &FS{root: root}
+}
+
+// path maps a key to a file under root, refusing keys that escape it
+func (f *FS) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(clean)), nil
+}
+
+func (f *FS) Ping(ctx context.Context) error {
+	if err := os.MkdirAll(f.root, 0755); err != nil {
+		return fmt.Errorf("storage dir is not usable: %w", err)
+	}
+	return nil
+}
+
+func (f *FS) CopyPrefix(ctx context.Context, src, dst string) error {
+	objects, err := f.List(ctx, src)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		log.Println("⚠️ No objects found under prefix:", src)
+		return nil
+	}
+
+	for _, obj := range objects {
+		if err := f.copy(ctx, obj, path.Join(dst, strings.TrimPrefix(obj.Key, src))); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Copied %d objects %s -> %s", len(objects), src, dst)
+	return nil
+}
+
+func (f *FS) copy(ctx context.Context, obj Object, destinationKey string) error {
+	body, err := f.Get(ctx, obj.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return f.Put(ctx, destinationKey, body, obj.Size)
+}
+
+func (f *FS) DeletePrefix(ctx context.Context, prefix string) error {
+	objects, err := f.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err := f.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+
+	// Drop the now empty folder, like S3 does implicitly
+	if strings.HasSuffix(prefix, "/") {
+		if dir, err := f.path(prefix); err == nil {
+			removeEmptyDirs(dir)
+		}
+	}
+
+	log.Printf("✅ Deleted %d objects under %s", len(objects), prefix)
+	return nil
+}
+
+// List walks the folder holding prefix and keeps the keys starting with it,
+// as a prefix does not have to end on a "/".
+func (f *FS) List(ctx context.Context, prefix string) ([]Object, error) {
+	base := f.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := f.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		base = dir
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(base, func(file string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.root, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	return objects, nil
+}
+
+func (f *FS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// tmpDir holds the objects being written, next to root rather than in it so
+// that no key can collide with them
+func (f *FS) tmpDir() string {
+	return filepath.Clean(f.root) + ".tmp"
+}
+
+// Put writes to a temporary file first so that readers never see a partial object
+func (f *FS) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	file, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.tmpDir(), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.tmpDir(), "object-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+func (f *FS) Delete(ctx context.Context, key string) error {
+	file, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (f *FS) Size(ctx context.Context, prefix string) (int64, error) {
+	objects, err := f.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	return totalSize(objects), nil
+}
+
+// removeEmptyDirs removes dir and the empty folders below it
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, entry.Name()))
+		}
+	}
+	os.Remove(dir)
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSPutList(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "storage")
+	f := NewFS(root)
+
+	// Names the backend once used for its own temp files are user files too
+	keys := []string{"repl/octocat/demo/main.go", "repl/octocat/demo/.tmp-notes"}
+	for _, key := range keys {
+		if err := f.Put(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := f.List(ctx, "repl/octocat/demo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != len(keys) {
+		t.Fatalf("List() = %v, want %v", objects, keys)
+	}
+
+	// Nothing is left behind, in the tree or next to it
+	entries, err := os.ReadDir(f.tmpDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temp files left: %v", entries)
+	}
+}
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var _ WorkspaceStorage = (*Presigned)(nil)
+
+// Presigner hands out requests on single objects that can be made without
+// the storage credentials, S3 implements it
+type Presigner interface {
+	Presign(ctx context.Context, req PresignRequest, ttl time.Duration) (PresignedRequest, error)
+}
+
+// PresignRequest asks for a GET, PUT or DELETE of Key. Size is the length of
+// the body of a PUT.
+type PresignRequest struct {
+	Method string `json:"method"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// PresignedRequest has to be made with its method, URL and headers as they
+// are, they are covered by the signature
+type PresignedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// Presigned is the storage of a process that holds no storage credentials,
+// e.g. the runner of a repl. Its endpoint lists objects for it and presigns
+// every request on an object, and decides which keys it may touch.
+//
+//	GET  <endpoint>/objects?prefix=<prefix>  → []Object
+//	POST <endpoint>/presign PresignRequest   → PresignedRequest
+type Presigned struct {
+	endpoint string
+	// authorize adds the credentials of the process to endpoint requests
+	authorize func(*http.Request) error
+	client    *http.Client
+}
+
+func NewPresigned(endpoint string, authorize func(*http.Request) error) *Presigned {
+	return &Presigned{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		authorize: authorize,
+		client:    &http.Client{},
+	}
+}
+
+// Ping is not supported, the endpoint only answers for the keys of the process
+func (p *Presigned) Ping(ctx context.Context) error {
+	return fmt.Errorf("%w: presigned storage can not be pinged", errors.ErrUnsupported)
+}
+
+func (p *Presigned) CopyPrefix(ctx context.Context, src, dst string) error {
+	objects, err := p.List(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		body, err := p.Get(ctx, obj.Key)
+		if err != nil {
+			return err
+		}
+		err = p.Put(ctx, path.Join(dst, strings.TrimPrefix(obj.Key, src)), body, obj.Size)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Presigned) DeletePrefix(ctx context.Context, prefix string) error {
+	objects, err := p.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err := p.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Presigned) List(ctx context.Context, prefix string) ([]Object, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"/objects?prefix="+url.QueryEscape(prefix), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var objects []Object
+	if err := json.NewDecoder(resp.Body).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return objects, nil
+}
+
+func (p *Presigned) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := p.presigned(ctx, PresignRequest{Method: http.MethodGet, Key: key}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+func (p *Presigned) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := p.presigned(ctx, PresignRequest{Method: http.MethodPut, Key: key, Size: size}, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		// An empty body is still sent with its Content-Length
+		req.Body = http.NoBody
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes a single object, deleting a missing key is not an error
+func (p *Presigned) Delete(ctx context.Context, key string) error {
+	req, err := p.presigned(ctx, PresignRequest{Method: http.MethodDelete, Key: key}, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err := checkStatus(resp); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (p *Presigned) Size(ctx context.Context, prefix string) (int64, error) {
+	objects, err := p.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	return totalSize(objects), nil
+}
+
+// presigned has the endpoint presign r and returns the request to make
+func (p *Presigned) presigned(ctx context.Context, r PresignRequest, body io.Reader) (*http.Request, error) {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/presign", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign %s %s: %w", r.Method, r.Key, err)
+	}
+	defer resp.Body.Close()
+
+	var presigned PresignedRequest
+	if err := json.NewDecoder(resp.Body).Decode(&presigned); err != nil {
+		return nil, fmt.Errorf("failed to presign %s %s: %w", r.Method, r.Key, err)
+	}
+
+	objectReq, err := http.NewRequestWithContext(ctx, presigned.Method, presigned.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range presigned.Header {
+		// Set by the client from the request itself
+		if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		objectReq.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	return objectReq, nil
+}
+
+// do makes an authorized request to the endpoint
+func (p *Presigned) do(req *http.Request) (*http.Response, error) {
+	if err := p.authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkStatus turns an unsuccessful response into an error, closing its body
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// presignServer plays both the presigning endpoint, which only serves keys
+// under allowed, and the object store the presigned requests go to
+func presignServer(t *testing.T, allowed string) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	objects := make(map[string]string)
+
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, r *http.Request, key string) bool {
+		if r.Header.Get("Authorization") != "Bearer test" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return false
+		}
+		if !strings.HasPrefix(key, allowed) {
+			http.Error(w, "outside of the repl", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("GET /storage/objects", func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		if !authorized(w, r, prefix) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		list := []Object{}
+		for key, body := range objects {
+			if strings.HasPrefix(key, prefix) {
+				list = append(list, Object{Key: key, Size: int64(len(body))})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("POST /storage/presign", func(w http.ResponseWriter, r *http.Request) {
+		var req PresignRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !authorized(w, r, req.Key) {
+			return
+		}
+		json.NewEncoder(w).Encode(PresignedRequest{
+			Method: req.Method,
+			URL:    "http://" + r.Host + "/bucket/" + req.Key + "?signature=ok",
+		})
+	})
+	mux.HandleFunc("/bucket/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("signature") != "ok" || r.Header.Get("Authorization") != "" {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			body, ok := objects[key]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, body)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if int64(len(body)) != r.ContentLength {
+				http.Error(w, "bad length", http.StatusBadRequest)
+				return
+			}
+			objects[key] = string(body)
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPresigned(t *testing.T) {
+	ctx := context.Background()
+	server := presignServer(t, "repl/octocat/demo/")
+	p := NewPresigned(server.URL+"/storage/", func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer test")
+		return nil
+	})
+
+	files := map[string]string{
+		"repl/octocat/demo/main.go":    "package main",
+		"repl/octocat/demo/empty.txt":  "",
+		"repl/octocat/demo/src/app.js": "console.log(1)",
+	}
+	for key, body := range files {
+		if err := p.Put(ctx, key, strings.NewReader(body), int64(len(body))); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+
+	objects, err := p.List(ctx, "repl/octocat/demo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != len(files) {
+		t.Errorf("List() = %v, want %d objects", objects, len(files))
+	}
+	if size, err := p.Size(ctx, "repl/octocat/demo/"); err != nil || size != 26 {
+		t.Errorf("Size() = %d, %v, want 26", size, err)
+	}
+
+	for key, want := range files {
+		body, err := p.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", key, err)
+		}
+		got, _ := io.ReadAll(body)
+		body.Close()
+		if string(got) != want {
+			t.Errorf("Get(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	if err := p.Delete(ctx, "repl/octocat/demo/main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(ctx, "repl/octocat/demo/main.go"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a deleted object error = %v, want %v", err, ErrNotFound)
+	}
+
+	// The endpoint decides which keys can be reached
+	if _, err := p.List(ctx, "repl/octocat/other/"); err == nil {
+		t.Error("List() of another repl succeeded")
+	}
+	if err := p.Put(ctx, "repl/octocat/other/main.go", strings.NewReader("x"), 1); err == nil {
+		t.Error("Put() in another repl succeeded")
+	}
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	_ WorkspaceStorage = (*S3)(nil)
+	_ Presigner        = (*S3)(nil)
+)
+
+// S3Config points at any S3-compatible endpoint: AWS S3, DigitalOcean
+// Spaces, MinIO, ...
+type S3Config struct {
+	// Endpoint is the base URL, e.g. https://blr1.digitaloceanspaces.com or
+	// http://localhost:9000. Empty means AWS S3.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+type S3 struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3(cfg S3Config) *S3 {
+	awsCfg, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithRegion(cfg.Region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")),
+	)
+	if err != nil {
+		log.Printf("❌ Failed to load config: %v", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = true
+	})
+
+	return &S3{
+		client: client,
+		bucket: cfg.Bucket,
+	}
+}
+
+// To Ping the S3 Connection
+func (s *S3) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("S3 ping failed: %w", err)
+	}
+	return nil
+}
+
+func (s *S3) CopyPrefix(ctx context.Context, src, dst string) error {
+	objects, err := s.List(ctx, src)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		log.Println("⚠️ No objects found under prefix:", src)
+		return nil
+	}
+
+	for _, obj := range objects {
+		destinationKey := path.Join(dst, strings.TrimPrefix(obj.Key, src))
+
+		// Use simple bucket/key format for DigitalOcean Spaces compatibility
+		_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			CopySource: aws.String(s.bucket + "/" + obj.Key),
+			Key:        aws.String(destinationKey),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy object %s -> %s: %w", obj.Key, destinationKey, err)
+		}
+	}
+
+	log.Printf("✅ Copied %d objects %s -> %s", len(objects), src, dst)
+	return nil
+}
+
+func (s *S3) DeletePrefix(ctx context.Context, prefix string) error {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		log.Println("⚠️ No objects found under prefix:", prefix)
+		return nil
+	}
+
+	// DeleteObjects takes at most 1000 keys per call
+	for start := 0; start < len(objects); start += 1000 {
+		end := min(start+1000, len(objects))
+
+		ids := make([]types.ObjectIdentifier, 0, end-start)
+		for _, obj := range objects[start:end] {
+			ids = append(ids, types.ObjectIdentifier{Key: aws.String(obj.Key)})
+		}
+
+		output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+		if len(output.Errors) > 0 {
+			e := output.Errors[0]
+			return fmt.Errorf("failed to delete %d objects, first: %s: %s",
+				len(output.Errors), aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+	}
+
+	log.Printf("✅ Deleted %d objects under %s", len(objects), prefix)
+	return nil
+}
+
+// List returns the objects under prefix, skipping folder placeholders like "base/lang/"
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range output.Contents {
+			key := aws.ToString(obj.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			objects = append(objects, Object{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return output.Body, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Size(ctx context.Context, prefix string) (int64, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	return totalSize(objects), nil
+}
+
+// Presign signs a single request on an object, valid for ttl
+func (s *S3) Presign(ctx context.Context, req PresignRequest, ttl time.Duration) (PresignedRequest, error) {
+	presigner := s3.NewPresignClient(s.client, s3.WithPresignExpires(ttl))
+
+	var (
+		presigned *v4.PresignedHTTPRequest
+		err       error
+	)
+	switch req.Method {
+	case http.MethodGet:
+		presigned, err = presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(req.Key),
+		})
+	case http.MethodPut:
+		presigned, err = presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(req.Key),
+			ContentLength: aws.Int64(req.Size),
+		})
+	case http.MethodDelete:
+		presigned, err = presigner.PresignDeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(req.Key),
+		})
+	default:
+		return PresignedRequest{}, fmt.Errorf("can not presign a %s request", req.Method)
+	}
+	if err != nil {
+		return PresignedRequest{}, fmt.Errorf("failed to presign %s %s: %w", req.Method, req.Key, err)
+	}
+
+	return PresignedRequest{
+		Method: presigned.Method,
+		URL:    presigned.URL,
+		Header: presigned.SignedHeader,
+	}, nil
+}
@@ -0,0 +1,38 @@
+// Package storage is where repl workspaces, templates and checkpoints are
+// kept. It is shared by core and the runner so that both read and write the
+// same layout, whatever the backend.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by Get for keys that do not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored object
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// WorkspaceStorage is a flat key/value object store. Keys use "/" as
+// separator and prefixes act as folders (e.g. "repl/<user>/<replId>/").
+type WorkspaceStorage interface {
+	Ping(ctx context.Context) error
+	// CopyPrefix copies every object under src to the same relative key under dst
+	CopyPrefix(ctx context.Context, src, dst string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores body under key, size is the length of body
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Delete removes a single object, deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// Size is the total size in bytes of the objects under prefix
+	Size(ctx context.Context, prefix string) (int64, error)
+}